@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// guardConcurrency applies the concurrency policy before dispatching. It
+// returns proceed=false when the dispatch must not happen, together with
+// the ID of an active run to wait on instead, if any.
+func guardConcurrency(ctx context.Context, client *trigwait.Client, config *Config) (runID int64, proceed bool, err error) {
+	if config.Concurrency == "" || config.Concurrency == trigwait.ConcurrencyAllow {
+		return 0, true, nil
+	}
+
+	d := dispatchFor(config)
+	active, err := client.ActiveRuns(ctx, d, concurrencyKey(config))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to check for active runs: %w", err)
+	}
+	if len(active) == 0 {
+		return 0, true, nil
+	}
+	run := active[0]
+	url := client.RunURL(run.ID)
+
+	switch config.Concurrency {
+	case trigwait.ConcurrencyWait:
+		logf(trigwait.LevelInfo, "⏸ Run #%d of %s @ %s is already %s; waiting on it instead of dispatching", run.ID, d.Workflow, d.Ref, run.Status)
+		config.result.runFound(run.ID, url)
+		return run.ID, false, nil
+	case trigwait.ConcurrencySkip:
+		logf(trigwait.LevelInfo, "⏭ Run #%d of %s @ %s is already %s; skipping dispatch", run.ID, d.Workflow, d.Ref, run.Status)
+		logf(trigwait.LevelInfo, "   URL: %s", url)
+		setOutput("workflow_id", strconv.FormatInt(run.ID, 10))
+		setOutput("workflow_url", url)
+		return 0, false, nil
+	}
+
+	for _, run := range active {
+		logf(trigwait.LevelInfo, "🛑 Cancelling active run #%d of %s @ %s", run.ID, d.Workflow, d.Ref)
+		if err := client.CancelRun(ctx, run.ID); err != nil {
+			logf(trigwait.LevelWarn, "⚠ Could not cancel run #%d: %v", run.ID, err)
+		}
+	}
+	return 0, true, nil
+}
+
+// concurrencyKey returns the text that identifies runs dispatched with the
+// same inputs in their display title, or "" when inputs are not compared.
+func concurrencyKey(config *Config) string {
+	if !config.MatchInputs {
+		return ""
+	}
+	return inputsKey(config) + "-"
+}
+
+// inputsKey is a short digest of the dispatch inputs that prefixes the
+// distinct ID when concurrency_match_inputs is enabled.
+func inputsKey(config *Config) string {
+	return inputsHash(config.ClientPayload, config.DistinctIDName)[len("sha256:"):][:8]
+}
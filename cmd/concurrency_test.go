@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func TestTriggerWorkflow_Concurrency(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        trigwait.ConcurrencyPolicy
+		matchInputs   bool
+		activeEnv     string
+		wantRunID     bool
+		wantRuns      int
+		wantCancelled bool
+	}{
+		{name: "allow", policy: trigwait.ConcurrencyAllow, activeEnv: "prod", wantRunID: true, wantRuns: 2},
+		{name: "wait", policy: trigwait.ConcurrencyWait, activeEnv: "prod", wantRunID: true, wantRuns: 1},
+		{name: "skip", policy: trigwait.ConcurrencySkip, activeEnv: "prod", wantRuns: 1},
+		{name: "cancel", policy: trigwait.ConcurrencyCancel, activeEnv: "prod", wantRunID: true, wantRuns: 2, wantCancelled: true},
+		{name: "same inputs", policy: trigwait.ConcurrencySkip, matchInputs: true, activeEnv: "prod", wantRuns: 1},
+		{name: "different inputs", policy: trigwait.ConcurrencySkip, matchInputs: true, activeEnv: "staging", wantRunID: true, wantRuns: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := newJSONFileSink(filepath.Join(t.TempDir(), "outputs.json"))
+			outputSinks = []OutputSink{sink}
+			defer func() { outputSinks = []OutputSink{githubOutputSink{}} }()
+
+			fake, config := newDeployFake(t, fakegh.Workflow{QueueTime: fakegh.Duration(time.Hour)})
+			config.Concurrency = tt.policy
+			config.MatchInputs = tt.matchInputs
+
+			// An earlier dispatch of the same workflow that is still queued
+			config.ClientPayload = map[string]interface{}{"env": tt.activeEnv}
+			setDistinctID(config, inputsKey(config)+"-AAAAAAAA")
+			if _, err := triggerWorkflow(context.Background(), config); err != nil {
+				t.Fatalf("first dispatch failed: %v", err)
+			}
+			active := fake.Runs()[0]
+
+			config.ClientPayload = map[string]interface{}{"env": "prod"}
+			setDistinctID(config, inputsKey(config)+"-BBBBBBBB")
+			runID, err := triggerWorkflow(context.Background(), config)
+			if err != nil {
+				t.Fatalf("triggerWorkflow failed: %v", err)
+			}
+
+			runs := fake.Runs()
+			if len(runs) != tt.wantRuns {
+				t.Errorf("expected %d runs, got %d", tt.wantRuns, len(runs))
+			}
+			if (runID != 0) != tt.wantRunID {
+				t.Errorf("unexpected run ID %d", runID)
+			}
+			if tt.policy == trigwait.ConcurrencyWait && runID != active.ID {
+				t.Errorf("expected to wait on run %d, got %d", active.ID, runID)
+			}
+			if tt.name == "skip" && sink.values["workflow_id"] != strconv.FormatInt(active.ID, 10) {
+				t.Errorf("expected workflow_id of the active run, got %q", sink.values["workflow_id"])
+			}
+			if cancelled := !runs[0].CancelledAt.IsZero(); cancelled != tt.wantCancelled {
+				t.Errorf("expected cancelled=%v for the active run", tt.wantCancelled)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_MatchInputsRequiresDistinctID(t *testing.T) {
+	os.Setenv("INPUT_OWNER", "test-owner")
+	os.Setenv("INPUT_REPO", "test-repo")
+	os.Setenv("INPUT_GITHUB_TOKEN", "test-token")
+	os.Setenv("INPUT_WORKFLOW_FILE_NAME", "test.yml")
+	os.Setenv("INPUT_CONCURRENCY", "wait")
+	os.Setenv("INPUT_CONCURRENCY_MATCH_INPUTS", "true")
+	defer func() {
+		os.Unsetenv("INPUT_OWNER")
+		os.Unsetenv("INPUT_REPO")
+		os.Unsetenv("INPUT_GITHUB_TOKEN")
+		os.Unsetenv("INPUT_WORKFLOW_FILE_NAME")
+		os.Unsetenv("INPUT_CONCURRENCY")
+		os.Unsetenv("INPUT_CONCURRENCY_MATCH_INPUTS")
+		os.Unsetenv("INPUT_DISTINCT_ID_NAME")
+	}()
+
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error without distinct_id_name")
+	}
+
+	os.Setenv("INPUT_DISTINCT_ID_NAME", "distinct_id")
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if prefix := inputsKey(config) + "-"; !strings.HasPrefix(config.DistinctID, prefix) {
+		t.Errorf("expected distinct ID prefixed with %q, got %q", prefix, config.DistinctID)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// dryRun runs the preflight checks and prints the dispatch request instead
+// of sending it. Every failed check is logged; the returned error is the
+// first one.
+func dryRun(ctx context.Context, config *Config) error {
+	client := newClient(config)
+	d := dispatchFor(config)
+
+	logf(trigwait.LevelInfo, "🧪 Dry run: %s/%s → %s @ %s", config.Owner, config.Repo, d.Workflow, d.Ref)
+	if config.DistinctID != "" {
+		setOutput("distinct_id", config.DistinctID)
+	}
+
+	_, checkErr := client.Preflight(ctx, d, trigwait.PreflightOptions{ValidateInputs: config.ValidateInputs})
+
+	method, url, body := client.DispatchRequest(d)
+	var pretty bytes.Buffer
+	json.Indent(&pretty, body, "   ", "  ")
+	logf(trigwait.LevelInfo, "   Would send: %s %s", method, url)
+	logf(trigwait.LevelInfo, "   %s", pretty.String())
+
+	request, _ := json.Marshal(map[string]interface{}{
+		"method": method,
+		"url":    url,
+		"body":   json.RawMessage(body),
+	})
+	setOutput("dispatch_request", string(request))
+
+	if checkErr != nil {
+		return checkErr
+	}
+	logf(trigwait.LevelInfo, "✅ Dry run passed; nothing was dispatched")
+	return nil
+}
+
+// dryRunPipeline prints the order the pipeline's stages would run in and
+// runs the preflight checks of every stage, as dryRun does for a single
+// workflow. Outputs of earlier stages are replaced with placeholders. Every
+// failed check is logged; the returned error is that of the first failed
+// stage.
+func dryRunPipeline(ctx context.Context, config *Config) error {
+	pipeline := config.Pipeline
+	if pipeline.Matrix {
+		logf(trigwait.LevelInfo, "🧪 Dry run: matrix of %d combinations", len(pipeline.Stages))
+	} else {
+		logf(trigwait.LevelInfo, "🧪 Dry run: pipeline of %d stages", len(pipeline.Stages))
+	}
+
+	outputs := make(map[string]map[string]string, len(pipeline.Stages))
+	var firstErr error
+	for i, stage := range pipeline.Stages {
+		stageConfig, err := stageConfigFor(ctx, config, stage, expandInputs(stage.Inputs, outputs))
+		ref := stage.Ref
+		if err == nil {
+			ref = stageConfig.Ref
+		}
+		line := fmt.Sprintf("   %d. %s: %s/%s → %s @ %s", i+1, stage.Name, stage.Owner, stage.Repo, stage.Workflow, ref)
+		if len(stage.Needs) > 0 {
+			line += fmt.Sprintf(" (needs %s)", strings.Join(stage.Needs, ", "))
+		}
+		logf(trigwait.LevelInfo, "%s", line)
+		outputs[stage.Name] = map[string]string{
+			"workflow_id":  "0",
+			"workflow_url": "https://github.com/dry-run",
+			"conclusion":   "success",
+			"distinct_id":  "dry-run",
+			"ref":          ref,
+		}
+
+		if err == nil {
+			client := newClient(stageConfig)
+			_, err = client.Preflight(ctx, dispatchFor(stageConfig), trigwait.PreflightOptions{ValidateInputs: config.ValidateInputs})
+			if err == nil {
+				method, url, _ := client.DispatchRequest(dispatchFor(stageConfig))
+				stageLogger(stage.Name).Log(trigwait.LevelInfo, fmt.Sprintf("   Would send: %s %s", method, url))
+			}
+		}
+		if err != nil {
+			logf(trigwait.LevelError, "   ❌ %s: %v", stage.Name, err)
+			if firstErr == nil {
+				firstErr = &trigwait.Error{Kind: trigwait.KindOf(err), Err: fmt.Errorf("stage %s failed: %w", stage.Name, err)}
+			}
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	logf(trigwait.LevelInfo, "✅ Dry run passed; nothing was dispatched")
+	return nil
+}
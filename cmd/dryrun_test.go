@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func newFakeGitHub(t *testing.T, repo *fakegh.Repo) (*fakegh.Server, *Config) {
+	t.Helper()
+	fake := fakegh.New(&fakegh.Config{Repos: []*fakegh.Repo{repo}})
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	config := &Config{
+		Owner:            repo.Owner,
+		Repo:             repo.Name,
+		GitHubToken:      "test-token",
+		GitHubAPIURL:     server.URL,
+		WorkflowFileName: "deploy.yml",
+		Ref:              "main",
+		ClientPayload:    map[string]interface{}{},
+	}
+	return fake, config
+}
+
+// newDeployFake returns a fake with the Deploy workflow, whose run names
+// carry the distinct ID, and a config that dispatches it with a fixed
+// distinct ID and polls quickly. Fields set on wf, such as Conclusion or
+// QueueTime, are kept.
+func newDeployFake(t *testing.T, wf fakegh.Workflow) (*fakegh.Server, *Config) {
+	t.Helper()
+	wf.Name, wf.Path = "Deploy", "deploy.yml"
+	if wf.RunName == "" {
+		wf.RunName = "Deploy ${{ inputs.distinct_id }}"
+	}
+	fake, config := newFakeGitHub(t, &fakegh.Repo{Owner: "owner", Name: "repo", Workflows: []*fakegh.Workflow{&wf}})
+	config.DistinctIDName = "distinct_id"
+	setDistinctID(config, "K7M4N2P9QRST")
+	config.WaitInterval = 10 * time.Millisecond
+	config.TriggerTimeout = 5 * time.Second
+	return fake, config
+}
+
+// setDistinctID makes config dispatch with distinct ID id.
+func setDistinctID(config *Config, id string) {
+	config.DistinctID = id
+	config.ClientPayload[config.DistinctIDName] = id
+}
+
+func TestDryRun_DoesNotDispatch(t *testing.T) {
+	sink := newJSONFileSink(filepath.Join(t.TempDir(), "outputs.json"))
+	outputSinks = []OutputSink{sink}
+	defer func() { outputSinks = []OutputSink{githubOutputSink{}} }()
+
+	fake, config := newFakeGitHub(t, &fakegh.Repo{
+		Owner:     "owner",
+		Name:      "repo",
+		Workflows: []*fakegh.Workflow{{Name: "Deploy", Path: "deploy.yml", Inputs: []string{"env"}}},
+	})
+	config.ClientPayload["env"] = "prod"
+	config.ValidateInputs = true
+
+	if err := dryRun(context.Background(), config); err != nil {
+		t.Fatalf("dryRun failed: %v", err)
+	}
+	if runs := fake.Runs(); len(runs) != 0 {
+		t.Errorf("expected no runs to be dispatched, got %d", len(runs))
+	}
+	want := `{"body":{"inputs":{"env":"prod"},"ref":"main"},"method":"POST","url":"` + config.GitHubAPIURL + `/repos/owner/repo/actions/workflows/deploy.yml/dispatches"}`
+	if got := sink.values["dispatch_request"]; got != want {
+		t.Errorf("unexpected dispatch_request:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestDryRun_ReportsProblems(t *testing.T) {
+	tests := []struct {
+		name string
+		repo *fakegh.Repo
+		ref  string
+		kind trigwait.ErrorKind
+	}{
+		{
+			name: "missing ref",
+			repo: &fakegh.Repo{Owner: "owner", Name: "repo", Workflows: []*fakegh.Workflow{{Name: "Deploy", Path: "deploy.yml", Inputs: []string{"env"}}}},
+			ref:  "nope",
+			kind: trigwait.KindDispatchRejected,
+		},
+		{
+			name: "undeclared input",
+			repo: &fakegh.Repo{Owner: "owner", Name: "repo", Workflows: []*fakegh.Workflow{{Name: "Deploy", Path: "deploy.yml", Inputs: []string{}}}},
+			ref:  "main",
+			kind: trigwait.KindDispatchRejected,
+		},
+		{
+			name: "read-only token",
+			repo: &fakegh.Repo{Owner: "owner", Name: "repo", ReadOnly: true, Workflows: []*fakegh.Workflow{{Name: "Deploy", Path: "deploy.yml", Inputs: []string{"env"}}}},
+			ref:  "main",
+			kind: trigwait.KindAuth,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, config := newFakeGitHub(t, tt.repo)
+			config.Ref = tt.ref
+			config.ClientPayload["env"] = "prod"
+			config.ValidateInputs = true
+
+			err := dryRun(context.Background(), config)
+			if kind := trigwait.KindOf(err); kind != tt.kind {
+				t.Errorf("expected kind %s, got %s (%v)", tt.kind, kind, err)
+			}
+			if runs := fake.Runs(); len(runs) != 0 {
+				t.Errorf("expected no runs to be dispatched, got %d", len(runs))
+			}
+		})
+	}
+}
+
+func TestTriggerWorkflow_DiagnosesRejectedDispatch(t *testing.T) {
+	_, config := newFakeGitHub(t, &fakegh.Repo{
+		Owner:     "owner",
+		Name:      "repo",
+		Workflows: []*fakegh.Workflow{{Name: "Deploy", Path: "deploy.yml", NoDispatch: true}},
+	})
+
+	_, err := triggerWorkflow(context.Background(), config)
+	if kind := trigwait.KindOf(err); kind != trigwait.KindDispatchRejected {
+		t.Fatalf("expected kind %s, got %s (%v)", trigwait.KindDispatchRejected, kind, err)
+	}
+	if !contains(err.Error(), "does not declare a workflow_dispatch trigger") {
+		t.Errorf("expected a targeted error, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+// Command fakegh serves a fake GitHub Actions API for local testing of
+// workflow-trigwait without touching real repositories.
+//
+//	fakegh -addr :8080 -config repos.json
+//	GITHUB_API_URL=http://localhost:8080 ...
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "address to listen on")
+	configPath := flag.String("config", "", "JSON file describing repositories and workflows")
+	token := flag.String("token", "", "only accept this bearer token (default: accept any)")
+	rateLimit := flag.Int("rate-limit", 0, "requests allowed per hour (0 = unlimited)")
+	flag.Parse()
+
+	config := &fakegh.Config{}
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			log.Fatalf("failed to read config: %v", err)
+		}
+		if err := json.Unmarshal(data, config); err != nil {
+			log.Fatalf("failed to parse config: %v", err)
+		}
+	} else {
+		// A small default repository so the binary is useful without a config
+		config.Repos = []*fakegh.Repo{{
+			Owner: "octo",
+			Name:  "demo",
+			Workflows: []*fakegh.Workflow{{
+				Name:      "Deploy",
+				Path:      "deploy.yml",
+				RunName:   "Deploy ${{ inputs.distinct_id }}",
+				QueueTime: fakegh.Duration(5 * time.Second),
+				RunTime:   fakegh.Duration(20 * time.Second),
+			}},
+		}}
+	}
+
+	server := fakegh.New(config)
+	server.Token = *token
+	server.RateLimit = *rateLimit
+
+	for _, repo := range config.Repos {
+		for _, wf := range repo.Workflows {
+			fmt.Printf("Serving %s/%s %s (%s)\n", repo.Owner, repo.Name, wf.Path, wf.Name)
+		}
+	}
+	fmt.Printf("Listening on http://%s — set GITHUB_API_URL to this address\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
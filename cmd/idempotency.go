@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// idempotencyKey resolves the idempotency_key input. "auto" derives a key
+// that is the same for every attempt of the calling step: the workflow run
+// ID, the job and the step, plus a hash of what config dispatches.
+func idempotencyKey(value string, config *Config) (string, error) {
+	if value != "auto" {
+		return sanitizeKey(value), nil
+	}
+	runID := os.Getenv("GITHUB_RUN_ID")
+	if runID == "" {
+		return "", fmt.Errorf("idempotency_key auto requires GITHUB_RUN_ID; set an explicit key outside GitHub Actions")
+	}
+	parts := []string{runID}
+	for _, name := range []string{"GITHUB_JOB", "GITHUB_ACTION"} {
+		if v := os.Getenv(name); v != "" {
+			parts = append(parts, v)
+		}
+	}
+	parts = append(parts, dispatchKey(config))
+	return sanitizeKey(strings.Join(parts, "-")), nil
+}
+
+// dispatchKey hashes the target workflow, the ref and the inputs. Legs of a
+// calling matrix job share the run ID, job and step, so this keeps their
+// keys apart as long as they dispatch something different.
+func dispatchKey(config *Config) string {
+	target := strings.Join([]string{config.Owner, config.Repo, config.WorkflowFileName, config.Ref}, "\n")
+	sum := sha256.Sum256([]byte(target + "\n" + inputsHash(config.ClientPayload, config.DistinctIDName)))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// sanitizeKey replaces characters that would be awkward in a run name, so
+// the key reads the same in the display title as in the logs.
+func sanitizeKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '-'
+	}, strings.TrimSpace(key))
+}
+
+// idempotencyWindow is how far back reattachRun looks for a run dispatched
+// with the idempotency key. GitHub allows re-running a workflow run for 30
+// days.
+const idempotencyWindow = 30 * 24 * time.Hour
+
+// reattachRun looks for a run already dispatched with the idempotency key,
+// for example by an earlier attempt of the calling workflow, and returns its
+// ID, or 0 when the workflow has to be dispatched.
+func reattachRun(ctx context.Context, config *Config) (int64, error) {
+	if config.IdempotencyKey == "" {
+		return 0, nil
+	}
+	runID, err := newClient(config).FindRunByDistinctID(ctx, dispatchFor(config), time.Now().Add(-idempotencyWindow))
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up idempotency key %s: %w", config.IdempotencyKey, err)
+	}
+	if runID == 0 {
+		return 0, nil
+	}
+	url := newClient(config).RunURL(runID)
+	logf(trigwait.LevelInfo, "🔁 Run #%d was already dispatched with idempotency key %s; reattaching", runID, config.IdempotencyKey)
+	logf(trigwait.LevelInfo, "   URL: %s", url)
+	config.result.runFound(runID, url)
+	return runID, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func TestTriggerWorkflow_IdempotencyKey(t *testing.T) {
+	fake, config := newDeployFake(t, fakegh.Workflow{})
+	config.IdempotencyKey = "4242-release-deploy"
+	setDistinctID(config, config.IdempotencyKey)
+
+	first, err := triggerWorkflow(context.Background(), config)
+	if err != nil {
+		t.Fatalf("first attempt failed: %v", err)
+	}
+	second, err := triggerWorkflow(context.Background(), config)
+	if err != nil {
+		t.Fatalf("second attempt failed: %v", err)
+	}
+	if second != first {
+		t.Errorf("expected the second attempt to reattach to run %d, got %d", first, second)
+	}
+	if runs := fake.Runs(); len(runs) != 1 {
+		t.Errorf("expected 1 dispatched run, got %d", len(runs))
+	}
+
+	// Another key dispatches its own run
+	config.IdempotencyKey = "4243-release-deploy"
+	setDistinctID(config, config.IdempotencyKey)
+	if third, err := triggerWorkflow(context.Background(), config); err != nil || third == first {
+		t.Errorf("expected a new run for another key, got %d (%v)", third, err)
+	}
+}
+
+func TestTriggerWorkflow_IdempotencyKeyBusyWorkflow(t *testing.T) {
+	fake, config := newDeployFake(t, fakegh.Workflow{})
+	config.IdempotencyKey = "4242-release-deploy"
+	setDistinctID(config, config.IdempotencyKey)
+
+	ctx := context.Background()
+	first, err := triggerWorkflow(ctx, config)
+	if err != nil {
+		t.Fatalf("first attempt failed: %v", err)
+	}
+	// More runs than fit on one page are dispatched after it
+	client := newClient(config)
+	for i := 0; i < 120; i++ {
+		d := trigwait.Dispatch{Workflow: "deploy.yml", Ref: "main", Inputs: map[string]interface{}{"distinct_id": fmt.Sprintf("other-%d", i)}}
+		if err := client.Dispatch(ctx, d); err != nil {
+			t.Fatalf("dispatch %d failed: %v", i, err)
+		}
+	}
+
+	second, err := triggerWorkflow(ctx, config)
+	if err != nil {
+		t.Fatalf("second attempt failed: %v", err)
+	}
+	if second != first {
+		t.Errorf("expected the second attempt to reattach to run %d, got %d", first, second)
+	}
+	if runs := fake.Runs(); len(runs) != 121 {
+		t.Errorf("expected 121 dispatched runs, got %d", len(runs))
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	os.Setenv("GITHUB_RUN_ID", "4242")
+	os.Setenv("GITHUB_JOB", "release")
+	os.Setenv("GITHUB_ACTION", "__PhuongTMR_workflow-trigwait")
+	defer func() {
+		os.Unsetenv("GITHUB_RUN_ID")
+		os.Unsetenv("GITHUB_JOB")
+		os.Unsetenv("GITHUB_ACTION")
+	}()
+
+	config := &Config{
+		Owner:            "owner",
+		Repo:             "repo",
+		WorkflowFileName: "deploy.yml",
+		Ref:              "main",
+		DistinctIDName:   "distinct_id",
+		ClientPayload:    map[string]interface{}{"env": "prod"},
+	}
+	tests := map[string]string{
+		"":                 "",
+		"deploy v1.2/prod": "deploy-v1.2-prod",
+	}
+	for value, want := range tests {
+		if got, err := idempotencyKey(value, config); err != nil || got != want {
+			t.Errorf("idempotencyKey(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+
+	auto, err := idempotencyKey("auto", config)
+	if err != nil || !strings.HasPrefix(auto, "4242-release-__PhuongTMR_workflow-trigwait-") {
+		t.Fatalf("unexpected auto key %q (%v)", auto, err)
+	}
+	if again, _ := idempotencyKey("auto", config); again != auto {
+		t.Errorf("expected the same key on every attempt, got %q and %q", auto, again)
+	}
+	// Legs of a calling matrix job share the run, job and step but dispatch
+	// different inputs
+	leg := *config
+	leg.ClientPayload = map[string]interface{}{"env": "staging"}
+	if other, _ := idempotencyKey("auto", &leg); other == auto {
+		t.Errorf("expected matrix legs with different inputs to get different keys, both got %q", auto)
+	}
+	leg.ClientPayload = config.ClientPayload
+	leg.Ref = "release"
+	if other, _ := idempotencyKey("auto", &leg); other == auto {
+		t.Errorf("expected a different ref to change the key, both got %q", auto)
+	}
+
+	os.Unsetenv("GITHUB_RUN_ID")
+	if _, err := idempotencyKey("auto", config); err == nil {
+		t.Error("expected error for auto outside GitHub Actions")
+	}
+}
@@ -1,99 +1,292 @@
 package main
 
 import (
-	"bytes"
-	"crypto/rand"
-	"encoding/hex"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/internal/otlp"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
 )
 
+// reportTimeout bounds each request made by the features that only report
+// on the step: notifications, commit statuses and check runs, metrics and
+// trace exports. Their failures are logged as warnings and never fail the
+// step, and an unresponsive endpoint holds it up for at most this long.
+const reportTimeout = 10 * time.Second
+
 type Config struct {
-	Owner            string
-	Repo             string
-	GitHubToken      string
-	WorkflowFileName string
-	Ref              string
-	ClientPayload    map[string]interface{}
-	WaitInterval     time.Duration
-	TriggerTimeout   time.Duration
-	PropagateFailure bool
-	TriggerWorkflow  bool
-	WaitWorkflow     bool
-	GitHubAPIURL     string
-	GitHubServerURL  string
-	DistinctID       string
-	DistinctIDName   string
+	Owner              string
+	Repo               string
+	GitHubToken        string
+	WorkflowFileName   string
+	Ref                string
+	RefMode            trigwait.RefMode
+	ClientPayload      map[string]interface{}
+	WaitInterval       time.Duration
+	TriggerTimeout     time.Duration
+	WaitTimeout        time.Duration
+	PropagateFailure   bool
+	Policy             trigwait.Policy
+	SLO                trigwait.SLO
+	TriggerWorkflow    bool
+	Concurrency        trigwait.ConcurrencyPolicy
+	MatchInputs        bool
+	WaitWorkflow       bool
+	DryRun             bool
+	Preflight          bool
+	ValidateInputs     bool
+	GitHubAPIURL       string
+	GitHubServerURL    string
+	DistinctID         string
+	DistinctIDName     string
+	DistinctIDFormat   trigwait.IDFormat
+	IdempotencyKey     string
+	WebhookListen      string
+	WebhookRelayURL    string
+	WebhookSecret      string
+	WebhookFallback    time.Duration
+	OutputSinks        []OutputSink
+	ResultFile         string
+	StateFile          string
+	MetricsTextfile    string
+	MetricsPushgateway string
+	MetricsStatsD      string
+	OTLPEndpoint       string
+	OTLPHeaders        map[string]string
+	TraceParentName    string
+	Notifier           *Notifier
+	StatusReporter     *StatusReporter
+	Reconciler         *Reconciler
+	PipelineFile       string
+	Pipeline           *Pipeline
+	LogFormat          trigwait.Format
+	LogLevel           trigwait.Level
+	InActions          bool
+
+	result *Result
+	// stage is the name of the pipeline stage the configuration describes.
+	stage  string
+	events trigwait.RunEvents
+	cache  *trigwait.ResponseCache
+	// metrics is set when any metrics destination is configured.
+	metrics *metrics
+	// tracer and traceSpan are set when otlp_endpoint is configured;
+	// traceParent is passed to the downstream run as traceparent_name.
+	tracer      *otlp.Tracer
+	traceSpan   trigwait.Span
+	traceParent string
+	// runTime is how long the run was queued and in progress.
+	runTime time.Duration
 }
 
-type WorkflowRun struct {
-	ID           int64  `json:"id"`
-	Status       string `json:"status"`
-	Conclusion   string `json:"conclusion"`
-	CreatedAt    string `json:"created_at"`
-	DisplayTitle string `json:"display_title"`
+// logger receives all progress messages. main replaces it with a logger
+// configured from the log_format and log_level inputs.
+var logger trigwait.Logger = trigwait.NewTextLogger(os.Stdout, trigwait.FormatPretty, trigwait.LevelInfo, os.Getenv("GITHUB_ACTIONS") == "true")
+
+func logf(level trigwait.Level, format string, args ...interface{}) {
+	logger.Log(level, fmt.Sprintf(format, args...))
 }
 
-type WorkflowRunsResponse struct {
-	WorkflowRuns []WorkflowRun `json:"workflow_runs"`
+// exitCodes maps error kinds to the process exit codes documented in the
+// README. Errors of unknown kind exit with 1.
+var exitCodes = map[trigwait.ErrorKind]int{
+	trigwait.KindConfig:              2,
+	trigwait.KindAuth:                3,
+	trigwait.KindNotFound:            4,
+	trigwait.KindDispatchRejected:    5,
+	trigwait.KindDiscoveryTimeout:    6,
+	trigwait.KindWaitTimeout:         7,
+	trigwait.KindDownstreamFailed:    8,
+	trigwait.KindDownstreamCancelled: 9,
+	trigwait.KindSLOBreached:         10,
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if code, ok := exitCodes[trigwait.KindOf(err)]; ok {
+		return code
+	}
+	return 1
 }
 
 func main() {
+	start := time.Now()
 	config, err := loadConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v", err)
-		os.Exit(1)
+		err = &trigwait.Error{Kind: trigwait.KindConfig, Err: err}
+		logf(trigwait.LevelError, "Error: %v", err)
+		setOutput("error_kind", string(trigwait.KindConfig))
+		result := &Result{SchemaVersion: resultSchemaVersion, StatusTimeline: []StatusChange{}, Jobs: []JobResult{}}
+		result.finish(err)
+		result.write(os.Getenv("INPUT_RESULT_FILE"))
+		os.Exit(exitCode(err))
+	}
+
+	outputSinks = config.OutputSinks
+	logger = trigwait.NewTextLogger(os.Stdout, config.LogFormat, config.LogLevel, config.InActions)
+	if (config.ResultFile != "" || config.metrics != nil) && config.Pipeline == nil && config.Reconciler == nil {
+		config.result = newResult(config)
 	}
 
 	// Print header
-	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	logf(trigwait.LevelInfo, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = startTracing(ctx, config, start)
+
+	if config.Reconciler != nil {
+		if err := reconcile(ctx, config); err != nil {
+			logf(trigwait.LevelError, "❌ Error: %v", err)
+			exit(config, err)
+		}
+		exit(config, nil)
+	}
+
+	if config.Pipeline != nil {
+		if config.DryRun {
+			exit(config, dryRunPipeline(ctx, config))
+		}
+		if err := startWebhook(ctx, config); err != nil {
+			logf(trigwait.LevelError, "❌ Error: %v", err)
+			exit(config, err)
+		}
+		if err := runPipeline(ctx, config); err != nil {
+			logf(trigwait.LevelError, "❌ Error: %v", err)
+			exit(config, err)
+		}
+		exit(config, nil)
+	}
+
+	if config.TriggerWorkflow || config.DryRun {
+		if err := resolveWorkflow(ctx, config); err != nil {
+			logf(trigwait.LevelError, "❌ Error: %v", err)
+			exit(config, err)
+		}
+		if err := resolveRef(ctx, config); err != nil {
+			logf(trigwait.LevelError, "❌ Error: %v", err)
+			exit(config, err)
+		}
+	}
+
+	if config.DryRun {
+		if err := dryRun(ctx, config); err != nil {
+			logf(trigwait.LevelError, "❌ Error: %v", err)
+			exit(config, err)
+		}
+		exit(config, nil)
+	}
+
+	// Start receiving events before dispatching so none are missed
+	if config.WaitWorkflow {
+		if err := startWebhook(ctx, config); err != nil {
+			logf(trigwait.LevelError, "❌ Error: %v", err)
+			exit(config, err)
+		}
+	}
 
 	var runID int64
 	if config.TriggerWorkflow {
-		runID, err = triggerWorkflow(config)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "❌ Error: %v", err)
-			os.Exit(1)
+		if runID = resumeRun(config); runID == 0 {
+			runID, err = triggerWorkflow(ctx, config)
+			if err != nil {
+				logf(trigwait.LevelError, "❌ Error: %v", err)
+				exit(config, err)
+			}
+			if config.WaitWorkflow && runID > 0 {
+				saveState(config, runID)
+			}
 		}
 	} else {
-		fmt.Println("⏭ Skipping workflow trigger")
+		logf(trigwait.LevelInfo, "⏭ Skipping workflow trigger")
+	}
+
+	if runID > 0 {
+		statusStarted(ctx, config, runID)
 	}
 
 	if config.WaitWorkflow && runID > 0 {
-		err = waitForWorkflow(config, runID)
+		err = waitForWorkflow(ctx, config, runID)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "❌ Error: %v", err)
-			os.Exit(1)
+			logf(trigwait.LevelError, "❌ Error: %v", err)
+			exit(config, err)
 		}
 	} else if runID > 0 {
 		// Set outputs even when not waiting
-		workflowURL := fmt.Sprintf("%s/%s/%s/actions/runs/%d", config.GitHubServerURL, config.Owner, config.Repo, runID)
+		workflowURL := newClient(config).RunURL(runID)
 		setOutput("workflow_id", strconv.FormatInt(runID, 10))
 		setOutput("workflow_url", workflowURL)
-		fmt.Printf("⏭ Skipping wait (workflow started)")
-		fmt.Printf("   URL: %s", workflowURL)
+		logf(trigwait.LevelInfo, "⏭ Skipping wait (workflow started)")
+		logf(trigwait.LevelInfo, "   URL: %s", workflowURL)
+	}
+
+	exit(config, nil)
+}
+
+// exit records the error kind, writes the result document if one was
+// requested, and terminates the process with the exit code for err.
+func exit(config *Config, err error) {
+	if err != nil {
+		setOutput("error_kind", string(trigwait.KindOf(err)))
+	}
+	reportRequests(config)
+	config.result.finish(err)
+	reportMetrics(config, err)
+	finishTracing(config, err)
+	if werr := config.result.write(config.ResultFile); werr != nil {
+		logf(trigwait.LevelWarn, "⚠ Failed to write result: %v", werr)
+	}
+	os.Exit(exitCode(err))
+}
+
+// reportRequests logs how many API requests conditional requests saved.
+func reportRequests(config *Config) {
+	if config.cache == nil {
+		return
+	}
+	if requests, notModified := config.cache.Stats(); notModified > 0 {
+		logf(trigwait.LevelInfo, "📊 %d API requests, %d answered from cache (304 Not Modified, not counted against the rate limit)", requests, notModified)
 	}
 }
 
 func loadConfig() (*Config, error) {
 	config := &Config{
-		Owner:            os.Getenv("INPUT_OWNER"),
-		Repo:             os.Getenv("INPUT_REPO"),
-		GitHubToken:      os.Getenv("INPUT_GITHUB_TOKEN"),
-		WorkflowFileName: os.Getenv("INPUT_WORKFLOW_FILE_NAME"),
-		Ref:              getEnvOrDefault("INPUT_REF", "main"),
-		PropagateFailure: getEnvBool("INPUT_PROPAGATE_FAILURE", true),
-		TriggerWorkflow:  getEnvBool("INPUT_TRIGGER_WORKFLOW", true),
-		WaitWorkflow:     getEnvBool("INPUT_WAIT_WORKFLOW", true),
-		GitHubAPIURL:     getEnvOrDefault("GITHUB_API_URL", "https://api.github.com"),
-		GitHubServerURL:  getEnvOrDefault("GITHUB_SERVER_URL", "https://github.com"),
-		DistinctIDName:   os.Getenv("INPUT_DISTINCT_ID_NAME"),
+		Owner:              os.Getenv("INPUT_OWNER"),
+		Repo:               os.Getenv("INPUT_REPO"),
+		GitHubToken:        os.Getenv("INPUT_GITHUB_TOKEN"),
+		WorkflowFileName:   os.Getenv("INPUT_WORKFLOW_FILE_NAME"),
+		Ref:                getEnvOrDefault("INPUT_REF", "main"),
+		PropagateFailure:   getEnvBool("INPUT_PROPAGATE_FAILURE", true),
+		TriggerWorkflow:    getEnvBool("INPUT_TRIGGER_WORKFLOW", true),
+		WaitWorkflow:       getEnvBool("INPUT_WAIT_WORKFLOW", true),
+		DryRun:             getEnvBool("INPUT_DRY_RUN", false),
+		Preflight:          getEnvBool("INPUT_PREFLIGHT", false),
+		ValidateInputs:     getEnvBool("INPUT_VALIDATE_INPUTS", false),
+		MatchInputs:        getEnvBool("INPUT_CONCURRENCY_MATCH_INPUTS", false),
+		GitHubAPIURL:       getEnvOrDefault("GITHUB_API_URL", "https://api.github.com"),
+		GitHubServerURL:    getEnvOrDefault("GITHUB_SERVER_URL", "https://github.com"),
+		DistinctIDName:     os.Getenv("INPUT_DISTINCT_ID_NAME"),
+		WebhookListen:      os.Getenv("INPUT_WEBHOOK_LISTEN"),
+		WebhookRelayURL:    os.Getenv("INPUT_WEBHOOK_RELAY_URL"),
+		WebhookSecret:      os.Getenv("INPUT_WEBHOOK_SECRET"),
+		ResultFile:         os.Getenv("INPUT_RESULT_FILE"),
+		StateFile:          os.Getenv("INPUT_STATE_FILE"),
+		MetricsTextfile:    os.Getenv("INPUT_METRICS_TEXTFILE"),
+		MetricsPushgateway: os.Getenv("INPUT_METRICS_PUSHGATEWAY"),
+		MetricsStatsD:      os.Getenv("INPUT_METRICS_STATSD"),
+		OTLPEndpoint:       getEnvOrDefault("INPUT_OTLP_ENDPOINT", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")),
+		TraceParentName:    os.Getenv("INPUT_TRACEPARENT_NAME"),
+		PipelineFile:       os.Getenv("INPUT_PIPELINE_FILE"),
+		InActions:          os.Getenv("GITHUB_ACTIONS") == "true",
 	}
 
 	// Parse durations
@@ -103,6 +296,51 @@ func loadConfig() (*Config, error) {
 	triggerTimeout, _ := strconv.Atoi(getEnvOrDefault("INPUT_TRIGGER_TIMEOUT", "120"))
 	config.TriggerTimeout = time.Duration(triggerTimeout) * time.Second
 
+	waitTimeout, _ := strconv.Atoi(getEnvOrDefault("INPUT_WAIT_TIMEOUT", "0"))
+	config.WaitTimeout = time.Duration(waitTimeout) * time.Second
+
+	maxQueueTime, _ := strconv.Atoi(getEnvOrDefault("INPUT_MAX_QUEUE_TIME", "0"))
+	config.SLO.MaxQueueTime = time.Duration(maxQueueTime) * time.Second
+
+	maxRunTime, _ := strconv.Atoi(getEnvOrDefault("INPUT_MAX_RUN_TIME", "0"))
+	config.SLO.MaxRunTime = time.Duration(maxRunTime) * time.Second
+
+	webhookFallback, _ := strconv.Atoi(getEnvOrDefault("INPUT_WEBHOOK_FALLBACK_INTERVAL", "300"))
+	config.WebhookFallback = time.Duration(webhookFallback) * time.Second
+
+	// Parse ref mode; modes other than explicit only fall back to ref when
+	// it was actually given
+	refMode, err := trigwait.ParseRefMode(getEnvOrDefault("INPUT_REF_MODE", "explicit"))
+	if err != nil {
+		return nil, err
+	}
+	config.RefMode = refMode
+	if refMode != trigwait.RefExplicit {
+		config.Ref = os.Getenv("INPUT_REF")
+	}
+
+	concurrency, err := trigwait.ParseConcurrencyPolicy(getEnvOrDefault("INPUT_CONCURRENCY", "allow"))
+	if err != nil {
+		return nil, err
+	}
+	config.Concurrency = concurrency
+
+	sloAction, err := trigwait.ParseSLOAction(getEnvOrDefault("INPUT_SLO_ACTION", "fail"))
+	if err != nil {
+		return nil, err
+	}
+	config.SLO.Action = sloAction
+
+	// Parse failure propagation policy
+	config.Policy = trigwait.DefaultPolicy()
+	if accepted := getEnvList("INPUT_ACCEPTED_CONCLUSIONS"); len(accepted) > 0 {
+		if err := trigwait.ValidateConclusions(accepted); err != nil {
+			return nil, fmt.Errorf("invalid accepted_conclusions: %w", err)
+		}
+		config.Policy.AcceptedConclusions = accepted
+	}
+	config.Policy.NonBlockingJobs = getEnvList("INPUT_NON_BLOCKING_JOBS")
+
 	// Parse client payload
 	payloadStr := os.Getenv("INPUT_CLIENT_PAYLOAD")
 	if payloadStr != "" {
@@ -117,28 +355,170 @@ func loadConfig() (*Config, error) {
 	config.ClientPayload = removeEmptyValues(config.ClientPayload)
 
 	// Generate distinct_id for correlating the triggered workflow run (only if enabled)
+	if config.IdempotencyKey, err = idempotencyKey(os.Getenv("INPUT_IDEMPOTENCY_KEY"), config); err != nil {
+		return nil, err
+	}
+	if config.DistinctIDFormat, err = distinctIDFormat(); err != nil {
+		return nil, err
+	}
 	if config.DistinctIDName != "" {
-		config.DistinctID = generateDistinctID()
+		if config.IdempotencyKey != "" {
+			// Every attempt dispatches with the same ID, so it can find the run of an earlier one
+			config.DistinctID = config.IdempotencyKey
+		} else if config.DistinctID, err = trigwait.NewDistinctID(config.DistinctIDFormat); err != nil {
+			return nil, err
+		}
+		if config.MatchInputs {
+			// Lets concurrent dispatches with the same inputs recognise each other
+			config.DistinctID = inputsKey(config) + "-" + config.DistinctID
+		}
 		config.ClientPayload[config.DistinctIDName] = config.DistinctID
 	}
 
-	// Validate required fields
-	if config.Owner == "" {
-		return nil, fmt.Errorf("owner is a required argument")
+	// Parse logging options; step debug logging enables debug messages
+	logFormat, err := trigwait.ParseFormat(getEnvOrDefault("INPUT_LOG_FORMAT", "pretty"))
+	if err != nil {
+		return nil, err
+	}
+	config.LogFormat = logFormat
+
+	defaultLevel := "info"
+	if os.Getenv("RUNNER_DEBUG") == "1" {
+		defaultLevel = "debug"
+	}
+	logLevel, err := trigwait.ParseLevel(getEnvOrDefault("INPUT_LOG_LEVEL", defaultLevel))
+	if err != nil {
+		return nil, err
+	}
+	config.LogLevel = logLevel
+
+	// Parse tracing options
+	if config.OTLPHeaders, err = otlp.ParseHeaders(getEnvOrDefault("INPUT_OTLP_HEADERS", os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))); err != nil {
+		return nil, fmt.Errorf("invalid otlp_headers: %w", err)
 	}
-	if config.Repo == "" {
-		return nil, fmt.Errorf("repo is a required argument")
+
+	// Parse notification options
+	if config.Notifier, err = newNotifier(os.Getenv("INPUT_NOTIFY_URL"), os.Getenv("INPUT_NOTIFY_TYPE"), os.Getenv("INPUT_NOTIFY_TEMPLATE"), getEnvList("INPUT_NOTIFY_ON")); err != nil {
+		return nil, err
 	}
+
+	// Parse status reporting options
+	statusToken := getEnvOrDefault("INPUT_STATUS_TOKEN", config.GitHubToken)
+	if config.StatusReporter, err = newStatusReporter(os.Getenv("INPUT_REPORT_STATUS"), os.Getenv("INPUT_STATUS_CONTEXT"), statusToken); err != nil {
+		return nil, err
+	}
+	if config.Reconciler, err = newReconciler(getEnvBool("INPUT_RECONCILE", false), getEnvList("INPUT_RECONCILE_REFS"), statusToken); err != nil {
+		return nil, err
+	}
+
+	// Parse output sinks
+	sinks, err := parseOutputSinks(getEnvOrDefault("INPUT_OUTPUT_SINKS", "github"), config.LogFormat)
+	if err != nil {
+		return nil, err
+	}
+	config.OutputSinks = sinks
+
+	// Validate required fields
 	if config.GitHubToken == "" {
 		return nil, fmt.Errorf("github_token is required")
 	}
-	if config.WorkflowFileName == "" {
-		return nil, fmt.Errorf("workflow_file_name is required")
+	if config.PipelineFile != "" {
+		// Stages name their own targets, defaulting to these inputs
+		pipeline, err := loadPipeline(config.PipelineFile, config)
+		if err != nil {
+			return nil, err
+		}
+		config.Pipeline = pipeline
+	} else if config.Reconciler == nil {
+		// Reconciling reads the downstream repositories from the statuses
+		if config.Owner == "" {
+			return nil, fmt.Errorf("owner is a required argument")
+		}
+		if config.Repo == "" {
+			return nil, fmt.Errorf("repo is a required argument")
+		}
+		if config.WorkflowFileName == "" {
+			return nil, fmt.Errorf("workflow_file_name is required")
+		}
+	}
+
+	// Expand the matrix into one dispatch per combination
+	if spec := os.Getenv("INPUT_MATRIX"); spec != "" {
+		if config.Pipeline != nil {
+			return nil, fmt.Errorf("matrix cannot be combined with pipeline_file")
+		}
+		if config.DistinctIDName == "" {
+			return nil, fmt.Errorf("matrix requires distinct_id_name to tell the dispatched runs apart")
+		}
+		pipeline, err := matrixPipeline(config, spec)
+		if err != nil {
+			return nil, err
+		}
+		config.Pipeline = pipeline
+	}
+	if config.Pipeline != nil && (config.Concurrency != trigwait.ConcurrencyAllow || config.MatchInputs) {
+		// Stages of a matrix share a workflow and would wait on or cancel each other
+		return nil, fmt.Errorf("concurrency and concurrency_match_inputs cannot be combined with pipeline_file or matrix")
+	}
+	if config.MatchInputs && config.DistinctIDName == "" {
+		return nil, fmt.Errorf("concurrency_match_inputs requires distinct_id_name")
+	}
+	if (config.WebhookListen != "" || config.WebhookRelayURL != "") && config.WebhookSecret == "" {
+		return nil, fmt.Errorf("webhook_secret is required with webhook_listen or webhook_relay_url")
+	}
+	if config.IdempotencyKey != "" {
+		if config.DistinctIDName == "" {
+			return nil, fmt.Errorf("idempotency_key requires distinct_id_name")
+		}
+		if config.Pipeline != nil {
+			return nil, fmt.Errorf("idempotency_key cannot be combined with pipeline_file or matrix")
+		}
+	}
+	if config.MetricsTextfile != "" || config.MetricsPushgateway != "" || config.MetricsStatsD != "" {
+		if config.Pipeline != nil {
+			return nil, fmt.Errorf("metrics cannot be combined with pipeline_file or matrix")
+		}
+		if config.Reconciler != nil {
+			return nil, fmt.Errorf("metrics cannot be combined with reconcile")
+		}
+		config.metrics = &metrics{}
+	}
+	if config.Notifier != nil && config.Pipeline != nil {
+		return nil, fmt.Errorf("notify_url cannot be combined with pipeline_file or matrix")
+	}
+	if config.Reconciler != nil && config.Pipeline != nil {
+		return nil, fmt.Errorf("reconcile cannot be combined with pipeline_file or matrix")
+	}
+	if config.StatusReporter != nil && config.Pipeline != nil {
+		return nil, fmt.Errorf("report_status cannot be combined with pipeline_file or matrix")
+	}
+	if config.StateFile != "" && config.Pipeline != nil {
+		return nil, fmt.Errorf("state_file cannot be combined with pipeline_file or matrix")
 	}
 
 	return config, nil
 }
 
+// distinctIDFormat reads the distinct_id_* inputs.
+func distinctIDFormat() (trigwait.IDFormat, error) {
+	var format trigwait.IDFormat
+	switch kind := getEnvOrDefault("INPUT_DISTINCT_ID_FORMAT", "random"); kind {
+	case "random":
+	case "uuid":
+		format.UUID = true
+	default:
+		return format, fmt.Errorf("invalid distinct_id_format %q: must be random or uuid", kind)
+	}
+	length, err := strconv.Atoi(getEnvOrDefault("INPUT_DISTINCT_ID_LENGTH", strconv.Itoa(trigwait.DefaultIDLength)))
+	if err != nil || length < 1 {
+		return format, fmt.Errorf("invalid distinct_id_length: must be a positive number")
+	}
+	format.Length = length
+	format.Alphabet = os.Getenv("INPUT_DISTINCT_ID_ALPHABET")
+	format.Prefix = sanitizeKey(os.Getenv("INPUT_DISTINCT_ID_PREFIX"))
+	return format, format.Validate()
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -154,6 +534,17 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return strings.ToLower(value) == "true"
 }
 
+// getEnvList splits a comma- or newline-separated variable, dropping empty items.
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(os.Getenv(key), func(r rune) bool { return r == ',' || r == '\n' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func removeEmptyValues(payload map[string]interface{}) map[string]interface{} {
 	cleaned := make(map[string]interface{})
 	for key, value := range payload {
@@ -182,278 +573,167 @@ func removeEmptyValues(payload map[string]interface{}) map[string]interface{} {
 	return cleaned
 }
 
-func generateDistinctID() string {
-	// Generate 6 random bytes
-	b := make([]byte, 6)
-	rand.Read(b)
-
-	// Use base32 encoding without padding for shorter, more readable IDs
-	// Results in ~10 characters (e.g., "K7M4N2P9QR")
-	encoded := strings.ToUpper(hex.EncodeToString(b))
-
-	// Take first 8 characters for even shorter ID
-	if len(encoded) > 8 {
-		return encoded[:8]
+// newClient builds a trigwait client from the action configuration.
+func newClient(config *Config) *trigwait.Client {
+	if config.cache == nil {
+		config.cache = trigwait.NewResponseCache()
+	}
+	opts := []trigwait.Option{
+		trigwait.WithAPIURL(config.GitHubAPIURL),
+		trigwait.WithServerURL(config.GitHubServerURL),
+		trigwait.WithWaitInterval(config.WaitInterval),
+		trigwait.WithTriggerTimeout(config.TriggerTimeout),
+		trigwait.WithWaitTimeout(config.WaitTimeout),
+		trigwait.WithLogger(logger),
+		trigwait.WithStatusHook(func(run *trigwait.WorkflowRun) {
+			config.result.recordStatus(run)
+			config.StatusReporter.recordStatus(run)
+		}),
+		trigwait.WithResponseCache(config.cache),
+		trigwait.WithSLO(config.SLO),
+	}
+	if config.stage != "" {
+		// Pipeline stages report through the pipeline report instead of outputs
+		opts = append(opts, trigwait.WithLogger(stageLogger(config.stage)))
+	} else {
+		opts = append(opts, trigwait.WithDurationsHook(func(queued, running time.Duration) {
+			setOutput("queue_duration", strconv.Itoa(int(queued.Seconds())))
+			setOutput("run_duration", strconv.Itoa(int(running.Seconds())))
+			config.result.recordDurations(queued, running)
+			config.runTime = queued + running
+		}))
 	}
-	return encoded
-}
-
-func triggerWorkflow(config *Config) (int64, error) {
-	startTime := time.Now()
-	deadline := startTime.Add(config.TriggerTimeout)
-
-	// Prepare dispatch payload
-	payload := map[string]interface{}{
-		"ref":    config.Ref,
-		"inputs": config.ClientPayload,
+	if config.tracer != nil {
+		opts = append(opts, trigwait.WithTracer(config.tracer))
 	}
-	payloadBytes, _ := json.Marshal(payload)
-
-	// Print compact header
-	fmt.Printf("🚀 Triggering %s/%s → %s @ %s", config.Owner, config.Repo, config.WorkflowFileName, config.Ref)
-	if config.DistinctID != "" {
-		fmt.Printf(" [%s]", config.DistinctID)
-		setOutput("distinct_id", config.DistinctID)
+	if config.metrics != nil {
+		opts = append(opts, trigwait.WithRequestHook(config.metrics.recordRequest), trigwait.WithRetryHook(config.metrics.recordRetry))
 	}
-	fmt.Println()
-	if len(config.ClientPayload) > 0 {
-		inputsJSON, _ := json.Marshal(config.ClientPayload)
-		fmt.Printf("   Inputs: %s\n", string(inputsJSON))
+	if config.events != nil {
+		opts = append(opts, trigwait.WithRunEvents(config.events, config.WebhookFallback))
 	}
+	return trigwait.New(config.Owner, config.Repo, config.GitHubToken, opts...)
+}
 
-	// Trigger the workflow
-	path := fmt.Sprintf("workflows/%s/dispatches", config.WorkflowFileName)
-	_, err := apiRequest(config, "POST", path, payloadBytes)
-	if err != nil {
-		return 0, fmt.Errorf("failed to trigger workflow: %w", err)
-	}
-
-	// Wait for the run to appear
-	retryInterval := config.WaitInterval
-	lastPrintTime := time.Now()
-	for {
-		if time.Now().After(deadline) {
-			return 0, fmt.Errorf("timeout: workflow run did not appear within %v", config.TriggerTimeout)
-		}
-
-		time.Sleep(retryInterval)
-
-		runID, err := findWorkflowRun(config, startTime)
-		if err != nil {
-			// Only print errors occasionally to avoid spam
-			if time.Since(lastPrintTime) > 10*time.Second {
-				fmt.Fprintf(os.Stderr, "\r⚠ Error checking runs (retrying...)")
-				lastPrintTime = time.Now()
-			}
-		}
-		if runID > 0 {
-			fmt.Printf("   ✓ Triggered run #%d", runID)
-			return runID, nil
-		}
-
-		// Show progress dot every 10 seconds
-		if time.Since(lastPrintTime) > 10*time.Second {
-			elapsed := time.Since(startTime).Round(time.Second)
-			fmt.Printf("\r   Finding run... %v", elapsed)
-			lastPrintTime = time.Now()
-		}
-
-		// Exponential backoff up to 60 seconds
-		retryInterval *= 2
-		if retryInterval > 60*time.Second {
-			retryInterval = 60 * time.Second
+// dispatchFor returns the dispatch described by the action configuration.
+func dispatchFor(config *Config) trigwait.Dispatch {
+	inputs := config.ClientPayload
+	if config.TraceParentName != "" && config.traceParent != "" {
+		// Added here rather than to client_payload so inputs_hash stays stable
+		inputs = make(map[string]interface{}, len(config.ClientPayload)+1)
+		for k, v := range config.ClientPayload {
+			inputs[k] = v
 		}
+		inputs[config.TraceParentName] = config.traceParent
+	}
+	return trigwait.Dispatch{
+		Workflow:   config.WorkflowFileName,
+		Ref:        config.Ref,
+		Inputs:     inputs,
+		DistinctID: config.DistinctID,
 	}
 }
 
-func findWorkflowRun(config *Config, startTime time.Time) (int64, error) {
-	// Build query with filters
-	query := fmt.Sprintf("event=workflow_dispatch&branch=%s&per_page=10", config.Ref)
-
-	path := fmt.Sprintf("workflows/%s/runs?%s", config.WorkflowFileName, query)
-	respBody, err := apiRequest(config, "GET", path, nil)
+func triggerWorkflow(ctx context.Context, config *Config) (int64, error) {
+	if config.DistinctID != "" {
+		setOutput("distinct_id", config.DistinctID)
+	}
+	if runID, err := reattachRun(ctx, config); runID > 0 || err != nil {
+		return runID, err
+	}
+	client := newClient(config)
+	checked, err := preflight(ctx, client, config)
 	if err != nil {
 		return 0, err
 	}
-
-	var response WorkflowRunsResponse
-	if err := json.Unmarshal(respBody, &response); err != nil {
-		return 0, fmt.Errorf("failed to parse response: %w", err)
+	if runID, proceed, err := guardConcurrency(ctx, client, config); !proceed || err != nil {
+		return runID, err
 	}
-
-	// Find a run created after startTime
-	for _, run := range response.WorkflowRuns {
-		createdAt, err := time.Parse(time.RFC3339, run.CreatedAt)
-		if err != nil {
-			continue
-		}
-		if createdAt.Unix() >= startTime.Unix() {
-			// If distinct_id is enabled, verify by checking display_title (run-name)
-			if config.DistinctID != "" {
-				if strings.Contains(run.DisplayTitle, config.DistinctID) {
-					return run.ID, nil
-				}
-			} else {
-				// Fall back to time-based matching
-				return run.ID, nil
-			}
-		}
-	}
-
-	return 0, nil
-}
-
-func waitForWorkflow(config *Config, runID int64) error {
-	workflowURL := fmt.Sprintf("%s/%s/%s/actions/runs/%d", config.GitHubServerURL, config.Owner, config.Repo, runID)
-
-	fmt.Printf("\n⏳ Waiting for workflow completion...\n")
-	fmt.Printf("   URL: %s\n", workflowURL)
-
-	setOutput("workflow_id", strconv.FormatInt(runID, 10))
-	setOutput("workflow_url", workflowURL)
-
-	startTime := time.Now()
-	lastStatus := ""
-	pollInterval := config.WaitInterval
-	lastPrintTime := time.Now()
-
-	// Poll for completion with adaptive intervals
-	for {
-		time.Sleep(pollInterval)
-
-		run, err := getWorkflowRun(config, runID)
-		if err != nil {
-			// Only show errors occasionally
-			if time.Since(lastPrintTime) > 10*time.Second {
-				fmt.Fprintf(os.Stderr, "\r⚠ Error fetching status (retrying...)")
-				lastPrintTime = time.Now()
-			}
-			continue
-		}
-
-		elapsed := time.Since(startTime).Round(time.Second)
-		setOutput("conclusion", run.Conclusion)
-
-		if run.Status == "completed" {
-			fmt.Printf("\r")
-			if run.Conclusion == "success" {
-				fmt.Printf("   ✅ Completed successfully in %v\n", elapsed)
-			} else {
-				fmt.Printf("   ❌ Failed with status: %s (duration: %v)\n", run.Conclusion, elapsed)
-			}
-
-			if run.Conclusion != "success" && config.PropagateFailure {
-				return fmt.Errorf("workflow failed with conclusion: %s", run.Conclusion)
-			}
-			return nil
-		}
-
-		// Only print status changes to reduce log noise
-		if run.Status != lastStatus {
-			statusIcon := "⏳"
-			statusText := run.Status
-			switch run.Status {
-			case "queued", "waiting", "pending":
-				statusIcon = "🔄"
-				statusText = "queued"
-			case "in_progress":
-				statusIcon = "▶️"
-				statusText = "running"
-			}
-			fmt.Printf("\r   %s Status: %s (elapsed: %v)", statusIcon, statusText, elapsed)
-			lastStatus = run.Status
-			lastPrintTime = time.Now()
-		} else if time.Since(lastPrintTime) > 5*time.Minute {
-			// Update elapsed time every 5 minutes if status hasn't changed
-			statusText := "running"
-			if run.Status == "queued" || run.Status == "waiting" || run.Status == "pending" {
-				statusText = "queued"
-			}
-			fmt.Printf("\r   %s Status: %s (elapsed: %v)", "⏳", statusText, elapsed)
-			lastPrintTime = time.Now()
-		}
-
-		// Adaptive polling: slower when queued, faster when in_progress
-		switch run.Status {
-		case "queued", "waiting", "pending":
-			pollInterval = maxDuration(config.WaitInterval, 30*time.Second)
-		case "in_progress":
-			pollInterval = config.WaitInterval
-		}
+	config.result.dispatched()
+	runID, err := client.Trigger(ctx, dispatchFor(config))
+	if err != nil {
+		return 0, explainDispatchError(ctx, client, config, checked, err)
 	}
+	config.result.runFound(runID, client.RunURL(runID))
+	return runID, nil
 }
 
-func maxDuration(a, b time.Duration) time.Duration {
-	if a > b {
-		return a
+// preflight runs the checks the preflight and validate_inputs inputs ask
+// for, and reports whether it ran them.
+func preflight(ctx context.Context, client *trigwait.Client, config *Config) (checked bool, err error) {
+	if !config.Preflight && !config.ValidateInputs {
+		return false, nil
 	}
-	return b
+	_, err = client.Preflight(ctx, dispatchFor(config), trigwait.PreflightOptions{ValidateInputs: config.ValidateInputs})
+	return true, err
 }
 
-func getWorkflowRun(config *Config, runID int64) (*WorkflowRun, error) {
-	path := fmt.Sprintf("runs/%d", runID)
-	respBody, err := apiRequest(config, "GET", path, nil)
-	if err != nil {
-		return nil, err
+// explainDispatchError replaces the generic API error of a rejected
+// dispatch with the targeted error of a failed preflight check, unless the
+// checks already ran before dispatching.
+func explainDispatchError(ctx context.Context, client *trigwait.Client, config *Config, checked bool, err error) error {
+	if checked || !isDispatchFailure(err) {
+		return err
 	}
-
-	var run WorkflowRun
-	if err := json.Unmarshal(respBody, &run); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if _, checkErr := client.Preflight(ctx, dispatchFor(config), trigwait.PreflightOptions{}); checkErr != nil {
+		return checkErr
 	}
-
-	return &run, nil
+	return err
 }
 
-func apiRequest(config *Config, method, path string, body []byte) ([]byte, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/actions/%s", config.GitHubAPIURL, config.Owner, config.Repo, path)
-
-	var reqBody io.Reader
-	if body != nil {
-		reqBody = bytes.NewReader(body)
+// isDispatchFailure reports whether err is a rejected dispatch that
+// preflight checks can explain.
+func isDispatchFailure(err error) bool {
+	switch trigwait.KindOf(err) {
+	case trigwait.KindAuth, trigwait.KindNotFound, trigwait.KindDispatchRejected:
+		return true
 	}
+	return false
+}
 
-	req, err := http.NewRequest(method, url, reqBody)
-	if err != nil {
-		return nil, err
-	}
+func findWorkflowRun(ctx context.Context, config *Config, startTime time.Time) (int64, error) {
+	return newClient(config).FindRun(ctx, dispatchFor(config), startTime)
+}
 
-	req.Header.Set("Authorization", "Bearer "+config.GitHubToken)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("Content-Type", "application/json")
+func waitForWorkflow(ctx context.Context, config *Config, runID int64) error {
+	client := newClient(config)
+	setOutput("workflow_id", strconv.FormatInt(runID, 10))
+	setOutput("workflow_url", client.RunURL(runID))
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	run, err := client.Wait(ctx, runID)
 	if err != nil {
-		return nil, err
+		statusFailed(ctx, config, err)
+		notifyFailed(ctx, config, runID, err)
+		return err
+	}
+	clearState(config)
+	setOutput("conclusion", run.Conclusion)
+	notify(ctx, config, run)
+
+	var jobs []trigwait.Job
+	checkPolicy := config.PropagateFailure || config.StatusReporter != nil
+	if config.result != nil || (checkPolicy && config.Policy.NeedsJobs(run)) {
+		jobs, err = client.ListJobs(ctx, runID)
+		if err != nil {
+			logf(trigwait.LevelWarn, "⚠ Could not list jobs: %v", err)
+		}
 	}
-	defer resp.Body.Close()
+	config.result.completed(run, jobs)
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	var policyErr error
+	if checkPolicy {
+		policyErr = config.Policy.Evaluate(run, jobs)
 	}
+	statusCompleted(ctx, config, run, policyErr)
 
-	// 204 No Content is success for dispatch
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return respBody, nil
+	if !config.PropagateFailure {
+		return nil
 	}
-
-	return nil, fmt.Errorf("API request failed: %sResponse: %s", resp.Status, string(respBody))
-}
-
-func setOutput(name, value string) {
-	outputFile := os.Getenv("GITHUB_OUTPUT")
-	if outputFile == "" {
-		return
+	if policyErr != nil {
+		return policyErr
 	}
-
-	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to open GITHUB_OUTPUT: %v", err)
-		return
+	if run.Conclusion != "success" {
+		logf(trigwait.LevelInfo, "   Conclusion %s accepted by failure policy", run.Conclusion)
 	}
-	defer f.Close()
-
-	fmt.Fprintf(f, "%s=%s\n", name, value)
+	return nil
 }
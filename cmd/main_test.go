@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
 	"testing"
 	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
 )
 
 func TestLoadConfig_Defaults(t *testing.T) {
@@ -168,6 +173,129 @@ func TestLoadConfig_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_LogOptions(t *testing.T) {
+	os.Setenv("INPUT_OWNER", "test-owner")
+	os.Setenv("INPUT_REPO", "test-repo")
+	os.Setenv("INPUT_GITHUB_TOKEN", "test-token")
+	os.Setenv("INPUT_WORKFLOW_FILE_NAME", "test.yml")
+	os.Setenv("INPUT_LOG_FORMAT", "plain")
+	os.Setenv("RUNNER_DEBUG", "1")
+	defer func() {
+		os.Unsetenv("INPUT_OWNER")
+		os.Unsetenv("INPUT_REPO")
+		os.Unsetenv("INPUT_GITHUB_TOKEN")
+		os.Unsetenv("INPUT_WORKFLOW_FILE_NAME")
+		os.Unsetenv("INPUT_LOG_FORMAT")
+		os.Unsetenv("RUNNER_DEBUG")
+	}()
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if config.LogFormat != trigwait.FormatPlain {
+		t.Errorf("expected plain log format, got %v", config.LogFormat)
+	}
+	if config.LogLevel != trigwait.LevelDebug {
+		t.Errorf("expected debug level when RUNNER_DEBUG=1, got %v", config.LogLevel)
+	}
+
+	os.Setenv("INPUT_LOG_LEVEL", "loud")
+	defer os.Unsetenv("INPUT_LOG_LEVEL")
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error for invalid log_level")
+	}
+}
+
+func TestLoadConfig_RefMode(t *testing.T) {
+	os.Setenv("INPUT_OWNER", "test-owner")
+	os.Setenv("INPUT_REPO", "test-repo")
+	os.Setenv("INPUT_GITHUB_TOKEN", "test-token")
+	os.Setenv("INPUT_WORKFLOW_FILE_NAME", "test.yml")
+	os.Setenv("INPUT_REF_MODE", "same-as-caller")
+	defer func() {
+		os.Unsetenv("INPUT_OWNER")
+		os.Unsetenv("INPUT_REPO")
+		os.Unsetenv("INPUT_GITHUB_TOKEN")
+		os.Unsetenv("INPUT_WORKFLOW_FILE_NAME")
+		os.Unsetenv("INPUT_REF_MODE")
+	}()
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if config.RefMode != trigwait.RefSameAsCaller {
+		t.Errorf("expected same-as-caller, got %s", config.RefMode)
+	}
+	if config.Ref != "" {
+		t.Errorf("expected no fallback ref when ref is unset, got %q", config.Ref)
+	}
+
+	os.Setenv("INPUT_REF_MODE", "latest")
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error for invalid ref_mode")
+	}
+}
+
+func TestLoadConfig_SLO(t *testing.T) {
+	os.Setenv("INPUT_OWNER", "test-owner")
+	os.Setenv("INPUT_REPO", "test-repo")
+	os.Setenv("INPUT_GITHUB_TOKEN", "test-token")
+	os.Setenv("INPUT_WORKFLOW_FILE_NAME", "test.yml")
+	os.Setenv("INPUT_MAX_QUEUE_TIME", "600")
+	os.Setenv("INPUT_MAX_RUN_TIME", "3600")
+	os.Setenv("INPUT_SLO_ACTION", "cancel")
+	defer func() {
+		os.Unsetenv("INPUT_OWNER")
+		os.Unsetenv("INPUT_REPO")
+		os.Unsetenv("INPUT_GITHUB_TOKEN")
+		os.Unsetenv("INPUT_WORKFLOW_FILE_NAME")
+		os.Unsetenv("INPUT_MAX_QUEUE_TIME")
+		os.Unsetenv("INPUT_MAX_RUN_TIME")
+		os.Unsetenv("INPUT_SLO_ACTION")
+	}()
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	want := trigwait.SLO{MaxQueueTime: 10 * time.Minute, MaxRunTime: time.Hour, Action: trigwait.SLOCancel}
+	if config.SLO != want {
+		t.Errorf("expected %+v, got %+v", want, config.SLO)
+	}
+
+	os.Setenv("INPUT_SLO_ACTION", "ignore")
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error for invalid slo_action")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{nil, 0},
+		{errors.New("boom"), 1},
+		{trigwait.Errorf(trigwait.KindConfig, "bad"), 2},
+		{trigwait.Errorf(trigwait.KindAuth, "bad"), 3},
+		{trigwait.Errorf(trigwait.KindNotFound, "bad"), 4},
+		{trigwait.Errorf(trigwait.KindDispatchRejected, "bad"), 5},
+		{trigwait.Errorf(trigwait.KindDiscoveryTimeout, "bad"), 6},
+		{trigwait.Errorf(trigwait.KindWaitTimeout, "bad"), 7},
+		{trigwait.Errorf(trigwait.KindDownstreamFailed, "bad"), 8},
+		{trigwait.Errorf(trigwait.KindDownstreamCancelled, "bad"), 9},
+		{trigwait.Errorf(trigwait.KindSLOBreached, "bad"), 10},
+	}
+
+	for _, tt := range tests {
+		if code := exitCode(tt.err); code != tt.code {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, code, tt.code)
+		}
+	}
+}
+
 func TestGetEnvBool(t *testing.T) {
 	tests := []struct {
 		value    string
@@ -197,59 +325,79 @@ func TestGetEnvBool(t *testing.T) {
 	}
 }
 
-func TestAPIRequest_Success(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Header.Get("Authorization") != "Bearer test-token" {
-			t.Error("missing or incorrect Authorization header")
-		}
-		if r.Header.Get("Accept") != "application/vnd.github.v3+json" {
-			t.Error("missing or incorrect Accept header")
-		}
-
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-	}))
-	defer server.Close()
-
-	config := &Config{
-		Owner:        "owner",
-		Repo:         "repo",
-		GitHubToken:  "test-token",
-		GitHubAPIURL: server.URL,
-	}
+func TestLoadConfig_DistinctIDFormat(t *testing.T) {
+	os.Setenv("INPUT_OWNER", "test-owner")
+	os.Setenv("INPUT_REPO", "test-repo")
+	os.Setenv("INPUT_GITHUB_TOKEN", "test-token")
+	os.Setenv("INPUT_WORKFLOW_FILE_NAME", "test.yml")
+	os.Setenv("INPUT_DISTINCT_ID_NAME", "distinct_id")
+	os.Setenv("INPUT_DISTINCT_ID_LENGTH", "16")
+	os.Setenv("INPUT_DISTINCT_ID_ALPHABET", "0123456789")
+	os.Setenv("INPUT_DISTINCT_ID_PREFIX", "run 4242")
+	defer func() {
+		os.Unsetenv("INPUT_OWNER")
+		os.Unsetenv("INPUT_REPO")
+		os.Unsetenv("INPUT_GITHUB_TOKEN")
+		os.Unsetenv("INPUT_WORKFLOW_FILE_NAME")
+		os.Unsetenv("INPUT_DISTINCT_ID_NAME")
+		os.Unsetenv("INPUT_DISTINCT_ID_LENGTH")
+		os.Unsetenv("INPUT_DISTINCT_ID_ALPHABET")
+		os.Unsetenv("INPUT_DISTINCT_ID_PREFIX")
+		os.Unsetenv("INPUT_DISTINCT_ID_FORMAT")
+	}()
 
-	resp, err := apiRequest(config, "GET", "test/path", nil)
+	config, err := loadConfig()
 	if err != nil {
-		t.Fatalf("apiRequest failed: %v", err)
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if !regexp.MustCompile(`^run-4242-[0-9]{16}$`).MatchString(config.DistinctID) {
+		t.Errorf("unexpected distinct ID %q", config.DistinctID)
+	}
+	if config.ClientPayload["distinct_id"] != config.DistinctID {
+		t.Errorf("expected the distinct ID in client_payload, got %v", config.ClientPayload["distinct_id"])
 	}
 
-	var result map[string]string
-	json.Unmarshal(resp, &result)
-	if result["status"] != "ok" {
-		t.Errorf("unexpected response: %s", string(resp))
+	os.Setenv("INPUT_DISTINCT_ID_FORMAT", "ulid")
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error for invalid distinct_id_format")
+	}
+	os.Setenv("INPUT_DISTINCT_ID_FORMAT", "random")
+	os.Setenv("INPUT_DISTINCT_ID_ALPHABET", "00")
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error for invalid distinct_id_alphabet")
 	}
 }
 
-func TestAPIRequest_Failure(t *testing.T) {
+func TestFindWorkflowRun_DistinctIDWholeWord(t *testing.T) {
+	startTime := time.Now()
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		w.Write([]byte(`{"message": "Validation failed"}`))
+		response := trigwait.WorkflowRunsResponse{
+			WorkflowRuns: []trigwait.WorkflowRun{
+				{ID: 12346, CreatedAt: startTime.Format(time.RFC3339), DisplayTitle: "Deploy [XK7M4N2Y]"},
+				{ID: 12345, CreatedAt: startTime.Format(time.RFC3339), DisplayTitle: "Deploy [K7M4N2]"},
+			},
+		}
+		json.NewEncoder(w).Encode(response)
 	}))
 	defer server.Close()
 
 	config := &Config{
-		Owner:        "owner",
-		Repo:         "repo",
-		GitHubToken:  "test-token",
-		GitHubAPIURL: server.URL,
+		Owner:            "owner",
+		Repo:             "repo",
+		GitHubToken:      "test-token",
+		GitHubAPIURL:     server.URL,
+		WorkflowFileName: "test.yml",
+		Ref:              "main",
+		DistinctID:       "K7M4N2",
+		DistinctIDName:   "distinct_id",
 	}
 
-	_, err := apiRequest(config, "POST", "test/path", []byte(`{}`))
-	if err == nil {
-		t.Fatal("expected error for 422 response, got nil")
+	runID, err := findWorkflowRun(context.Background(), config, startTime)
+	if err != nil {
+		t.Fatalf("findWorkflowRun failed: %v", err)
 	}
-	if !contains(err.Error(), "422") {
-		t.Errorf("expected error containing '422', got '%s'", err.Error())
+	if runID != 12345 {
+		t.Errorf("expected runID 12345, got %d", runID)
 	}
 }
 
@@ -259,8 +407,8 @@ func TestFindWorkflowRun(t *testing.T) {
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Runs endpoint - return matching display_title
-		response := WorkflowRunsResponse{
-			WorkflowRuns: []WorkflowRun{
+		response := trigwait.WorkflowRunsResponse{
+			WorkflowRuns: []trigwait.WorkflowRun{
 				{
 					ID:           12345,
 					CreatedAt:    startTime.Add(1 * time.Second).Format(time.RFC3339),
@@ -284,7 +432,7 @@ func TestFindWorkflowRun(t *testing.T) {
 		DistinctIDName:   "distinct_id",
 	}
 
-	runID, err := findWorkflowRun(config, startTime)
+	runID, err := findWorkflowRun(context.Background(), config, startTime)
 	if err != nil {
 		t.Fatalf("findWorkflowRun failed: %v", err)
 	}
@@ -297,8 +445,8 @@ func TestFindWorkflowRun_NoMatch(t *testing.T) {
 	startTime := time.Now()
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		response := WorkflowRunsResponse{
-			WorkflowRuns: []WorkflowRun{
+		response := trigwait.WorkflowRunsResponse{
+			WorkflowRuns: []trigwait.WorkflowRun{
 				{ID: 12345, CreatedAt: startTime.Add(-1 * time.Hour).Format(time.RFC3339)},
 			},
 		}
@@ -315,7 +463,7 @@ func TestFindWorkflowRun_NoMatch(t *testing.T) {
 		Ref:              "main",
 	}
 
-	runID, err := findWorkflowRun(config, startTime)
+	runID, err := findWorkflowRun(context.Background(), config, startTime)
 	if err != nil {
 		t.Fatalf("findWorkflowRun failed: %v", err)
 	}
@@ -330,8 +478,8 @@ func TestFindWorkflowRun_DistinctIDFallbackToTimeBased(t *testing.T) {
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Simulate target workflow that doesn't include distinct_id in run-name
-		response := WorkflowRunsResponse{
-			WorkflowRuns: []WorkflowRun{
+		response := trigwait.WorkflowRunsResponse{
+			WorkflowRuns: []trigwait.WorkflowRun{
 				{
 					ID:           12346,
 					CreatedAt:    startTime.Add(2 * time.Second).Format(time.RFC3339),
@@ -364,7 +512,7 @@ func TestFindWorkflowRun_DistinctIDFallbackToTimeBased(t *testing.T) {
 		DistinctIDName:   "distinct_id",
 	}
 
-	runID, err := findWorkflowRun(config, startTime)
+	runID, err := findWorkflowRun(context.Background(), config, startTime)
 	if err != nil {
 		t.Fatalf("findWorkflowRun failed: %v", err)
 	}
@@ -381,8 +529,8 @@ func TestFindWorkflowRun_TimeBasedWithMultipleRuns(t *testing.T) {
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Multiple runs after startTime, should match the first one chronologically
-		response := WorkflowRunsResponse{
-			WorkflowRuns: []WorkflowRun{
+		response := trigwait.WorkflowRunsResponse{
+			WorkflowRuns: []trigwait.WorkflowRun{
 				{
 					ID:           12346,
 					CreatedAt:    startTime.Add(2 * time.Second).Format(time.RFC3339),
@@ -414,7 +562,7 @@ func TestFindWorkflowRun_TimeBasedWithMultipleRuns(t *testing.T) {
 		// No DistinctID - pure time-based matching
 	}
 
-	runID, err := findWorkflowRun(config, startTime)
+	runID, err := findWorkflowRun(context.Background(), config, startTime)
 	if err != nil {
 		t.Fatalf("findWorkflowRun failed: %v", err)
 	}
@@ -425,29 +573,6 @@ func TestFindWorkflowRun_TimeBasedWithMultipleRuns(t *testing.T) {
 	}
 }
 
-func TestSetOutput(t *testing.T) {
-	tmpFile, err := os.CreateTemp("", "github_output")
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer os.Remove(tmpFile.Name())
-	tmpFile.Close()
-
-	os.Setenv("GITHUB_OUTPUT", tmpFile.Name())
-	defer os.Unsetenv("GITHUB_OUTPUT")
-
-	setOutput("test_key", "test_value")
-	setOutput("workflow_id", "12345")
-
-	content, _ := os.ReadFile(tmpFile.Name())
-	if !contains(string(content), "test_key=test_value") {
-		t.Errorf("expected output to contain 'test_key=test_value', got: %s", string(content))
-	}
-	if !contains(string(content), "workflow_id=12345") {
-		t.Errorf("expected output to contain 'workflow_id=12345', got: %s", string(content))
-	}
-}
-
 func TestTriggerWorkflow_Success(t *testing.T) {
 	triggerCalled := false
 	startTime := time.Now()
@@ -460,8 +585,8 @@ func TestTriggerWorkflow_Success(t *testing.T) {
 			return
 		}
 		if contains(r.URL.Path, "runs") {
-			response := WorkflowRunsResponse{
-				WorkflowRuns: []WorkflowRun{
+			response := trigwait.WorkflowRunsResponse{
+				WorkflowRuns: []trigwait.WorkflowRun{
 					{
 						ID:           99999,
 						CreatedAt:    startTime.Add(1 * time.Second).Format(time.RFC3339),
@@ -489,7 +614,7 @@ func TestTriggerWorkflow_Success(t *testing.T) {
 		DistinctIDName:   "distinct_id",
 	}
 
-	runID, err := triggerWorkflow(config)
+	runID, err := triggerWorkflow(context.Background(), config)
 	if err != nil {
 		t.Fatalf("triggerWorkflow failed: %v", err)
 	}
@@ -509,7 +634,7 @@ func TestTriggerWorkflow_Timeout(t *testing.T) {
 		}
 		if contains(r.URL.Path, "runs") {
 			// Return empty runs - run never appears
-			json.NewEncoder(w).Encode(WorkflowRunsResponse{WorkflowRuns: []WorkflowRun{}})
+			json.NewEncoder(w).Encode(trigwait.WorkflowRunsResponse{WorkflowRuns: []trigwait.WorkflowRun{}})
 			return
 		}
 	}))
@@ -529,7 +654,7 @@ func TestTriggerWorkflow_Timeout(t *testing.T) {
 		DistinctIDName:   "distinct_id",
 	}
 
-	_, err := triggerWorkflow(config)
+	_, err := triggerWorkflow(context.Background(), config)
 	if err == nil {
 		t.Fatal("expected timeout error, got nil")
 	}
@@ -543,7 +668,7 @@ func TestWaitForWorkflow_Success(t *testing.T) {
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		pollCount++
-		run := WorkflowRun{
+		run := trigwait.WorkflowRun{
 			ID:         12345,
 			Status:     "completed",
 			Conclusion: "success",
@@ -568,7 +693,7 @@ func TestWaitForWorkflow_Success(t *testing.T) {
 		PropagateFailure: true,
 	}
 
-	err := waitForWorkflow(config, 12345)
+	err := waitForWorkflow(context.Background(), config, 12345)
 	if err != nil {
 		t.Fatalf("waitForWorkflow failed: %v", err)
 	}
@@ -584,7 +709,7 @@ func TestWaitForWorkflow_Success(t *testing.T) {
 
 func TestWaitForWorkflow_FailurePropagated(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		run := WorkflowRun{
+		run := trigwait.WorkflowRun{
 			ID:         12345,
 			Status:     "completed",
 			Conclusion: "failure",
@@ -609,7 +734,7 @@ func TestWaitForWorkflow_FailurePropagated(t *testing.T) {
 		PropagateFailure: true,
 	}
 
-	err := waitForWorkflow(config, 12345)
+	err := waitForWorkflow(context.Background(), config, 12345)
 	if err == nil {
 		t.Fatal("expected error for failed workflow, got nil")
 	}
@@ -620,7 +745,7 @@ func TestWaitForWorkflow_FailurePropagated(t *testing.T) {
 
 func TestWaitForWorkflow_FailureNotPropagated(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		run := WorkflowRun{
+		run := trigwait.WorkflowRun{
 			ID:         12345,
 			Status:     "completed",
 			Conclusion: "failure",
@@ -645,12 +770,83 @@ func TestWaitForWorkflow_FailureNotPropagated(t *testing.T) {
 		PropagateFailure: false,
 	}
 
-	err := waitForWorkflow(config, 12345)
+	err := waitForWorkflow(context.Background(), config, 12345)
 	if err != nil {
 		t.Fatalf("expected no error when propagate_failure=false, got: %v", err)
 	}
 }
 
+func TestWaitForWorkflow_NonBlockingJobs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contains(r.URL.Path, "jobs") {
+			json.NewEncoder(w).Encode(trigwait.JobsResponse{
+				TotalCount: 2,
+				Jobs: []trigwait.Job{
+					{Name: "build", Status: "completed", Conclusion: "success"},
+					{Name: "flaky-e2e", Status: "completed", Conclusion: "failure"},
+				},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(trigwait.WorkflowRun{ID: 12345, Status: "completed", Conclusion: "failure"})
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Owner:            "owner",
+		Repo:             "repo",
+		GitHubToken:      "test-token",
+		GitHubAPIURL:     server.URL,
+		GitHubServerURL:  "https://github.com",
+		WaitInterval:     50 * time.Millisecond,
+		PropagateFailure: true,
+		Policy:           trigwait.Policy{NonBlockingJobs: []string{"flaky-*"}},
+	}
+
+	if err := waitForWorkflow(context.Background(), config, 12345); err != nil {
+		t.Fatalf("expected failure of non-blocking job to be ignored, got: %v", err)
+	}
+
+	config.Policy.NonBlockingJobs = []string{"lint"}
+	err := waitForWorkflow(context.Background(), config, 12345)
+	if trigwait.KindOf(err) != trigwait.KindDownstreamFailed {
+		t.Fatalf("expected downstream-failed error, got: %v", err)
+	}
+}
+
+func TestLoadConfig_Policy(t *testing.T) {
+	os.Setenv("INPUT_OWNER", "test-owner")
+	os.Setenv("INPUT_REPO", "test-repo")
+	os.Setenv("INPUT_GITHUB_TOKEN", "test-token")
+	os.Setenv("INPUT_WORKFLOW_FILE_NAME", "test.yml")
+	os.Setenv("INPUT_ACCEPTED_CONCLUSIONS", "success, cancelled")
+	os.Setenv("INPUT_NON_BLOCKING_JOBS", "lint\nflaky-*")
+	defer func() {
+		os.Unsetenv("INPUT_OWNER")
+		os.Unsetenv("INPUT_REPO")
+		os.Unsetenv("INPUT_GITHUB_TOKEN")
+		os.Unsetenv("INPUT_WORKFLOW_FILE_NAME")
+		os.Unsetenv("INPUT_ACCEPTED_CONCLUSIONS")
+		os.Unsetenv("INPUT_NON_BLOCKING_JOBS")
+	}()
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if len(config.Policy.AcceptedConclusions) != 2 || config.Policy.AcceptedConclusions[1] != "cancelled" {
+		t.Errorf("unexpected accepted conclusions: %v", config.Policy.AcceptedConclusions)
+	}
+	if len(config.Policy.NonBlockingJobs) != 2 || config.Policy.NonBlockingJobs[1] != "flaky-*" {
+		t.Errorf("unexpected non-blocking jobs: %v", config.Policy.NonBlockingJobs)
+	}
+
+	os.Setenv("INPUT_ACCEPTED_CONCLUSIONS", "success,passed")
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error for unknown conclusion")
+	}
+}
+
 // Helper function
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsHelper(s, substr))
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxMatrixCombinations matches the limit GitHub puts on job matrices.
+const maxMatrixCombinations = 256
+
+// matrixPipeline expands the matrix input, a JSON object of input names to
+// lists of values, into a pipeline with one independent stage per
+// combination. Each stage dispatches client_payload plus its combination and
+// is named after it, so combinations that read the same, such as a repeated
+// value, are rejected.
+func matrixPipeline(config *Config, spec string) (*Pipeline, error) {
+	var matrix map[string][]interface{}
+	if err := json.Unmarshal([]byte(spec), &matrix); err != nil {
+		return nil, fmt.Errorf("invalid matrix JSON: expected an object of input names to lists of values: %w", err)
+	}
+	if len(matrix) == 0 {
+		return nil, fmt.Errorf("invalid matrix: no inputs")
+	}
+	keys := make([]string, 0, len(matrix))
+	total := 1
+	for key, values := range matrix {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("invalid matrix: %s has no values", key)
+		}
+		keys = append(keys, key)
+		total *= len(values)
+		if total > maxMatrixCombinations {
+			return nil, fmt.Errorf("invalid matrix: more than %d combinations", maxMatrixCombinations)
+		}
+	}
+	sort.Strings(keys)
+
+	base := make(map[string]interface{}, len(config.ClientPayload))
+	for k, v := range config.ClientPayload {
+		if k != config.DistinctIDName {
+			base[k] = v
+		}
+	}
+
+	pipeline := &Pipeline{Matrix: true}
+	seen := make(map[string]bool, total)
+	for _, combination := range combinations(keys, matrix) {
+		inputs := make(map[string]interface{}, len(base)+len(combination))
+		for k, v := range base {
+			inputs[k] = v
+		}
+		var labels []string
+		for _, key := range keys {
+			inputs[key] = combination[key]
+			labels = append(labels, fmt.Sprintf("%s=%v", key, combination[key]))
+		}
+		name := strings.Join(labels, ",")
+		if seen[name] {
+			return nil, fmt.Errorf("invalid matrix: combination %s appears more than once", name)
+		}
+		seen[name] = true
+		pipeline.Stages = append(pipeline.Stages, &Stage{
+			Name:           name,
+			Owner:          config.Owner,
+			Repo:           config.Repo,
+			Workflow:       config.WorkflowFileName,
+			Ref:            config.Ref,
+			Inputs:         inputs,
+			DistinctIDName: config.DistinctIDName,
+			Wait:           config.WaitWorkflow,
+			Matrix:         combination,
+		})
+	}
+	return pipeline, nil
+}
+
+// combinations returns the cartesian product of the matrix values, varying
+// the last key fastest.
+func combinations(keys []string, matrix map[string][]interface{}) []map[string]interface{} {
+	result := []map[string]interface{}{{}}
+	for _, key := range keys {
+		var next []map[string]interface{}
+		for _, partial := range result {
+			for _, value := range matrix[key] {
+				combination := make(map[string]interface{}, len(partial)+1)
+				for k, v := range partial {
+					combination[k] = v
+				}
+				combination[key] = value
+				next = append(next, combination)
+			}
+		}
+		result = next
+	}
+	return result
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func TestMatrixPipeline(t *testing.T) {
+	config := &Config{
+		Owner:            "owner",
+		Repo:             "repo",
+		WorkflowFileName: "deploy.yml",
+		Ref:              "main",
+		DistinctIDName:   "distinct_id",
+		WaitWorkflow:     true,
+		ClientPayload:    map[string]interface{}{"version": "1.2.3", "distinct_id": "ABCDEFGH"},
+	}
+	pipeline, err := matrixPipeline(config, `{"region": ["eu", "us"], "env": ["staging", "prod", "dev"]}`)
+	if err != nil {
+		t.Fatalf("matrixPipeline failed: %v", err)
+	}
+	if !pipeline.Matrix {
+		t.Error("expected a matrix pipeline")
+	}
+
+	want := []string{
+		"env=staging,region=eu", "env=staging,region=us",
+		"env=prod,region=eu", "env=prod,region=us",
+		"env=dev,region=eu", "env=dev,region=us",
+	}
+	if len(pipeline.Stages) != len(want) {
+		t.Fatalf("expected %d combinations, got %d", len(want), len(pipeline.Stages))
+	}
+	for i, stage := range pipeline.Stages {
+		if stage.Name != want[i] {
+			t.Errorf("combination %d: expected %s, got %s", i, want[i], stage.Name)
+		}
+		if stage.Inputs["version"] != "1.2.3" {
+			t.Errorf("%s: expected client_payload inputs, got %v", stage.Name, stage.Inputs)
+		}
+		if _, ok := stage.Inputs["distinct_id"]; ok {
+			t.Errorf("%s: expected the shared distinct ID to be dropped", stage.Name)
+		}
+		if stage.Workflow != "deploy.yml" || stage.Ref != "main" || !stage.Wait || len(stage.Needs) != 0 {
+			t.Errorf("%s: unexpected stage %+v", stage.Name, stage)
+		}
+	}
+}
+
+func TestMatrixPipeline_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{name: "not JSON", spec: `env: [prod]`},
+		{name: "not a list", spec: `{"env": "prod"}`},
+		{name: "empty", spec: `{}`},
+		{name: "no values", spec: `{"env": []}`},
+		{name: "repeated value", spec: `{"env": ["prod", "prod"]}`},
+		{name: "values that print the same", spec: `{"env": [1, "1"]}`},
+		{name: "too many", spec: `{"a": [1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16], "b": [1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := matrixPipeline(&Config{}, tt.spec); err == nil {
+				t.Errorf("expected error for %s", tt.spec)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_Matrix(t *testing.T) {
+	os.Setenv("INPUT_OWNER", "test-owner")
+	os.Setenv("INPUT_REPO", "test-repo")
+	os.Setenv("INPUT_GITHUB_TOKEN", "test-token")
+	os.Setenv("INPUT_WORKFLOW_FILE_NAME", "test.yml")
+	os.Setenv("INPUT_MATRIX", `{"env": ["staging", "prod"]}`)
+	defer func() {
+		os.Unsetenv("INPUT_OWNER")
+		os.Unsetenv("INPUT_REPO")
+		os.Unsetenv("INPUT_GITHUB_TOKEN")
+		os.Unsetenv("INPUT_WORKFLOW_FILE_NAME")
+		os.Unsetenv("INPUT_MATRIX")
+		os.Unsetenv("INPUT_DISTINCT_ID_NAME")
+	}()
+
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error without distinct_id_name")
+	}
+
+	os.Setenv("INPUT_DISTINCT_ID_NAME", "distinct_id")
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if config.Pipeline == nil || len(config.Pipeline.Stages) != 2 {
+		t.Fatalf("expected a pipeline of 2 combinations, got %+v", config.Pipeline)
+	}
+
+	os.Setenv("INPUT_CONCURRENCY", "wait")
+	defer os.Unsetenv("INPUT_CONCURRENCY")
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error combining concurrency with matrix")
+	}
+}
+
+func TestRunPipeline_Matrix(t *testing.T) {
+	sink := newJSONFileSink(filepath.Join(t.TempDir(), "outputs.json"))
+	outputSinks = []OutputSink{sink}
+	defer func() { outputSinks = []OutputSink{githubOutputSink{}} }()
+
+	fake, config := newDeployFake(t, fakegh.Workflow{})
+	config.WaitWorkflow = true
+	config.PropagateFailure = true
+	config.Policy = trigwait.DefaultPolicy()
+
+	pipeline, err := matrixPipeline(config, `{"env": ["staging", "prod"], "fake_conclusion": ["success", "failure"]}`)
+	if err != nil {
+		t.Fatalf("matrixPipeline failed: %v", err)
+	}
+	config.Pipeline = pipeline
+
+	err = runPipeline(context.Background(), config)
+	if kind := trigwait.KindOf(err); kind != trigwait.KindDownstreamFailed {
+		t.Fatalf("expected %s, got %s (%v)", trigwait.KindDownstreamFailed, kind, err)
+	}
+
+	runs := fake.Runs()
+	if len(runs) != 4 {
+		t.Fatalf("expected 4 dispatched runs, got %d", len(runs))
+	}
+	ids := map[interface{}]bool{}
+	for _, run := range runs {
+		ids[run.Inputs["distinct_id"]] = true
+	}
+	if len(ids) != 4 {
+		t.Errorf("expected 4 distinct IDs, got %v", ids)
+	}
+
+	var report []StageReport
+	if err := json.Unmarshal([]byte(sink.values["matrix_report"]), &report); err != nil {
+		t.Fatalf("invalid matrix_report: %v", err)
+	}
+	if len(report) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(report))
+	}
+	for _, entry := range report {
+		want := entry.Matrix["fake_conclusion"]
+		if entry.Conclusion != want {
+			t.Errorf("%v: expected conclusion %v, got %s", entry.Matrix, want, entry.Conclusion)
+		}
+	}
+	if report[0].Matrix["env"] != "staging" || report[3].Matrix["env"] != "prod" {
+		t.Errorf("expected entries in matrix order, got %v then %v", report[0].Matrix, report[3].Matrix)
+	}
+}
+
+func TestRunPipeline_MatrixWithoutRef(t *testing.T) {
+	fake, fakeConfig := newFakeGitHub(t, &fakegh.Repo{
+		Owner:         "owner",
+		Name:          "repo",
+		DefaultBranch: "trunk",
+		Workflows: []*fakegh.Workflow{
+			{Name: "Deploy", Path: "deploy.yml", RunName: "Deploy ${{ inputs.distinct_id }}"},
+		},
+	})
+	// No ref: ref_mode picks it for every combination, and the workflow is
+	// given by its display name
+	env := map[string]string{
+		"GITHUB_API_URL":           fakeConfig.GitHubAPIURL,
+		"INPUT_OWNER":              "owner",
+		"INPUT_REPO":               "repo",
+		"INPUT_GITHUB_TOKEN":       "test-token",
+		"INPUT_WORKFLOW_FILE_NAME": "Deploy",
+		"INPUT_REF_MODE":           "default",
+		"INPUT_DISTINCT_ID_NAME":   "distinct_id",
+		"INPUT_WAIT_WORKFLOW":      "false",
+		"INPUT_MATRIX":             `{"env": ["staging", "prod"]}`,
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	config.WaitInterval = 10 * time.Millisecond
+	config.OutputSinks = []OutputSink{newJSONFileSink(filepath.Join(t.TempDir(), "outputs.json"))}
+	outputSinks = config.OutputSinks
+	defer func() { outputSinks = []OutputSink{githubOutputSink{}} }()
+
+	if err := runPipeline(context.Background(), config); err != nil {
+		t.Fatalf("runPipeline failed: %v", err)
+	}
+	runs := fake.Runs()
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 dispatched runs, got %d", len(runs))
+	}
+	for _, run := range runs {
+		if run.Ref != "trunk" {
+			t.Errorf("expected the default branch trunk, got %q", run.Ref)
+		}
+	}
+}
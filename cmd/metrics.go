@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// metrics collects what the result document does not record: API request
+// and retry counts and the latency of the dispatch request.
+type metrics struct {
+	mu              sync.Mutex
+	requests        int
+	errors          int
+	retries         int
+	dispatchLatency time.Duration
+}
+
+// recordRequest is installed as the client's request hook.
+func (m *metrics) recordRequest(_ context.Context, info trigwait.RequestInfo) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests++
+	if info.Err != nil {
+		m.errors++
+	}
+	if info.Method == "POST" && strings.HasSuffix(info.Path, "/dispatches") && info.Err == nil {
+		m.dispatchLatency = info.Duration
+	}
+}
+
+// recordRetry is installed as the client's retry hook.
+func (m *metrics) recordRetry(_ context.Context, _ error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries++
+}
+
+// sample is a single measurement, named without the trigwait prefix.
+type sample struct {
+	name    string
+	help    string
+	counter bool
+	seconds bool
+	value   float64
+	// label is an extra label, e.g. conclusion, for samples that count an
+	// outcome.
+	label, labelValue string
+}
+
+// samples returns the measurements of the finished step. Phases that did not
+// happen, such as queueing when not waiting, are left out.
+func (m *metrics) samples(result *Result, cache *trigwait.ResponseCache, err error) []sample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var notModified int
+	if cache != nil {
+		_, notModified = cache.Stats()
+	}
+	timings := result.Timings
+	var samples []sample
+	durations := []struct {
+		name, help string
+		value      float64
+	}{
+		{"dispatch_latency_seconds", "Duration of the workflow_dispatch request.", m.dispatchLatency.Seconds()},
+		{"discovery_seconds", "Time from dispatch until the run was found.", timings.DiscoverySeconds},
+		{"queue_seconds", "Time the run was queued.", timings.QueueSeconds},
+		{"run_seconds", "Time the run was in progress.", timings.RunSeconds},
+		{"wait_seconds", "Time from finding the run until it completed.", timings.WaitSeconds},
+	}
+	for _, d := range durations {
+		if d.value > 0 {
+			samples = append(samples, sample{name: d.name, help: d.help, seconds: true, value: d.value})
+		}
+	}
+	samples = append(samples,
+		sample{name: "total_seconds", help: "Duration of the step.", seconds: true, value: timings.TotalSeconds},
+		sample{name: "api_requests", help: "GitHub API requests made by the step.", counter: true, value: float64(m.requests)},
+		sample{name: "api_not_modified", help: "GitHub API requests answered with 304 Not Modified.", counter: true, value: float64(notModified)},
+		sample{name: "api_errors", help: "GitHub API requests that failed.", counter: true, value: float64(m.errors)},
+		sample{name: "api_retries", help: "Run lookups and status polls retried after an error.", counter: true, value: float64(m.retries)},
+	)
+	if result.Conclusion != "" {
+		samples = append(samples, sample{name: "runs", help: "Downstream run of the step, by conclusion.", counter: true, value: 1, label: "conclusion", labelValue: result.Conclusion})
+	}
+	if err != nil {
+		samples = append(samples, sample{name: "errors", help: "Failure of the step, by error kind.", counter: true, value: 1, label: "error_kind", labelValue: string(trigwait.KindOf(err))})
+	}
+	return samples
+}
+
+// reportMetrics exports the metrics of the finished step to every configured
+// destination.
+func reportMetrics(config *Config, err error) {
+	if config.metrics == nil || config.result == nil {
+		return
+	}
+	samples := config.metrics.samples(config.result, config.cache, err)
+	labels := [][2]string{{"owner", config.Owner}, {"repo", config.Repo}, {"workflow", config.WorkflowFileName}}
+
+	if config.MetricsTextfile != "" {
+		if werr := writeTextfile(config.MetricsTextfile, prometheusText(samples, labels)); werr != nil {
+			logf(trigwait.LevelWarn, "⚠ Failed to write metrics textfile: %v", werr)
+		}
+	}
+	if config.MetricsPushgateway != "" {
+		if perr := pushMetrics(config.MetricsPushgateway, prometheusText(samples, labels), labels); perr != nil {
+			logf(trigwait.LevelWarn, "⚠ Failed to push metrics: %v", perr)
+		}
+	}
+	if config.MetricsStatsD != "" {
+		if serr := sendStatsD(config.MetricsStatsD, statsDLines(samples)); serr != nil {
+			logf(trigwait.LevelWarn, "⚠ Failed to send StatsD metrics: %v", serr)
+		}
+	}
+}
+
+// prometheusText renders samples in the Prometheus text exposition format.
+// Every sample is written as a gauge, since each run of the step reports
+// its own values rather than incrementing a long-lived counter; names
+// therefore never end in _total.
+func prometheusText(samples []sample, labels [][2]string) []byte {
+	var buf bytes.Buffer
+	seen := map[string]bool{}
+	for _, s := range samples {
+		name := "trigwait_" + s.name
+		if !seen[name] {
+			seen[name] = true
+			fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n", name, s.help, name)
+		}
+		all := labels
+		if s.label != "" {
+			all = append(append([][2]string{}, labels...), [2]string{s.label, s.labelValue})
+		}
+		pairs := make([]string, len(all))
+		for i, l := range all {
+			pairs[i] = fmt.Sprintf("%s=%q", l[0], l[1])
+		}
+		fmt.Fprintf(&buf, "%s{%s} %s\n", name, strings.Join(pairs, ","), strconv.FormatFloat(s.value, 'g', -1, 64))
+	}
+	return buf.Bytes()
+}
+
+// writeTextfile writes a file for the node_exporter textfile collector,
+// through a temporary file so the collector never reads a partial one.
+func writeTextfile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// pushMetrics replaces the group of the target workflow on a Pushgateway.
+func pushMetrics(baseURL string, data []byte, labels [][2]string) error {
+	target := strings.TrimSuffix(baseURL, "/") + "/metrics/job/trigwait"
+	for _, l := range labels {
+		target += "/" + l[0] + "/" + url.PathEscape(l[1])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "PUT", target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("pushgateway returned %s", resp.Status)
+	}
+	return nil
+}
+
+// statsDLines renders samples as StatsD lines: durations as timers in
+// milliseconds, counts as counters. Outcomes become one counter per value,
+// e.g. trigwait.runs.failure.
+func statsDLines(samples []sample) []string {
+	var lines []string
+	for _, s := range samples {
+		name := "trigwait." + strings.TrimSuffix(s.name, "_seconds")
+		if s.label != "" {
+			name += "." + s.labelValue
+		}
+		switch {
+		case s.seconds:
+			lines = append(lines, fmt.Sprintf("%s:%d|ms", name, int64(s.value*1000)))
+		case s.counter:
+			lines = append(lines, fmt.Sprintf("%s:%d|c", name, int64(s.value)))
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// sendStatsD sends lines to a StatsD server in a single UDP datagram.
+func sendStatsD(addr string, lines []string) error {
+	conn, err := net.DialTimeout("udp", addr, reportTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(strings.Join(lines, "\n")))
+	return err
+}
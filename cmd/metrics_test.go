@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+)
+
+func TestReportMetrics(t *testing.T) {
+	var pushPath, pushMethod, pushBody string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pushMethod, pushPath, pushBody = r.Method, r.URL.Path, string(body)
+	}))
+	defer gateway.Close()
+
+	statsd, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer statsd.Close()
+
+	_, config := newDeployFake(t, fakegh.Workflow{Conclusion: "failure", RunTime: fakegh.Duration(50 * time.Millisecond)})
+	config.PropagateFailure = true
+	config.MetricsTextfile = filepath.Join(t.TempDir(), "trigwait.prom")
+	config.MetricsPushgateway = gateway.URL
+	config.MetricsStatsD = statsd.LocalAddr().String()
+	config.metrics = &metrics{}
+	config.result = newResult(config)
+
+	runID, err := triggerWorkflow(context.Background(), config)
+	if err != nil {
+		t.Fatalf("triggerWorkflow failed: %v", err)
+	}
+	err = waitForWorkflow(context.Background(), config, runID)
+	if err == nil {
+		t.Fatal("expected the failed run to fail the step")
+	}
+	config.result.finish(err)
+	reportMetrics(config, err)
+
+	textfile, rerr := os.ReadFile(config.MetricsTextfile)
+	if rerr != nil {
+		t.Fatalf("failed to read textfile: %v", rerr)
+	}
+	for _, want := range []string{
+		"# TYPE trigwait_dispatch_latency_seconds gauge",
+		`trigwait_discovery_seconds{owner="owner",repo="repo",workflow="deploy.yml"} `,
+		`trigwait_run_seconds{owner="owner",repo="repo",workflow="deploy.yml"} `,
+		`trigwait_runs{owner="owner",repo="repo",workflow="deploy.yml",conclusion="failure"} 1`,
+		`trigwait_errors{owner="owner",repo="repo",workflow="deploy.yml",error_kind="downstream-failed"} 1`,
+		`trigwait_api_errors{owner="owner",repo="repo",workflow="deploy.yml"} 0`,
+		`trigwait_api_retries{owner="owner",repo="repo",workflow="deploy.yml"} 0`,
+	} {
+		if !strings.Contains(string(textfile), want) {
+			t.Errorf("textfile missing %q:\n%s", want, textfile)
+		}
+	}
+
+	if pushMethod != "PUT" || pushPath != "/metrics/job/trigwait/owner/owner/repo/repo/workflow/deploy.yml" {
+		t.Errorf("unexpected push %s %s", pushMethod, pushPath)
+	}
+	if pushBody != string(textfile) {
+		t.Errorf("expected the pushed body to match the textfile, got:\n%s", pushBody)
+	}
+
+	statsd.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, rerr := statsd.ReadFrom(buf)
+	if rerr != nil {
+		t.Fatalf("no StatsD datagram received: %v", rerr)
+	}
+	lines := strings.Split(string(buf[:n]), "\n")
+	for _, prefix := range []string{"trigwait.dispatch_latency:", "trigwait.run:", "trigwait.runs.failure:1|c", "trigwait.errors.downstream-failed:1|c"} {
+		found := false
+		for _, line := range lines {
+			found = found || strings.HasPrefix(line, prefix)
+		}
+		if !found {
+			t.Errorf("expected a StatsD line starting with %q, got %q", prefix, lines)
+		}
+	}
+	if strings.Contains(string(textfile), "_total{") {
+		t.Errorf("expected no gauge named _total:\n%s", textfile)
+	}
+	if requests := config.metrics.requests; requests < 3 {
+		t.Errorf("expected the API requests to be counted, got %d", requests)
+	}
+}
+
+func TestReportMetrics_ExportFailure(t *testing.T) {
+	config := &Config{
+		MetricsTextfile:    filepath.Join(t.TempDir(), "missing", "trigwait.prom"),
+		MetricsPushgateway: "http://127.0.0.1:1",
+		metrics:            &metrics{},
+	}
+	config.result = newResult(config)
+	config.result.finish(nil)
+
+	// Failures are only logged
+	reportMetrics(config, nil)
+}
+
+func TestMetrics_Retries(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"id": 1, "status": "completed", "conclusion": "success"}`))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Owner:        "owner",
+		Repo:         "repo",
+		GitHubAPIURL: server.URL,
+		WaitInterval: 10 * time.Millisecond,
+		metrics:      &metrics{},
+	}
+	if _, err := newClient(config).Wait(context.Background(), 1); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if config.metrics.retries != 2 || config.metrics.errors != 2 {
+		t.Errorf("expected 2 failed requests and 2 retries, got %d and %d", config.metrics.errors, config.metrics.retries)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// defaultNotifyTemplate is used when notify_template is not set.
+const defaultNotifyTemplate = `{{.Icon}} {{.Owner}}/{{.Repo}} {{.Workflow}} @ {{.Ref}} finished with {{.Conclusion}} in {{.Duration}}: {{.URL}}`
+
+// Notifier posts a message about a completed run to an incoming webhook.
+type Notifier struct {
+	URL string
+	// Type is slack, teams or json.
+	Type     string
+	Template *template.Template
+	// On lists the conclusions to notify about; empty means all.
+	On []string
+}
+
+// Notification holds the fields available to notify_template.
+type Notification struct {
+	Owner      string  `json:"owner"`
+	Repo       string  `json:"repo"`
+	Workflow   string  `json:"workflow"`
+	Ref        string  `json:"ref"`
+	RunID      int64   `json:"run_id"`
+	URL        string  `json:"url"`
+	Conclusion string  `json:"conclusion"`
+	DistinctID string  `json:"distinct_id,omitempty"`
+	Seconds    float64 `json:"duration_seconds"`
+	// Duration is the rounded run time, e.g. 4m12s.
+	Duration string `json:"-"`
+	// Icon is an emoji for the conclusion.
+	Icon string `json:"-"`
+	Text string `json:"text"`
+}
+
+// newNotifier builds the notifier described by the notify_* inputs, or
+// returns nil when notify_url is not set.
+func newNotifier(url, kind, tmpl string, on []string) (*Notifier, error) {
+	if url == "" {
+		return nil, nil
+	}
+	if kind == "" {
+		kind = detectNotifyType(url)
+	}
+	switch kind {
+	case "slack", "teams", "json":
+	default:
+		return nil, fmt.Errorf("invalid notify_type %q: must be slack, teams or json", kind)
+	}
+	if tmpl == "" {
+		tmpl = defaultNotifyTemplate
+	}
+	parsed, err := template.New("notify").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notify_template: %w", err)
+	}
+	if err := parsed.Execute(&bytes.Buffer{}, Notification{}); err != nil {
+		return nil, fmt.Errorf("invalid notify_template: %w", err)
+	}
+	if err := trigwait.ValidateConclusions(on); err != nil {
+		return nil, fmt.Errorf("invalid notify_on: %w", err)
+	}
+	return &Notifier{URL: url, Type: kind, Template: parsed, On: on}, nil
+}
+
+// detectNotifyType guesses the webhook type from well-known hosts.
+func detectNotifyType(url string) string {
+	switch {
+	case strings.Contains(url, "hooks.slack.com/"):
+		return "slack"
+	case strings.Contains(url, ".webhook.office.com/"), strings.Contains(url, ".logic.azure.com"):
+		return "teams"
+	}
+	return "json"
+}
+
+// notify posts the message for a completed run if its conclusion is one
+// notify_on selects.
+func notify(ctx context.Context, config *Config, run *trigwait.WorkflowRun) {
+	n := config.Notifier
+	if n == nil {
+		return
+	}
+	if len(n.On) > 0 && !inListFold(n.On, run.Conclusion) {
+		logf(trigwait.LevelDebug, "Not notifying about conclusion %s", run.Conclusion)
+		return
+	}
+
+	msg := Notification{
+		Owner:      config.Owner,
+		Repo:       config.Repo,
+		Workflow:   config.WorkflowFileName,
+		Ref:        config.Ref,
+		RunID:      run.ID,
+		URL:        newClient(config).RunURL(run.ID),
+		Conclusion: run.Conclusion,
+		DistinctID: config.DistinctID,
+		Seconds:    config.runTime.Seconds(),
+		Duration:   config.runTime.Round(time.Second).String(),
+		Icon:       conclusionIcon(run.Conclusion),
+	}
+	var text bytes.Buffer
+	if err := n.Template.Execute(&text, msg); err != nil {
+		logf(trigwait.LevelWarn, "⚠ Failed to render notification: %v", err)
+		return
+	}
+	msg.Text = text.String()
+
+	if err := n.post(ctx, n.payload(&msg)); err != nil {
+		logf(trigwait.LevelWarn, "⚠ Failed to send notification: %v", err)
+		return
+	}
+	logf(trigwait.LevelInfo, "📣 Sent %s notification", n.Type)
+}
+
+// notifyFailed notifies about a run that was still going when waiting gave
+// up because of wait_timeout or an SLO breach, as if it had concluded
+// timed_out. Other errors, such as the step being cancelled, are not
+// notified.
+func notifyFailed(ctx context.Context, config *Config, runID int64, err error) {
+	switch trigwait.KindOf(err) {
+	case trigwait.KindWaitTimeout, trigwait.KindSLOBreached:
+	default:
+		return
+	}
+	// The step's context may be the one that was cancelled
+	notify(context.WithoutCancel(ctx), config, &trigwait.WorkflowRun{ID: runID, Conclusion: "timed_out"})
+}
+
+// payload returns the request body for the notifier's webhook type.
+func (n *Notifier) payload(msg *Notification) interface{} {
+	switch n.Type {
+	case "slack":
+		return map[string]interface{}{"text": msg.Text}
+	case "teams":
+		return map[string]interface{}{
+			"type": "message",
+			"attachments": []interface{}{map[string]interface{}{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    []interface{}{map[string]interface{}{"type": "TextBlock", "text": msg.Text, "wrap": true}},
+					"actions": []interface{}{map[string]interface{}{"type": "Action.OpenUrl", "title": "View run", "url": msg.URL}},
+				},
+			}},
+		}
+	}
+	return msg
+}
+
+func (n *Notifier) post(ctx context.Context, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func conclusionIcon(conclusion string) string {
+	switch conclusion {
+	case "success":
+		return "✅"
+	case "cancelled", "skipped", "neutral":
+		return "⚪"
+	}
+	return "❌"
+}
+
+func inListFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func TestWaitForWorkflow_Notify(t *testing.T) {
+	tests := []struct {
+		name       string
+		kind       string
+		template   string
+		on         []string
+		conclusion string
+		wantSent   bool
+		wantText   string
+	}{
+		{name: "slack", kind: "slack", conclusion: "success", wantSent: true, wantText: "✅ owner/repo deploy.yml @ main finished with success in "},
+		{name: "teams", kind: "teams", conclusion: "failure", wantSent: true, wantText: "❌ owner/repo deploy.yml @ main finished with failure"},
+		{name: "json", kind: "json", template: "{{.Workflow}} #{{.RunID}}: {{.Conclusion}}", conclusion: "cancelled", wantSent: true, wantText: "deploy.yml #"},
+		{name: "filtered", kind: "slack", on: []string{"failure", "cancelled"}, conclusion: "success"},
+		{name: "selected", kind: "slack", on: []string{"failure"}, conclusion: "failure", wantSent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+			}))
+			defer webhook.Close()
+
+			_, config := newDeployFake(t, fakegh.Workflow{Conclusion: tt.conclusion})
+			notifier, err := newNotifier(webhook.URL, tt.kind, tt.template, tt.on)
+			if err != nil {
+				t.Fatalf("newNotifier failed: %v", err)
+			}
+			config.Notifier = notifier
+
+			runID, err := triggerWorkflow(context.Background(), config)
+			if err != nil {
+				t.Fatalf("triggerWorkflow failed: %v", err)
+			}
+			waitForWorkflow(context.Background(), config, runID)
+
+			if sent := body != nil; sent != tt.wantSent {
+				t.Fatalf("expected sent=%v, got %s", tt.wantSent, body)
+			}
+			if !tt.wantSent {
+				return
+			}
+
+			var text string
+			switch tt.kind {
+			case "slack":
+				var payload struct{ Text string }
+				json.Unmarshal(body, &payload)
+				text = payload.Text
+			case "teams":
+				var payload struct {
+					Attachments []struct {
+						Content struct {
+							Body []struct{ Text string }
+						}
+					}
+				}
+				json.Unmarshal(body, &payload)
+				if len(payload.Attachments) == 1 && len(payload.Attachments[0].Content.Body) == 1 {
+					text = payload.Attachments[0].Content.Body[0].Text
+				}
+			case "json":
+				var payload Notification
+				json.Unmarshal(body, &payload)
+				if payload.RunID != runID || payload.Conclusion != tt.conclusion || payload.Repo != "repo" || !strings.HasSuffix(payload.URL, "/runs/"+strconv.FormatInt(runID, 10)) {
+					t.Errorf("unexpected payload %s", body)
+				}
+				text = payload.Text
+			}
+			if !strings.HasPrefix(text, tt.wantText) {
+				t.Errorf("expected text starting with %q, got %q", tt.wantText, text)
+			}
+		})
+	}
+}
+
+func TestWaitForWorkflow_NotifyTimeout(t *testing.T) {
+	var body []byte
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer webhook.Close()
+
+	_, config := newDeployFake(t, fakegh.Workflow{QueueTime: fakegh.Duration(time.Hour)})
+	config.WaitTimeout = 50 * time.Millisecond
+	notifier, err := newNotifier(webhook.URL, "json", "", []string{"failure", "timed_out"})
+	if err != nil {
+		t.Fatalf("newNotifier failed: %v", err)
+	}
+	config.Notifier = notifier
+
+	runID, err := triggerWorkflow(context.Background(), config)
+	if err != nil {
+		t.Fatalf("triggerWorkflow failed: %v", err)
+	}
+	if err := waitForWorkflow(context.Background(), config, runID); trigwait.KindOf(err) != trigwait.KindWaitTimeout {
+		t.Fatalf("expected %s, got %v", trigwait.KindWaitTimeout, err)
+	}
+
+	var payload Notification
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("expected a notification, got %q: %v", body, err)
+	}
+	if payload.RunID != runID || payload.Conclusion != "timed_out" || !strings.HasPrefix(payload.Text, "❌ ") {
+		t.Errorf("unexpected payload %s", body)
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	if n, err := newNotifier("", "", "", nil); n != nil || err != nil {
+		t.Errorf("expected no notifier without a URL, got %v, %v", n, err)
+	}
+	detect := map[string]string{
+		"https://hooks.slack.com/services/T0/B0/x":             "slack",
+		"https://example.webhook.office.com/webhookb2/x":       "teams",
+		"https://prod-01.westus.logic.azure.com:443/workflows": "teams",
+		"https://ci.example.com/hooks/deploys":                 "json",
+	}
+	for url, want := range detect {
+		if n, err := newNotifier(url, "", "", nil); err != nil || n.Type != want {
+			t.Errorf("expected %s for %s, got %+v (%v)", want, url, n, err)
+		}
+	}
+
+	invalid := []struct {
+		kind, template string
+		on             []string
+	}{
+		{kind: "discord"},
+		{template: "{{.Unknown}}"},
+		{template: "{{.Conclusion"},
+		{on: []string{"failed"}},
+	}
+	for _, tt := range invalid {
+		if _, err := newNotifier("https://example.com", tt.kind, tt.template, tt.on); err == nil {
+			t.Errorf("expected error for %+v", tt)
+		}
+	}
+}
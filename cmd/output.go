@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// OutputSink receives the action's outputs (workflow_id, conclusion, ...).
+type OutputSink interface {
+	Set(name, value string) error
+}
+
+// outputSinks are the sinks setOutput writes to. main replaces them with the
+// sinks selected by the output_sinks input.
+var outputSinks = []OutputSink{githubOutputSink{}}
+
+// githubOutputSink appends outputs to the file named by GITHUB_OUTPUT.
+// It silently does nothing when GITHUB_OUTPUT is not set.
+type githubOutputSink struct{}
+
+func (githubOutputSink) Set(name, value string) error {
+	outputFile := os.Getenv("GITHUB_OUTPUT")
+	if outputFile == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open GITHUB_OUTPUT: %w", err)
+	}
+	defer f.Close()
+
+	// Multi-line values need the heredoc syntax, with a delimiter that
+	// cannot appear in the value itself.
+	if strings.ContainsAny(value, "\r\n") {
+		delimiter := "ghadelimiter_" + randomHex(16)
+		_, err = fmt.Fprintf(f, "%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter)
+		return err
+	}
+
+	_, err = fmt.Fprintf(f, "%s=%s\n", name, value)
+	return err
+}
+
+// jsonFileSink keeps all outputs in a single JSON object, rewriting the file
+// on every update so it is complete even if the process is killed.
+type jsonFileSink struct {
+	path string
+
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func newJSONFileSink(path string) *jsonFileSink {
+	return &jsonFileSink{path: path, values: make(map[string]string)}
+}
+
+func (s *jsonFileSink) Set(name, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[name] = value
+	data, err := json.MarshalIndent(s.values, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, append(data, '\n'), 0644)
+}
+
+// dotenvSink appends outputs as NAME="value" lines that can be sourced by a
+// shell or loaded as a GitLab CI dotenv report.
+type dotenvSink struct {
+	path string
+}
+
+func (s dotenvSink) Set(name, value string) error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s=%s\n", strings.ToUpper(name), dotenvQuote(value))
+	return err
+}
+
+// writerSink prints outputs as name=value lines, one per output. Values
+// that contain whitespace or shell metacharacters are quoted like dotenv
+// values, so multi-line values stay on one line. With json set, outputs are
+// printed as JSON objects instead, to match log_format: json on the same
+// stream.
+type writerSink struct {
+	w    io.Writer
+	json bool
+}
+
+func (s writerSink) Set(name, value string) error {
+	if s.json {
+		line, _ := json.Marshal(struct {
+			Output string `json:"output"`
+			Value  string `json:"value"`
+		}{name, value})
+		_, err := fmt.Fprintf(s.w, "%s\n", line)
+		return err
+	}
+	if strings.ContainsAny(value, " \t\r\n\"'\\$`#") {
+		value = dotenvQuote(value)
+	}
+	_, err := fmt.Fprintf(s.w, "%s=%s\n", name, value)
+	return err
+}
+
+// parseOutputSinks parses a comma-separated list of sinks. Each entry is a
+// sink kind, optionally followed by ":" and a file path:
+//
+//	github,json:result.json,dotenv:trigwait.env,stdout
+//
+// The stdout sink prints JSON lines when logFormat is json.
+func parseOutputSinks(spec string, logFormat trigwait.Format) ([]OutputSink, error) {
+	var sinks []OutputSink
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kind, path, _ := strings.Cut(entry, ":")
+		switch strings.ToLower(kind) {
+		case "github":
+			sinks = append(sinks, githubOutputSink{})
+		case "stdout":
+			sinks = append(sinks, writerSink{w: os.Stdout, json: logFormat == trigwait.FormatJSON})
+		case "json":
+			if path == "" {
+				return nil, fmt.Errorf("output sink %q requires a file path (json:<path>)", entry)
+			}
+			sinks = append(sinks, newJSONFileSink(path))
+		case "dotenv":
+			if path == "" {
+				return nil, fmt.Errorf("output sink %q requires a file path (dotenv:<path>)", entry)
+			}
+			sinks = append(sinks, dotenvSink{path: path})
+		default:
+			return nil, fmt.Errorf("unknown output sink %q (expected github, json, dotenv or stdout)", kind)
+		}
+	}
+	return sinks, nil
+}
+
+func setOutput(name, value string) {
+	for _, sink := range outputSinks {
+		if err := sink.Set(name, value); err != nil {
+			logf(trigwait.LevelWarn, "⚠ Failed to set output %s: %v", name, err)
+		}
+	}
+}
+
+// dotenvQuote double-quotes value, escaping characters that would otherwise
+// end the value or be expanded by a shell.
+func dotenvQuote(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"$", `\$`,
+		"`", "\\`",
+		"\n", `\n`,
+		"\r", `\r`,
+	)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
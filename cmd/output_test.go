@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func TestSetOutput(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "github_output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpFile.Name())
+	tmpFile.Close()
+
+	os.Setenv("GITHUB_OUTPUT", tmpFile.Name())
+	defer os.Unsetenv("GITHUB_OUTPUT")
+
+	setOutput("test_key", "test_value")
+	setOutput("workflow_id", "12345")
+
+	content, _ := os.ReadFile(tmpFile.Name())
+	if !contains(string(content), "test_key=test_value") {
+		t.Errorf("expected output to contain 'test_key=test_value', got: %s", string(content))
+	}
+	if !contains(string(content), "workflow_id=12345") {
+		t.Errorf("expected output to contain 'workflow_id=12345', got: %s", string(content))
+	}
+}
+
+func TestGitHubOutputSink_MultiLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "github_output")
+	os.Setenv("GITHUB_OUTPUT", path)
+	defer os.Unsetenv("GITHUB_OUTPUT")
+
+	if err := (githubOutputSink{}).Set("summary", "line one\nline two"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d: %q", len(lines), string(content))
+	}
+	name, delimiter, ok := strings.Cut(lines[0], "<<")
+	if !ok || name != "summary" || !strings.HasPrefix(delimiter, "ghadelimiter_") {
+		t.Errorf("unexpected heredoc header: %q", lines[0])
+	}
+	if lines[1] != "line one" || lines[2] != "line two" {
+		t.Errorf("unexpected heredoc body: %q", lines[1:3])
+	}
+	if lines[3] != delimiter {
+		t.Errorf("expected closing delimiter %q, got %q", delimiter, lines[3])
+	}
+}
+
+func TestJSONFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.json")
+	sink := newJSONFileSink(path)
+
+	sink.Set("workflow_id", "12345")
+	sink.Set("conclusion", "success")
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values map[string]string
+	if err := json.Unmarshal(content, &values); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if values["workflow_id"] != "12345" || values["conclusion"] != "success" {
+		t.Errorf("unexpected values: %v", values)
+	}
+}
+
+func TestDotenvSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trigwait.env")
+	sink := dotenvSink{path: path}
+
+	sink.Set("workflow_url", "https://github.com/o/r/actions/runs/1")
+	sink.Set("note", "say \"hi\"\n$HOME")
+
+	content, _ := os.ReadFile(path)
+	expected := "WORKFLOW_URL=\"https://github.com/o/r/actions/runs/1\"\n" +
+		"NOTE=\"say \\\"hi\\\"\\n\\$HOME\"\n"
+	if string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, string(content))
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := writerSink{w: &buf}
+
+	sink.Set("conclusion", "failure")
+	sink.Set("summary", "line one\nline \"two\"")
+
+	want := "conclusion=failure\n" + `summary="line one\nline \"two\""` + "\n"
+	if buf.String() != want {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+
+	buf.Reset()
+	sink = writerSink{w: &buf, json: true}
+	sink.Set("summary", "a\nb")
+	if buf.String() != `{"output":"summary","value":"a\nb"}`+"\n" {
+		t.Errorf("unexpected JSON output: %q", buf.String())
+	}
+}
+
+func TestParseOutputSinks(t *testing.T) {
+	sinks, err := parseOutputSinks("github, json:out.json,dotenv:out.env,stdout", trigwait.FormatPretty)
+	if err != nil {
+		t.Fatalf("parseOutputSinks failed: %v", err)
+	}
+	if len(sinks) != 4 {
+		t.Fatalf("expected 4 sinks, got %d", len(sinks))
+	}
+	if _, ok := sinks[1].(*jsonFileSink); !ok {
+		t.Errorf("expected json sink, got %T", sinks[1])
+	}
+	if s, ok := sinks[2].(dotenvSink); !ok || s.path != "out.env" {
+		t.Errorf("expected dotenv sink for out.env, got %#v", sinks[2])
+	}
+
+	for _, spec := range []string{"json", "dotenv", "s3:bucket"} {
+		if _, err := parseOutputSinks(spec, trigwait.FormatPretty); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
@@ -0,0 +1,632 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/internal/miniyaml"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// Pipeline is a DAG of workflow dispatches read from pipeline_file.
+type Pipeline struct {
+	// MaxParallel limits how many stages run at once; 0 means no limit.
+	MaxParallel int
+	// Stages are in dependency order.
+	Stages []*Stage
+	// Matrix is set when the stages were expanded from the matrix input.
+	Matrix bool
+}
+
+// Stage is one workflow dispatch of a pipeline.
+type Stage struct {
+	Name           string
+	Owner          string
+	Repo           string
+	Workflow       string
+	Ref            string
+	Needs          []string
+	Inputs         map[string]interface{}
+	DistinctIDName string
+	Wait           bool
+	// Matrix is the combination of matrix values the stage dispatches.
+	Matrix map[string]interface{}
+}
+
+// StageReport is the outcome of a stage in the pipeline_report and
+// matrix_report outputs.
+type StageReport struct {
+	Status          string                 `json:"status"`
+	Matrix          map[string]interface{} `json:"matrix,omitempty"`
+	Owner           string                 `json:"owner"`
+	Repo            string                 `json:"repo"`
+	Workflow        string                 `json:"workflow"`
+	Ref             string                 `json:"ref"`
+	WorkflowID      int64                  `json:"workflow_id,omitempty"`
+	WorkflowURL     string                 `json:"workflow_url,omitempty"`
+	Conclusion      string                 `json:"conclusion,omitempty"`
+	DistinctID      string                 `json:"distinct_id,omitempty"`
+	DurationSeconds float64                `json:"duration_seconds"`
+	ErrorKind       string                 `json:"error_kind,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+
+	err error
+}
+
+// Stage statuses in the pipeline report.
+const (
+	stageSuccess = "success"
+	stageFailed  = "failed"
+	stageSkipped = "skipped"
+)
+
+// stageOutputs are the outputs of a stage that later stages can reference
+// as ${{ stages.<name>.outputs.<output> }}.
+var stageOutputs = []string{"workflow_id", "workflow_url", "conclusion", "distinct_id", "ref"}
+
+var (
+	stageNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+	stageExprPattern = regexp.MustCompile(`\$\{\{\s*stages\.([A-Za-z0-9_-]+)\.outputs\.([A-Za-z0-9_]+)\s*\}\}`)
+	stageKeys        = []string{"owner", "repo", "workflow", "ref", "needs", "inputs", "distinct_id_name", "wait"}
+	pipelineKeys     = []string{"defaults", "max_parallel", "stages"}
+	pipelineDefaults = []string{"owner", "repo", "ref", "distinct_id_name", "wait"}
+)
+
+// loadPipeline reads and validates a pipeline file. Stage settings that are
+// not given in the file default to the pipeline's defaults, then to the
+// action inputs.
+func loadPipeline(path string, config *Config) (*Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline_file: %w", err)
+	}
+	pipeline, err := parsePipeline(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pipeline_file %s: %w", path, err)
+	}
+	return pipeline, nil
+}
+
+func parsePipeline(data []byte, config *Config) (*Pipeline, error) {
+	doc, err := miniyaml.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a mapping with stages")
+	}
+	if err := checkKeys(root, pipelineKeys, "pipeline"); err != nil {
+		return nil, err
+	}
+
+	base := Stage{
+		Owner:          config.Owner,
+		Repo:           config.Repo,
+		Ref:            config.Ref,
+		DistinctIDName: config.DistinctIDName,
+		Wait:           config.WaitWorkflow,
+	}
+	if defaults, ok := root["defaults"]; ok {
+		m, ok := defaults.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("defaults: expected a mapping")
+		}
+		if err := checkKeys(m, pipelineDefaults, "defaults"); err != nil {
+			return nil, err
+		}
+		if err := applyStageSettings(&base, m, "defaults"); err != nil {
+			return nil, err
+		}
+	}
+
+	pipeline := &Pipeline{}
+	if v, ok := root["max_parallel"]; ok {
+		n, ok := v.(int64)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("max_parallel: expected a non-negative integer")
+		}
+		pipeline.MaxParallel = int(n)
+	}
+
+	stagesMap, ok := root["stages"].(map[string]interface{})
+	if !ok || len(stagesMap) == 0 {
+		return nil, fmt.Errorf("stages: expected a mapping of stage names to stages")
+	}
+	stages := make(map[string]*Stage, len(stagesMap))
+	for name, v := range stagesMap {
+		if !stageNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("stage name %q must start with a letter or _ and contain only letters, digits, - and _", name)
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("stage %s: expected a mapping", name)
+		}
+		if err := checkKeys(m, stageKeys, "stage "+name); err != nil {
+			return nil, err
+		}
+		stage := base
+		stage.Name = name
+		stage.Inputs = map[string]interface{}{}
+		if err := applyStageSettings(&stage, m, "stage "+name); err != nil {
+			return nil, err
+		}
+		for _, required := range []struct{ key, value string }{
+			{"owner", stage.Owner}, {"repo", stage.Repo}, {"workflow", stage.Workflow}, {"ref", stage.Ref},
+		} {
+			// ref_mode picks the ref of stages without one
+			refResolved := required.key == "ref" && config.RefMode != "" && config.RefMode != trigwait.RefExplicit
+			if required.value == "" && !refResolved {
+				return nil, fmt.Errorf("stage %s: %s is required", name, required.key)
+			}
+		}
+		stages[name] = &stage
+	}
+
+	ordered, err := orderStages(stages)
+	if err != nil {
+		return nil, err
+	}
+	pipeline.Stages = ordered
+
+	for _, stage := range ordered {
+		if err := checkStageReferences(stage, stages); err != nil {
+			return nil, err
+		}
+	}
+	return pipeline, nil
+}
+
+// applyStageSettings sets the stage fields present in m.
+func applyStageSettings(stage *Stage, m map[string]interface{}, where string) error {
+	for key, v := range m {
+		var err error
+		switch key {
+		case "owner":
+			stage.Owner, err = scalarString(v)
+		case "repo":
+			stage.Repo, err = scalarString(v)
+		case "workflow":
+			stage.Workflow, err = scalarString(v)
+		case "ref":
+			stage.Ref, err = scalarString(v)
+		case "distinct_id_name":
+			stage.DistinctIDName, err = scalarString(v)
+		case "wait":
+			var ok bool
+			if stage.Wait, ok = v.(bool); !ok {
+				err = fmt.Errorf("expected true or false")
+			}
+		case "needs":
+			stage.Needs, err = stringList(v)
+		case "inputs":
+			inputs, ok := v.(map[string]interface{})
+			if !ok && v != nil {
+				err = fmt.Errorf("expected a mapping")
+			}
+			stage.Inputs = inputs
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", where, key, err)
+		}
+	}
+	return nil
+}
+
+// orderStages sorts stages so every stage comes after the stages it needs,
+// breaking ties by name.
+func orderStages(stages map[string]*Stage) ([]*Stage, error) {
+	pending := make(map[string]int, len(stages))
+	dependents := make(map[string][]string)
+	for name, stage := range stages {
+		for _, need := range stage.Needs {
+			if _, ok := stages[need]; !ok {
+				return nil, fmt.Errorf("stage %s needs unknown stage %q", name, need)
+			}
+			dependents[need] = append(dependents[need], name)
+		}
+		pending[name] = len(stage.Needs)
+	}
+
+	var ready, ordered []string
+	for name, n := range pending {
+		if n == 0 {
+			ready = append(ready, name)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, name)
+		for _, dependent := range dependents[name] {
+			if pending[dependent]--; pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(ordered) < len(stages) {
+		var cycle []string
+		for name, n := range pending {
+			if n > 0 {
+				cycle = append(cycle, name)
+			}
+		}
+		sort.Strings(cycle)
+		return nil, fmt.Errorf("stages %s depend on each other in a cycle", strings.Join(cycle, ", "))
+	}
+
+	result := make([]*Stage, len(ordered))
+	for i, name := range ordered {
+		result[i] = stages[name]
+	}
+	return result, nil
+}
+
+// checkStageReferences makes sure expressions in the stage's inputs only
+// reference known outputs of stages the stage needs, directly or indirectly.
+func checkStageReferences(stage *Stage, stages map[string]*Stage) error {
+	upstream := map[string]bool{}
+	var visit func(names []string)
+	visit = func(names []string) {
+		for _, name := range names {
+			if !upstream[name] {
+				upstream[name] = true
+				visit(stages[name].Needs)
+			}
+		}
+	}
+	visit(stage.Needs)
+
+	var err error
+	walkStrings(stage.Inputs, func(s string) string {
+		for _, match := range stageExprPattern.FindAllStringSubmatch(s, -1) {
+			switch {
+			case err != nil:
+			case !upstream[match[1]]:
+				err = fmt.Errorf("stage %s: %s references stage %q, which is not in its needs", stage.Name, match[0], match[1])
+			case !inList(stageOutputs, match[2]):
+				err = fmt.Errorf("stage %s: %s references unknown output %q (available: %s)", stage.Name, match[0], match[2], strings.Join(stageOutputs, ", "))
+			}
+		}
+		return s
+	})
+	return err
+}
+
+// walkStrings replaces every string in v, recursing into maps and lists.
+func walkStrings(v interface{}, fn func(string) string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return fn(v)
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = walkStrings(item, fn)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = walkStrings(item, fn)
+		}
+	}
+	return v
+}
+
+// expandInputs returns a copy of the stage's inputs with references to
+// upstream outputs replaced by their values.
+func expandInputs(inputs map[string]interface{}, outputs map[string]map[string]string) map[string]interface{} {
+	// Round-trip through JSON for a deep copy
+	data, _ := json.Marshal(inputs)
+	var expanded map[string]interface{}
+	json.Unmarshal(data, &expanded)
+	if expanded == nil {
+		expanded = map[string]interface{}{}
+	}
+	walkStrings(expanded, func(s string) string {
+		return stageExprPattern.ReplaceAllStringFunc(s, func(expr string) string {
+			match := stageExprPattern.FindStringSubmatch(expr)
+			return outputs[match[1]][match[2]]
+		})
+	})
+	return expanded
+}
+
+func checkKeys(m map[string]interface{}, allowed []string, where string) error {
+	for key := range m {
+		if !inList(allowed, key) {
+			return fmt.Errorf("%s: unknown key %q (expected one of %s)", where, key, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+func inList(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func scalarString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case nil:
+		return "", nil
+	}
+	return "", fmt.Errorf("expected a string")
+}
+
+// stringList accepts a single string or a list of strings.
+func stringList(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of stage names")
+			}
+			items = append(items, s)
+		}
+		return items, nil
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("expected a stage name or a list of stage names")
+}
+
+// runPipeline runs the stages of config.Pipeline, each as soon as the stages
+// it needs have succeeded, and reports the outcome of every stage. Stages
+// whose needs did not succeed are skipped.
+func runPipeline(ctx context.Context, config *Config) error {
+	pipeline := config.Pipeline
+	if pipeline.Matrix {
+		logf(trigwait.LevelInfo, "🗂 Running matrix of %d combinations", len(pipeline.Stages))
+	} else {
+		logf(trigwait.LevelInfo, "🗂 Running pipeline of %d stages", len(pipeline.Stages))
+	}
+	if config.cache == nil {
+		// Shared by all stages so the request count covers the pipeline
+		config.cache = trigwait.NewResponseCache()
+	}
+
+	var (
+		mu      sync.Mutex
+		reports = make(map[string]*StageReport, len(pipeline.Stages))
+		outputs = make(map[string]map[string]string, len(pipeline.Stages))
+		done    = make(map[string]chan struct{}, len(pipeline.Stages))
+		slots   chan struct{}
+		wg      sync.WaitGroup
+	)
+	if pipeline.MaxParallel > 0 {
+		slots = make(chan struct{}, pipeline.MaxParallel)
+	}
+	for _, stage := range pipeline.Stages {
+		done[stage.Name] = make(chan struct{})
+	}
+
+	for _, stage := range pipeline.Stages {
+		wg.Add(1)
+		go func(stage *Stage) {
+			defer wg.Done()
+			defer close(done[stage.Name])
+
+			report := &StageReport{Matrix: stage.Matrix, Owner: stage.Owner, Repo: stage.Repo, Workflow: stage.Workflow, Ref: stage.Ref}
+			var failed []string
+			for _, need := range stage.Needs {
+				<-done[need]
+				mu.Lock()
+				if reports[need].Status != stageSuccess {
+					failed = append(failed, need)
+				}
+				mu.Unlock()
+			}
+
+			if len(failed) > 0 {
+				report.Status = stageSkipped
+				report.Error = fmt.Sprintf("needs %s, which did not succeed", strings.Join(failed, ", "))
+			} else {
+				if slots != nil {
+					slots <- struct{}{}
+					defer func() { <-slots }()
+				}
+				mu.Lock()
+				inputs := expandInputs(stage.Inputs, outputs)
+				mu.Unlock()
+				runStage(ctx, config, stage, inputs, report)
+			}
+
+			mu.Lock()
+			reports[stage.Name] = report
+			outputs[stage.Name] = map[string]string{
+				"workflow_id":  strconv.FormatInt(report.WorkflowID, 10),
+				"workflow_url": report.WorkflowURL,
+				"conclusion":   report.Conclusion,
+				"distinct_id":  report.DistinctID,
+				"ref":          report.Ref,
+			}
+			mu.Unlock()
+		}(stage)
+	}
+	wg.Wait()
+
+	return reportPipeline(pipeline, reports)
+}
+
+// runStage dispatches the stage's workflow and waits for it, recording the
+// outcome in report.
+func runStage(ctx context.Context, config *Config, stage *Stage, inputs map[string]interface{}, report *StageReport) {
+	start := time.Now()
+	defer func() {
+		report.DurationSeconds = time.Since(start).Seconds()
+		switch {
+		case report.err != nil:
+			report.Status = stageFailed
+			report.ErrorKind = string(trigwait.KindOf(report.err))
+			report.Error = report.err.Error()
+		default:
+			report.Status = stageSuccess
+		}
+	}()
+
+	stageConfig, err := stageConfigFor(ctx, config, stage, inputs)
+	if err != nil {
+		report.err = err
+		return
+	}
+	report.DistinctID = stageConfig.DistinctID
+	report.Workflow, report.Ref = stageConfig.WorkflowFileName, stageConfig.Ref
+	client := newClient(stageConfig)
+
+	// Stages are checked and dispatched like a single workflow
+	checked, err := preflight(ctx, client, stageConfig)
+	if err != nil {
+		report.err = err
+		return
+	}
+	runID, err := client.Trigger(ctx, dispatchFor(stageConfig))
+	if err != nil {
+		report.err = explainDispatchError(ctx, client, stageConfig, checked, err)
+		return
+	}
+	report.WorkflowID = runID
+	report.WorkflowURL = client.RunURL(runID)
+	if !stage.Wait {
+		return
+	}
+
+	run, err := client.Wait(ctx, runID)
+	if err != nil {
+		report.err = err
+		return
+	}
+	report.Conclusion = run.Conclusion
+	if !config.PropagateFailure {
+		return
+	}
+	var jobs []trigwait.Job
+	if config.Policy.NeedsJobs(run) {
+		if jobs, err = client.ListJobs(ctx, runID); err != nil {
+			stageLogger(stage.Name).Log(trigwait.LevelWarn, fmt.Sprintf("⚠ Could not list jobs: %v", err))
+		}
+	}
+	report.err = config.Policy.Evaluate(run, jobs)
+}
+
+// stageConfigFor returns the configuration of a stage dispatched with
+// inputs: its target, a fresh distinct ID, and its workflow and ref resolved
+// like those of a single workflow.
+func stageConfigFor(ctx context.Context, config *Config, stage *Stage, inputs map[string]interface{}) (*Config, error) {
+	stageConfig := *config
+	stageConfig.stage = stage.Name
+	stageConfig.result = nil
+	stageConfig.Owner = stage.Owner
+	stageConfig.Repo = stage.Repo
+	stageConfig.WorkflowFileName = stage.Workflow
+	stageConfig.Ref = stage.Ref
+	stageConfig.DistinctIDName = stage.DistinctIDName
+	stageConfig.DistinctID = ""
+	stageConfig.ClientPayload = removeEmptyValues(inputs)
+	if stage.DistinctIDName != "" {
+		id, err := trigwait.NewDistinctID(config.DistinctIDFormat)
+		if err != nil {
+			return nil, err
+		}
+		stageConfig.DistinctID = id
+		stageConfig.ClientPayload[stage.DistinctIDName] = stageConfig.DistinctID
+	}
+	if err := resolveWorkflow(ctx, &stageConfig); err != nil {
+		return nil, err
+	}
+	if err := resolveRef(ctx, &stageConfig); err != nil {
+		return nil, err
+	}
+	return &stageConfig, nil
+}
+
+// stageLogger returns a logger that prefixes messages with the stage name,
+// keeping the logs of parallel stages apart.
+func stageLogger(name string) trigwait.Logger {
+	return prefixLogger{prefix: "[" + name + "] "}
+}
+
+type prefixLogger struct {
+	prefix string
+}
+
+func (l prefixLogger) Log(level trigwait.Level, msg string) {
+	logger.Log(level, l.prefix+msg)
+}
+
+// reportPipeline logs a summary of the pipeline, sets the pipeline_report
+// output, or matrix_report for a matrix, and returns the error of the first
+// failed stage.
+func reportPipeline(pipeline *Pipeline, reports map[string]*StageReport) error {
+	title, noun := "Pipeline", "stages"
+	if pipeline.Matrix {
+		title, noun = "Matrix", "combinations"
+	}
+	logf(trigwait.LevelInfo, "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	logf(trigwait.LevelInfo, "📋 %s report", title)
+
+	var firstErr error
+	var failed int
+	for _, stage := range pipeline.Stages {
+		report := reports[stage.Name]
+		target := fmt.Sprintf("%s/%s → %s @ %s", report.Owner, report.Repo, report.Workflow, report.Ref)
+		duration := time.Duration(report.DurationSeconds * float64(time.Second)).Round(time.Second)
+		switch report.Status {
+		case stageSuccess:
+			conclusion := report.Conclusion
+			if conclusion == "" {
+				conclusion = "dispatched"
+			}
+			logf(trigwait.LevelInfo, "   ✅ %s: %s (%s, %v)", stage.Name, target, conclusion, duration)
+		case stageFailed:
+			failed++
+			logf(trigwait.LevelError, "   ❌ %s: %s: %s", stage.Name, target, report.Error)
+			if firstErr == nil {
+				firstErr = &trigwait.Error{Kind: trigwait.KindOf(report.err), Err: fmt.Errorf("stage %s failed: %w", stage.Name, report.err)}
+			}
+		case stageSkipped:
+			logf(trigwait.LevelInfo, "   ⏭ %s: skipped (%s)", stage.Name, report.Error)
+		}
+		if report.WorkflowURL != "" {
+			logf(trigwait.LevelInfo, "      %s", report.WorkflowURL)
+		}
+	}
+
+	if pipeline.Matrix {
+		// One entry per combination, in matrix order
+		ordered := make([]*StageReport, len(pipeline.Stages))
+		for i, stage := range pipeline.Stages {
+			ordered[i] = reports[stage.Name]
+		}
+		data, _ := json.Marshal(ordered)
+		setOutput("matrix_report", string(data))
+	} else {
+		data, _ := json.Marshal(map[string]interface{}{"stages": reports})
+		setOutput("pipeline_report", string(data))
+	}
+
+	if firstErr != nil {
+		return &trigwait.Error{Kind: trigwait.KindOf(firstErr), Err: fmt.Errorf("%s failed: %d of %d %s failed; %w", strings.ToLower(title), failed, len(pipeline.Stages), noun, firstErr)}
+	}
+	logf(trigwait.LevelInfo, "✅ %s completed: %d %s succeeded", title, len(pipeline.Stages), noun)
+	return nil
+}
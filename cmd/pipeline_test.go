@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func TestParsePipeline(t *testing.T) {
+	data := []byte(`
+defaults:
+  owner: my-org
+  ref: release
+max_parallel: 2
+stages:
+  deploy:
+    repo: infra
+    workflow: deploy.yml
+    needs: [build, test]
+    inputs:
+      build: ${{ stages.build.outputs.workflow_id }}
+  test:
+    repo: tests
+    workflow: e2e.yml
+    needs: build
+    ref: main
+  build:
+    repo: app
+    workflow: build.yml
+    wait: false
+`)
+	config := &Config{Repo: "default-repo", Ref: "main", WaitWorkflow: true}
+
+	pipeline, err := parsePipeline(data, config)
+	if err != nil {
+		t.Fatalf("parsePipeline failed: %v", err)
+	}
+	var names []string
+	for _, stage := range pipeline.Stages {
+		names = append(names, stage.Name)
+	}
+	if got := strings.Join(names, ","); got != "build,test,deploy" {
+		t.Errorf("expected dependency order build,test,deploy, got %s", got)
+	}
+	build, test := pipeline.Stages[0], pipeline.Stages[1]
+	if build.Owner != "my-org" || build.Ref != "release" || build.Wait {
+		t.Errorf("expected defaults to apply to build, got %+v", build)
+	}
+	if test.Ref != "main" || !test.Wait {
+		t.Errorf("expected stage settings to override defaults, got %+v", test)
+	}
+	if pipeline.MaxParallel != 2 {
+		t.Errorf("expected max_parallel 2, got %d", pipeline.MaxParallel)
+	}
+}
+
+func TestParsePipeline_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"no stages", "defaults: {owner: o}", "stages"},
+		{"unknown key", "stages:\n  a: {workflow: a.yml, secret: x}", `unknown key "secret"`},
+		{"missing workflow", "stages:\n  a: {repo: r}", "workflow is required"},
+		{"unknown need", "stages:\n  a: {workflow: a.yml, needs: b}", `unknown stage "b"`},
+		{"cycle", "stages:\n  a: {workflow: a.yml, needs: b}\n  b: {workflow: b.yml, needs: a}", "cycle"},
+		{"reference outside needs", "stages:\n  a: {workflow: a.yml}\n  b:\n    workflow: b.yml\n    inputs: {x: '${{ stages.a.outputs.workflow_id }}'}", "not in its needs"},
+		{"unknown output", "stages:\n  a: {workflow: a.yml}\n  b:\n    workflow: b.yml\n    needs: a\n    inputs: {x: '${{ stages.a.outputs.artifact }}'}", `unknown output "artifact"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePipeline([]byte(tt.yaml), &Config{Owner: "o", Repo: "r", Ref: "main"})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRunPipeline(t *testing.T) {
+	sink := newJSONFileSink(filepath.Join(t.TempDir(), "outputs.json"))
+	outputSinks = []OutputSink{sink}
+	defer func() { outputSinks = []OutputSink{githubOutputSink{}} }()
+
+	fake, config := newFakeGitHub(t, &fakegh.Repo{
+		Owner: "owner",
+		Name:  "repo",
+		Workflows: []*fakegh.Workflow{
+			{Name: "Build", Path: "build.yml", RunName: "Build ${{ inputs.distinct_id }}"},
+			{Name: "Test", Path: "test.yml", RunName: "Test ${{ inputs.distinct_id }}"},
+			{Name: "Lint", Path: "lint.yml", RunName: "Lint ${{ inputs.distinct_id }}", Conclusion: "failure"},
+			{Name: "Deploy", Path: "deploy.yml", RunName: "Deploy ${{ inputs.distinct_id }}"},
+		},
+	})
+	config.WaitInterval = 10 * time.Millisecond
+	config.TriggerTimeout = 5 * time.Second
+	config.WaitWorkflow = true
+	config.PropagateFailure = true
+	config.Policy = trigwait.DefaultPolicy()
+	config.DistinctIDName = "distinct_id"
+
+	pipeline, err := parsePipeline([]byte(`
+stages:
+  build:
+    workflow: build.yml
+  test:
+    workflow: Test
+    needs: build
+    inputs:
+      build_run: ${{ stages.build.outputs.workflow_id }}
+  lint:
+    workflow: lint.yml
+  deploy:
+    workflow: deploy.yml
+    needs: [test, lint]
+`), config)
+	if err != nil {
+		t.Fatalf("parsePipeline failed: %v", err)
+	}
+	config.Pipeline = pipeline
+
+	err = runPipeline(context.Background(), config)
+	if kind := trigwait.KindOf(err); kind != trigwait.KindDownstreamFailed {
+		t.Fatalf("expected %s from the lint stage, got %s (%v)", trigwait.KindDownstreamFailed, kind, err)
+	}
+
+	var report struct {
+		Stages map[string]StageReport `json:"stages"`
+	}
+	if err := json.Unmarshal([]byte(sink.values["pipeline_report"]), &report); err != nil {
+		t.Fatalf("invalid pipeline_report: %v", err)
+	}
+	want := map[string]string{"build": stageSuccess, "test": stageSuccess, "lint": stageFailed, "deploy": stageSkipped}
+	for name, status := range want {
+		if got := report.Stages[name].Status; got != status {
+			t.Errorf("stage %s: expected %s, got %s", name, status, got)
+		}
+	}
+
+	runs := fake.Runs()
+	if len(runs) != 3 {
+		t.Fatalf("expected 3 dispatched runs, got %d", len(runs))
+	}
+	buildID := strconv.FormatInt(report.Stages["build"].WorkflowID, 10)
+	for _, run := range runs {
+		if strings.HasSuffix(run.Workflow.Path, "/test.yml") && run.Inputs["build_run"] != buildID {
+			t.Errorf("expected test to receive build run %s, got %v", buildID, run.Inputs["build_run"])
+		}
+	}
+}
+
+func TestRunPipeline_ChecksStages(t *testing.T) {
+	sink := newJSONFileSink(filepath.Join(t.TempDir(), "outputs.json"))
+	outputSinks = []OutputSink{sink}
+	defer func() { outputSinks = []OutputSink{githubOutputSink{}} }()
+
+	fake, config := newFakeGitHub(t, &fakegh.Repo{
+		Owner:         "owner",
+		Name:          "repo",
+		DefaultBranch: "trunk",
+		Workflows: []*fakegh.Workflow{
+			{Name: "Build", Path: "build.yml", Inputs: []string{"env"}},
+			{Name: "Deploy", Path: "deploy.yml", Inputs: []string{"env"}},
+		},
+	})
+	config.WaitInterval = 10 * time.Millisecond
+	config.TriggerTimeout = 5 * time.Second
+	config.ValidateInputs = true
+	// Stages without a ref are dispatched on the ref ref_mode picks
+	config.RefMode = trigwait.RefDefault
+	config.Ref = ""
+
+	pipeline, err := parsePipeline([]byte(`
+stages:
+  build:
+    workflow: build.yml
+    inputs: {env: prod}
+  deploy:
+    workflow: deploy.yml
+    inputs: {region: eu}
+`), config)
+	if err != nil {
+		t.Fatalf("parsePipeline failed: %v", err)
+	}
+	config.Pipeline = pipeline
+
+	if err := dryRunPipeline(context.Background(), config); trigwait.KindOf(err) != trigwait.KindDispatchRejected {
+		t.Errorf("expected the dry run to reject the undeclared input, got %v", err)
+	}
+	if runs := fake.Runs(); len(runs) != 0 {
+		t.Fatalf("expected the dry run to dispatch nothing, got %d runs", len(runs))
+	}
+
+	err = runPipeline(context.Background(), config)
+	if kind := trigwait.KindOf(err); kind != trigwait.KindDispatchRejected {
+		t.Fatalf("expected %s from the deploy stage, got %s (%v)", trigwait.KindDispatchRejected, kind, err)
+	}
+	runs := fake.Runs()
+	if len(runs) != 1 || !strings.HasSuffix(runs[0].Workflow.Path, "/build.yml") || runs[0].Ref != "trunk" {
+		t.Fatalf("expected only build to be dispatched on trunk, got %+v", runs)
+	}
+
+	var report struct {
+		Stages map[string]StageReport `json:"stages"`
+	}
+	json.Unmarshal([]byte(sink.values["pipeline_report"]), &report)
+	if ref := report.Stages["build"].Ref; ref != "trunk" {
+		t.Errorf("expected the report to show the resolved ref, got %q", ref)
+	}
+}
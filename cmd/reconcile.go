@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// Reconciler completes the commit statuses and check runs that earlier
+// steps left pending, typically with wait_workflow: false, once their
+// downstream runs finish.
+type Reconciler struct {
+	// Owner and Repo identify the calling repository.
+	Owner string
+	Repo  string
+	Token string
+	// Refs are the commits, branches or tags whose statuses are reconciled.
+	Refs []string
+}
+
+// ReconciledRun is an entry of the reconciled output.
+type ReconciledRun struct {
+	// Kind is status or check.
+	Kind       string `json:"kind"`
+	Context    string `json:"context"`
+	SHA        string `json:"sha"`
+	RunID      int64  `json:"run_id"`
+	URL        string `json:"url"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion,omitempty"`
+	// State is the commit status state or check run conclusion that was
+	// set, empty while the run has not completed.
+	State string `json:"state,omitempty"`
+}
+
+// pendingReport is a status or check run still waiting on a downstream run.
+type pendingReport struct {
+	*StatusReporter
+	// owner and repo identify the downstream repository.
+	owner string
+	repo  string
+}
+
+var (
+	// runURLPattern matches the run URLs statuses and check runs link to.
+	runURLPattern = regexp.MustCompile(`^/([^/]+)/([^/]+)/actions/runs/(\d+)$`)
+	// bracketedIDPattern matches the distinct ID ending a status description.
+	bracketedIDPattern = regexp.MustCompile(`\[([A-Za-z0-9._-]+)\]$`)
+)
+
+// newReconciler builds the reconciler described by the reconcile,
+// reconcile_refs and status_token inputs, or returns nil when reconcile is
+// off. refs defaults to the calling commit.
+func newReconciler(enabled bool, refs []string, token string) (*Reconciler, error) {
+	if !enabled {
+		return nil, nil
+	}
+	owner, repo, err := callerRepo("reconcile")
+	if err != nil {
+		return nil, err
+	}
+	if len(refs) == 0 && os.Getenv("GITHUB_EVENT_NAME") == "schedule" {
+		// GITHUB_SHA is then the tip of the default branch, not a commit
+		// statuses were reported on, so nothing would be reconciled
+		return nil, fmt.Errorf("reconcile on a schedule requires reconcile_refs; the calling commit is only the default branch tip")
+	}
+	if len(refs) == 0 {
+		if sha := callerSHA(); sha != "" {
+			refs = []string{sha}
+		}
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("reconcile requires reconcile_refs or GITHUB_SHA")
+	}
+	return &Reconciler{Owner: owner, Repo: repo, Token: token, Refs: refs}, nil
+}
+
+// reconcile completes the pending statuses and check runs on the reconciled
+// refs whose downstream runs have finished, and sets the reconciled output.
+// Only failing to list them fails the step; a run that cannot be checked is
+// logged and left pending for the next attempt.
+func reconcile(ctx context.Context, config *Config) error {
+	rc := config.Reconciler
+	client := callerClient(config, rc.Owner, rc.Repo, rc.Token)
+	logf(trigwait.LevelInfo, "🔄 Reconciling statuses on %s/%s", rc.Owner, rc.Repo)
+
+	reconciled := []ReconciledRun{}
+	for _, ref := range rc.Refs {
+		pending, err := pendingReports(ctx, config, client, ref)
+		if err != nil {
+			return fmt.Errorf("failed to list statuses on %s: %w", ref, err)
+		}
+		for _, p := range pending {
+			entry, err := reconcileRun(ctx, config, p)
+			if err != nil {
+				logf(trigwait.LevelWarn, "⚠ Could not reconcile %s %q: %v", p.Kind, p.Context, err)
+				continue
+			}
+			reconciled = append(reconciled, entry)
+		}
+	}
+
+	if len(reconciled) == 0 {
+		logf(trigwait.LevelInfo, "   Nothing to reconcile")
+	}
+	data, _ := json.Marshal(reconciled)
+	setOutput("reconciled", string(data))
+	return nil
+}
+
+// pendingReports returns the pending statuses and incomplete check runs on
+// ref that report_status created: they link to a run and describe it. Only
+// the commit ref names is looked at; for a branch or tag that is its tip.
+func pendingReports(ctx context.Context, config *Config, client *trigwait.Client, ref string) ([]pendingReport, error) {
+	combined, err := client.GetCombinedStatus(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	checkRuns, err := client.ListCheckRuns(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []pendingReport
+	for _, status := range combined.Statuses {
+		if status.State != "pending" {
+			continue
+		}
+		p, ok := parseReport(config, "status", status.TargetURL, status.Description)
+		if !ok {
+			continue
+		}
+		p.SHA, p.Context = combined.SHA, status.Context
+		if m := bracketedIDPattern.FindStringSubmatch(status.Description); m != nil {
+			p.distinctID = m[1]
+		}
+		pending = append(pending, p)
+	}
+	for _, cr := range checkRuns {
+		if cr.Status == "completed" || cr.Output == nil {
+			continue
+		}
+		p, ok := parseReport(config, "check", cr.DetailsURL, cr.Output.Title)
+		if !ok {
+			continue
+		}
+		p.SHA, p.Context, p.checkRunID, p.distinctID = cr.HeadSHA, cr.Name, cr.ID, cr.ExternalID
+		pending = append(pending, p)
+	}
+	for i := range pending {
+		pending[i].Owner, pending[i].Repo = config.Reconciler.Owner, config.Reconciler.Repo
+		pending[i].client = client
+	}
+	return pending, nil
+}
+
+// parseReport recognises a status or check run created by report_status
+// from the run URL it links to and the title it starts with.
+func parseReport(config *Config, kind, url, title string) (pendingReport, bool) {
+	path, ok := strings.CutPrefix(url, strings.TrimSuffix(config.GitHubServerURL, "/"))
+	if !ok {
+		return pendingReport{}, false
+	}
+	m := runURLPattern.FindStringSubmatch(path)
+	if m == nil {
+		return pendingReport{}, false
+	}
+	runID, _ := strconv.ParseInt(m[3], 10, 64)
+	if !strings.HasPrefix(title, fmt.Sprintf("Run #%d: ", runID)) {
+		return pendingReport{}, false
+	}
+	return pendingReport{
+		StatusReporter: &StatusReporter{Kind: kind, runID: runID, url: url},
+		owner:          m[1],
+		repo:           m[2],
+	}, true
+}
+
+// reconcileRun fetches the downstream run of p and completes p if the run
+// has completed.
+func reconcileRun(ctx context.Context, config *Config, p pendingReport) (ReconciledRun, error) {
+	target := *config
+	target.Owner, target.Repo = p.owner, p.repo
+	client := newClient(&target)
+
+	entry := ReconciledRun{Kind: p.Kind, Context: p.Context, SHA: p.SHA, RunID: p.runID, URL: p.url}
+	run, err := client.GetRun(ctx, p.runID)
+	if err != nil {
+		return entry, err
+	}
+	if p.distinctID != "" && !trigwait.ContainsDistinctID(run.DisplayTitle, p.distinctID) {
+		return entry, fmt.Errorf("run #%d is not titled with distinct ID %s", run.ID, p.distinctID)
+	}
+	entry.Status = run.Status
+	if run.Status != "completed" {
+		logf(trigwait.LevelInfo, "   ⏳ %s: run #%d is %s", p.Context, run.ID, run.Status)
+		return entry, nil
+	}
+
+	var jobs []trigwait.Job
+	if config.Policy.NeedsJobs(run) {
+		if jobs, err = client.ListJobs(ctx, run.ID); err != nil {
+			logf(trigwait.LevelWarn, "⚠ Could not list jobs: %v", err)
+		}
+	}
+	entry.Conclusion = run.Conclusion
+	if entry.State, err = p.complete(ctx, run, config.Policy.Evaluate(run, jobs)); err != nil {
+		return entry, err
+	}
+	logf(trigwait.LevelInfo, "   %s %s: run #%d %s, %s set to %s", conclusionIcon(run.Conclusion), p.Context, run.ID, run.Conclusion, p.Kind, entry.State)
+	return entry, nil
+}
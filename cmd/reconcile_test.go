@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func TestReconcile(t *testing.T) {
+	sink := newJSONFileSink(filepath.Join(t.TempDir(), "outputs.json"))
+	outputSinks = []OutputSink{sink}
+	defer func() { outputSinks = []OutputSink{githubOutputSink{}} }()
+	os.Setenv("GITHUB_REPOSITORY", "owner/repo")
+	os.Setenv("GITHUB_SHA", "abc1234def")
+	defer func() {
+		os.Unsetenv("GITHUB_REPOSITORY")
+		os.Unsetenv("GITHUB_SHA")
+	}()
+
+	fake, config := newFakeGitHub(t, &fakegh.Repo{
+		Owner: "owner",
+		Name:  "repo",
+		Workflows: []*fakegh.Workflow{
+			{Name: "Deploy", Path: "deploy.yml", RunName: "Deploy ${{ inputs.distinct_id }}", Conclusion: "failure"},
+			{Name: "Slow", Path: "slow.yml", RunName: "Slow ${{ inputs.distinct_id }}", QueueTime: fakegh.Duration(time.Hour)},
+		},
+	})
+	config.GitHubServerURL = "https://github.com"
+	config.DistinctIDName = "distinct_id"
+	config.WaitInterval = 10 * time.Millisecond
+	config.TriggerTimeout = 5 * time.Second
+
+	// Fire-and-forget dispatches that leave their statuses pending
+	ctx := context.Background()
+	dispatches := []struct{ workflow, kind, id string }{
+		{"deploy.yml", "status", "AAAAAAAAAAAA"},
+		{"deploy.yml", "check", "BBBBBBBBBBBB"},
+		{"slow.yml", "status", "CCCCCCCCCCCC"},
+	}
+	for _, d := range dispatches {
+		c := *config
+		c.WorkflowFileName = d.workflow
+		c.DistinctID = d.id
+		c.ClientPayload = map[string]interface{}{"distinct_id": d.id}
+		c.StatusReporter = &StatusReporter{Kind: d.kind, Owner: "owner", Repo: "repo", SHA: "abc1234def", Token: "test-token"}
+		runID, err := triggerWorkflow(ctx, &c)
+		if err != nil {
+			t.Fatalf("triggerWorkflow failed: %v", err)
+		}
+		statusStarted(ctx, &c, runID)
+	}
+	// A pending status from another integration is left alone
+	other := trigwait.New("owner", "repo", "test-token", trigwait.WithAPIURL(config.GitHubAPIURL))
+	other.CreateCommitStatus(ctx, "abc1234def", trigwait.CommitStatus{State: "pending", TargetURL: "https://ci.example.com/builds/1", Context: "ci"})
+
+	reconciler, err := newReconciler(true, nil, "test-token")
+	if err != nil {
+		t.Fatalf("newReconciler failed: %v", err)
+	}
+	config.Reconciler = reconciler
+	if err := reconcile(ctx, config); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+
+	var reconciled []ReconciledRun
+	if err := json.Unmarshal([]byte(sink.values["reconciled"]), &reconciled); err != nil {
+		t.Fatalf("invalid reconciled output %q: %v", sink.values["reconciled"], err)
+	}
+	states := map[string]string{}
+	for _, r := range reconciled {
+		states[r.Kind+" "+r.Context] = r.Status + "/" + r.State
+	}
+	want := map[string]string{
+		"status trigwait / owner/repo deploy.yml": "completed/failure",
+		"check trigwait / owner/repo deploy.yml":  "completed/failure",
+		"status trigwait / owner/repo slow.yml":   "queued/",
+	}
+	if len(states) != len(want) {
+		t.Errorf("expected %d reconciled runs, got %v", len(want), states)
+	}
+	for key, state := range want {
+		if states[key] != state {
+			t.Errorf("expected %s to be %s, got %q", key, state, states[key])
+		}
+	}
+
+	checkRuns := fake.CheckRuns("owner", "repo")
+	if len(checkRuns) != 1 || checkRuns[0].Status != "completed" || checkRuns[0].Conclusion != "failure" {
+		t.Errorf("expected the check run to be completed, got %+v", checkRuns)
+	}
+
+	// Completed statuses are not reconciled again
+	if err := reconcile(ctx, config); err != nil {
+		t.Fatalf("reconcile failed: %v", err)
+	}
+	json.Unmarshal([]byte(sink.values["reconciled"]), &reconciled)
+	if len(reconciled) != 1 || reconciled[0].Context != "trigwait / owner/repo slow.yml" {
+		t.Errorf("expected only the queued run on the second pass, got %+v", reconciled)
+	}
+}
+
+func TestLoadConfig_Reconcile(t *testing.T) {
+	os.Setenv("INPUT_GITHUB_TOKEN", "test-token")
+	os.Setenv("INPUT_RECONCILE", "true")
+	defer func() {
+		os.Unsetenv("INPUT_GITHUB_TOKEN")
+		os.Unsetenv("INPUT_RECONCILE")
+		os.Unsetenv("INPUT_RECONCILE_REFS")
+		os.Unsetenv("GITHUB_REPOSITORY")
+	}()
+
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error without GITHUB_REPOSITORY")
+	}
+
+	// On a schedule the calling commit is not where statuses were reported
+	os.Setenv("GITHUB_REPOSITORY", "caller/app")
+	os.Setenv("GITHUB_SHA", "abc1234def")
+	os.Setenv("GITHUB_EVENT_NAME", "schedule")
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error on schedule without reconcile_refs")
+	}
+	os.Unsetenv("GITHUB_SHA")
+	defer os.Unsetenv("GITHUB_EVENT_NAME")
+
+	// owner, repo and workflow_file_name come from the statuses
+	os.Setenv("GITHUB_REPOSITORY", "caller/app")
+	os.Setenv("INPUT_RECONCILE_REFS", "main, release")
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if rc := config.Reconciler; rc.Owner != "caller" || rc.Repo != "app" || len(rc.Refs) != 2 || rc.Refs[1] != "release" {
+		t.Errorf("unexpected reconciler %+v", rc)
+	}
+
+	os.Setenv("INPUT_METRICS_TEXTFILE", "trigwait.prom")
+	defer os.Unsetenv("INPUT_METRICS_TEXTFILE")
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error combining metrics with reconcile")
+	}
+}
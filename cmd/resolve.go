@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// resolveWorkflow replaces a workflow display name, path or numeric ID with
+// the workflow's file name, which the rest of the run uses in API paths.
+func resolveWorkflow(ctx context.Context, config *Config) error {
+	if trigwait.IsWorkflowFileName(config.WorkflowFileName) {
+		return nil
+	}
+	wf, err := newClient(config).ResolveWorkflow(ctx, config.WorkflowFileName)
+	if err != nil {
+		return err
+	}
+	file := wf.Path[strings.LastIndex(wf.Path, "/")+1:]
+	msg := fmt.Sprintf("   Resolved workflow %q to %s (ID %d)", config.WorkflowFileName, file, wf.ID)
+	if config.stage != "" {
+		stageLogger(config.stage).Log(trigwait.LevelInfo, msg)
+	} else {
+		logger.Log(trigwait.LevelInfo, msg)
+	}
+	config.WorkflowFileName = file
+	if config.result != nil {
+		config.result.Target.Workflow = file
+	}
+	return nil
+}
+
+// resolveRef applies the ref mode, replacing config.Ref with the ref to
+// dispatch on.
+func resolveRef(ctx context.Context, config *Config) error {
+	if config.RefMode == "" || config.RefMode == trigwait.RefExplicit {
+		return nil
+	}
+	ref, err := newClient(config).ResolveRef(ctx, config.RefMode, config.Ref, callerBranch())
+	if err != nil {
+		return err
+	}
+	config.Ref = ref
+	if config.result != nil {
+		config.result.Ref = ref
+	}
+	setOutput("ref", ref)
+	return nil
+}
+
+// callerBranch returns the branch the calling workflow runs on: the head
+// branch of a pull request, or the pushed branch. It is empty for tags.
+func callerBranch() string {
+	if head := os.Getenv("GITHUB_HEAD_REF"); head != "" {
+		return head
+	}
+	if os.Getenv("GITHUB_REF_TYPE") == "tag" {
+		return ""
+	}
+	return os.Getenv("GITHUB_REF_NAME")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func TestResolveWorkflow_ByDisplayName(t *testing.T) {
+	_, config := newFakeGitHub(t, &fakegh.Repo{
+		Owner:     "owner",
+		Name:      "repo",
+		Workflows: []*fakegh.Workflow{{Name: "Deploy to Production", Path: "deploy.yml"}},
+	})
+	config.WorkflowFileName = "Deploy to Production"
+	config.result = newResult(config)
+
+	if err := resolveWorkflow(context.Background(), config); err != nil {
+		t.Fatalf("resolveWorkflow failed: %v", err)
+	}
+	if config.WorkflowFileName != "deploy.yml" {
+		t.Errorf("expected deploy.yml, got %s", config.WorkflowFileName)
+	}
+	if config.result.Target.Workflow != "deploy.yml" {
+		t.Errorf("expected result target workflow deploy.yml, got %s", config.result.Target.Workflow)
+	}
+}
+
+func TestResolveRef(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   trigwait.RefMode
+		ref    string
+		caller string
+		want   string
+	}{
+		{"explicit", trigwait.RefExplicit, "release", "feature/x", "release"},
+		{"default branch", trigwait.RefDefault, "", "feature/x", "trunk"},
+		{"caller branch exists", trigwait.RefSameAsCaller, "", "feature/x", "feature/x"},
+		{"caller branch missing falls back to ref", trigwait.RefSameAsCaller, "release", "feature/y", "release"},
+		{"caller branch missing falls back to default", trigwait.RefSameAsCaller, "", "feature/y", "trunk"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, config := newFakeGitHub(t, &fakegh.Repo{
+				Owner:         "owner",
+				Name:          "repo",
+				DefaultBranch: "trunk",
+				Branches:      []string{"release", "feature/x"},
+			})
+			config.RefMode = tt.mode
+			config.Ref = tt.ref
+			os.Setenv("GITHUB_HEAD_REF", tt.caller)
+			defer os.Unsetenv("GITHUB_HEAD_REF")
+
+			if err := resolveRef(context.Background(), config); err != nil {
+				t.Fatalf("resolveRef failed: %v", err)
+			}
+			if config.Ref != tt.want {
+				t.Errorf("expected ref %s, got %s", tt.want, config.Ref)
+			}
+		})
+	}
+}
+
+func TestCallerBranch(t *testing.T) {
+	defer func() {
+		os.Unsetenv("GITHUB_HEAD_REF")
+		os.Unsetenv("GITHUB_REF_NAME")
+		os.Unsetenv("GITHUB_REF_TYPE")
+	}()
+
+	os.Setenv("GITHUB_REF_NAME", "main")
+	os.Setenv("GITHUB_REF_TYPE", "branch")
+	if got := callerBranch(); got != "main" {
+		t.Errorf("expected main, got %q", got)
+	}
+
+	os.Setenv("GITHUB_HEAD_REF", "feature/x")
+	if got := callerBranch(); got != "feature/x" {
+		t.Errorf("expected pull request head branch, got %q", got)
+	}
+
+	os.Unsetenv("GITHUB_HEAD_REF")
+	os.Setenv("GITHUB_REF_NAME", "v1.0.0")
+	os.Setenv("GITHUB_REF_TYPE", "tag")
+	if got := callerBranch(); got != "" {
+		t.Errorf("expected no branch for tags, got %q", got)
+	}
+}
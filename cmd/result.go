@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// resultSchemaVersion is bumped whenever a field of Result changes meaning or
+// is removed. Adding fields does not change the version.
+const resultSchemaVersion = 1
+
+// Result is the machine-readable summary written at exit when result_file is
+// set. All methods are no-ops on a nil *Result.
+type Result struct {
+	SchemaVersion  int            `json:"schema_version"`
+	Target         ResultTarget   `json:"target"`
+	Ref            string         `json:"ref"`
+	InputsHash     string         `json:"inputs_hash"`
+	DistinctID     string         `json:"distinct_id,omitempty"`
+	RunID          int64          `json:"run_id,omitempty"`
+	URL            string         `json:"url,omitempty"`
+	RunAttempt     int            `json:"run_attempt,omitempty"`
+	Status         string         `json:"status,omitempty"`
+	Conclusion     string         `json:"conclusion,omitempty"`
+	StatusTimeline []StatusChange `json:"status_timeline"`
+	Jobs           []JobResult    `json:"jobs"`
+	Timings        ResultTimings  `json:"timings"`
+	ErrorKind      string         `json:"error_kind,omitempty"`
+	Error          string         `json:"error,omitempty"`
+}
+
+// ResultTarget identifies the dispatched workflow.
+type ResultTarget struct {
+	Owner    string `json:"owner"`
+	Repo     string `json:"repo"`
+	Workflow string `json:"workflow"`
+}
+
+// StatusChange records when the run was first observed in a status.
+type StatusChange struct {
+	Status     string    `json:"status"`
+	Conclusion string    `json:"conclusion,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// JobResult is the outcome of a single job of the downstream run.
+type JobResult struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Conclusion  string `json:"conclusion,omitempty"`
+	StartedAt   string `json:"started_at,omitempty"`
+	CompletedAt string `json:"completed_at,omitempty"`
+}
+
+// ResultTimings holds timestamps of each phase and the derived durations.
+type ResultTimings struct {
+	StartedAt        time.Time  `json:"started_at"`
+	DispatchedAt     *time.Time `json:"dispatched_at,omitempty"`
+	RunFoundAt       *time.Time `json:"run_found_at,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+	FinishedAt       time.Time  `json:"finished_at"`
+	DiscoverySeconds float64    `json:"discovery_seconds,omitempty"`
+	WaitSeconds      float64    `json:"wait_seconds,omitempty"`
+	QueueSeconds     float64    `json:"queue_seconds,omitempty"`
+	RunSeconds       float64    `json:"run_seconds,omitempty"`
+	TotalSeconds     float64    `json:"total_seconds"`
+}
+
+func newResult(config *Config) *Result {
+	return &Result{
+		SchemaVersion: resultSchemaVersion,
+		Target: ResultTarget{
+			Owner:    config.Owner,
+			Repo:     config.Repo,
+			Workflow: config.WorkflowFileName,
+		},
+		Ref:            config.Ref,
+		InputsHash:     inputsHash(config.ClientPayload, config.DistinctIDName),
+		DistinctID:     config.DistinctID,
+		StatusTimeline: []StatusChange{},
+		Jobs:           []JobResult{},
+		Timings:        ResultTimings{StartedAt: time.Now().UTC()},
+	}
+}
+
+func (r *Result) dispatched() {
+	if r == nil {
+		return
+	}
+	now := time.Now().UTC()
+	r.Timings.DispatchedAt = &now
+}
+
+func (r *Result) runFound(runID int64, url string) {
+	if r == nil {
+		return
+	}
+	now := time.Now().UTC()
+	r.RunID = runID
+	r.URL = url
+	r.Timings.RunFoundAt = &now
+	if r.Timings.DispatchedAt != nil {
+		r.Timings.DiscoverySeconds = now.Sub(*r.Timings.DispatchedAt).Seconds()
+	}
+}
+
+// recordStatus is installed as the client's status hook.
+func (r *Result) recordStatus(run *trigwait.WorkflowRun) {
+	if r == nil {
+		return
+	}
+	r.Status = run.Status
+	r.RunAttempt = run.RunAttempt
+	r.StatusTimeline = append(r.StatusTimeline, StatusChange{
+		Status:     run.Status,
+		Conclusion: run.Conclusion,
+		At:         time.Now().UTC(),
+	})
+}
+
+// recordDurations is installed as the client's durations hook.
+func (r *Result) recordDurations(queued, running time.Duration) {
+	if r == nil {
+		return
+	}
+	r.Timings.QueueSeconds = queued.Seconds()
+	r.Timings.RunSeconds = running.Seconds()
+}
+
+func (r *Result) completed(run *trigwait.WorkflowRun, jobs []trigwait.Job) {
+	if r == nil {
+		return
+	}
+	now := time.Now().UTC()
+	r.Status = run.Status
+	r.Conclusion = run.Conclusion
+	r.RunAttempt = run.RunAttempt
+	r.Timings.CompletedAt = &now
+	if r.Timings.RunFoundAt != nil {
+		r.Timings.WaitSeconds = now.Sub(*r.Timings.RunFoundAt).Seconds()
+	}
+	for _, job := range jobs {
+		r.Jobs = append(r.Jobs, JobResult{
+			Name:        job.Name,
+			Status:      job.Status,
+			Conclusion:  job.Conclusion,
+			StartedAt:   job.StartedAt,
+			CompletedAt: job.CompletedAt,
+		})
+	}
+}
+
+func (r *Result) finish(err error) {
+	if r == nil {
+		return
+	}
+	r.Timings.FinishedAt = time.Now().UTC()
+	r.Timings.TotalSeconds = r.Timings.FinishedAt.Sub(r.Timings.StartedAt).Seconds()
+	if err != nil {
+		r.ErrorKind = string(trigwait.KindOf(err))
+		r.Error = err.Error()
+	}
+}
+
+// write emits the result to path. "-" or "stdout" prints a single compact
+// line to standard output so it can be picked out of the log with tail -n1.
+func (r *Result) write(path string) error {
+	if r == nil || path == "" {
+		return nil
+	}
+
+	if path == "-" || path == "stdout" {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Println(string(data))
+		return err
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// inputsHash returns the SHA-256 of the canonical JSON encoding of the
+// dispatch inputs, ignoring the generated distinct ID so that identical
+// dispatches hash the same.
+func inputsHash(payload map[string]interface{}, distinctIDName string) string {
+	inputs := make(map[string]interface{}, len(payload))
+	for k, v := range payload {
+		if distinctIDName != "" && k == distinctIDName {
+			continue
+		}
+		inputs[k] = v
+	}
+
+	// encoding/json sorts map keys, which makes the encoding canonical.
+	data, _ := json.Marshal(inputs)
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
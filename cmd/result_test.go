@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func TestInputsHash(t *testing.T) {
+	a := inputsHash(map[string]interface{}{"env": "prod", "version": "1.0", "id": "AAAA"}, "id")
+	b := inputsHash(map[string]interface{}{"version": "1.0", "env": "prod", "id": "BBBB"}, "id")
+	c := inputsHash(map[string]interface{}{"version": "1.1", "env": "prod"}, "id")
+
+	if a != b {
+		t.Errorf("expected hashes to ignore key order and distinct ID, got %s and %s", a, b)
+	}
+	if a == c {
+		t.Error("expected different inputs to hash differently")
+	}
+	if !strings.HasPrefix(a, "sha256:") {
+		t.Errorf("expected sha256: prefix, got %s", a)
+	}
+}
+
+func TestResult_TriggerAndWait(t *testing.T) {
+	startTime := time.Now()
+	polls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/dispatches"):
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/test.yml/runs"):
+			json.NewEncoder(w).Encode(trigwait.WorkflowRunsResponse{
+				WorkflowRuns: []trigwait.WorkflowRun{
+					{ID: 777, CreatedAt: startTime.Add(time.Second).Format(time.RFC3339)},
+				},
+			})
+		case strings.HasSuffix(r.URL.Path, "/runs/777/jobs"):
+			json.NewEncoder(w).Encode(trigwait.JobsResponse{
+				TotalCount: 2,
+				Jobs: []trigwait.Job{
+					{Name: "build", Status: "completed", Conclusion: "success"},
+					{Name: "deploy", Status: "completed", Conclusion: "failure"},
+				},
+			})
+		case strings.HasSuffix(r.URL.Path, "/runs/777"):
+			polls++
+			run := trigwait.WorkflowRun{ID: 777, Status: "in_progress", RunAttempt: 1}
+			if polls > 1 {
+				run.Status = "completed"
+				run.Conclusion = "failure"
+			}
+			json.NewEncoder(w).Encode(run)
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Owner:            "owner",
+		Repo:             "repo",
+		GitHubToken:      "test-token",
+		GitHubAPIURL:     server.URL,
+		GitHubServerURL:  "https://github.com",
+		WorkflowFileName: "test.yml",
+		Ref:              "main",
+		ClientPayload:    map[string]interface{}{"env": "prod"},
+		WaitInterval:     20 * time.Millisecond,
+		TriggerTimeout:   5 * time.Second,
+		PropagateFailure: true,
+		ResultFile:       filepath.Join(t.TempDir(), "result.json"),
+	}
+	config.result = newResult(config)
+
+	runID, err := triggerWorkflow(context.Background(), config)
+	if err != nil {
+		t.Fatalf("triggerWorkflow failed: %v", err)
+	}
+	err = waitForWorkflow(context.Background(), config, runID)
+	if trigwait.KindOf(err) != trigwait.KindDownstreamFailed {
+		t.Fatalf("expected downstream-failed error, got %v", err)
+	}
+	config.result.finish(err)
+	if err := config.result.write(config.ResultFile); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	content, _ := os.ReadFile(config.ResultFile)
+	var result Result
+	if err := json.Unmarshal(content, &result); err != nil {
+		t.Fatalf("invalid result JSON: %v", err)
+	}
+
+	if result.SchemaVersion != resultSchemaVersion {
+		t.Errorf("expected schema version %d, got %d", resultSchemaVersion, result.SchemaVersion)
+	}
+	if result.RunID != 777 || result.URL != "https://github.com/owner/repo/actions/runs/777" {
+		t.Errorf("unexpected run: %d %s", result.RunID, result.URL)
+	}
+	if result.Conclusion != "failure" || result.ErrorKind != "downstream-failed" {
+		t.Errorf("unexpected conclusion/error kind: %s/%s", result.Conclusion, result.ErrorKind)
+	}
+	if len(result.StatusTimeline) != 2 || result.StatusTimeline[0].Status != "in_progress" || result.StatusTimeline[1].Status != "completed" {
+		t.Errorf("unexpected status timeline: %+v", result.StatusTimeline)
+	}
+	if len(result.Jobs) != 2 || result.Jobs[1].Name != "deploy" || result.Jobs[1].Conclusion != "failure" {
+		t.Errorf("unexpected jobs: %+v", result.Jobs)
+	}
+	if result.Timings.DispatchedAt == nil || result.Timings.RunFoundAt == nil || result.Timings.CompletedAt == nil {
+		t.Errorf("expected all phase timestamps, got %+v", result.Timings)
+	}
+}
+
+func TestResult_NilIsNoop(t *testing.T) {
+	var result *Result
+	result.dispatched()
+	result.recordStatus(&trigwait.WorkflowRun{Status: "queued"})
+	result.finish(errors.New("boom"))
+	if err := result.write("-"); err != nil {
+		t.Errorf("expected nil result write to be a no-op, got %v", err)
+	}
+}
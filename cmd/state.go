@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// State records a dispatched run in state_file so that a re-run of the step,
+// for example after the runner died mid-wait, resumes waiting on that run
+// instead of dispatching a duplicate.
+type State struct {
+	Owner        string    `json:"owner"`
+	Repo         string    `json:"repo"`
+	Workflow     string    `json:"workflow"`
+	Ref          string    `json:"ref"`
+	DistinctID   string    `json:"distinct_id,omitempty"`
+	RunID        int64     `json:"run_id"`
+	URL          string    `json:"url"`
+	DispatchedAt time.Time `json:"dispatched_at"`
+}
+
+// matches reports whether the state was recorded for the target config is
+// about to dispatch.
+func (s *State) matches(config *Config) bool {
+	return s.Owner == config.Owner && s.Repo == config.Repo && s.Workflow == config.WorkflowFileName && s.Ref == config.Ref
+}
+
+// resumeRun returns the run recorded in the state file for the configured
+// target, or 0 when there is none and the workflow must be dispatched.
+func resumeRun(config *Config) int64 {
+	if config.StateFile == "" {
+		return 0
+	}
+	data, err := os.ReadFile(config.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0
+	}
+	var state State
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil || state.RunID == 0 {
+		logf(trigwait.LevelWarn, "⚠ Ignoring unreadable state file %s: %v", config.StateFile, err)
+		return 0
+	}
+	if !state.matches(config) {
+		logf(trigwait.LevelWarn, "⚠ Ignoring state file %s recorded for %s/%s %s @ %s", config.StateFile, state.Owner, state.Repo, state.Workflow, state.Ref)
+		return 0
+	}
+
+	logf(trigwait.LevelInfo, "♻ Resuming run #%d dispatched at %s", state.RunID, state.DispatchedAt.Format(time.RFC3339))
+	logf(trigwait.LevelInfo, "   URL: %s", state.URL)
+	if state.DistinctID != "" {
+		config.DistinctID = state.DistinctID
+		setOutput("distinct_id", state.DistinctID)
+	}
+	config.result.runFound(state.RunID, state.URL)
+	return state.RunID
+}
+
+// saveState records runID in the state file. Failing to write it only
+// costs the ability to resume, so it is logged rather than returned.
+func saveState(config *Config, runID int64) {
+	if config.StateFile == "" {
+		return
+	}
+	state := State{
+		Owner:        config.Owner,
+		Repo:         config.Repo,
+		Workflow:     config.WorkflowFileName,
+		Ref:          config.Ref,
+		DistinctID:   config.DistinctID,
+		RunID:        runID,
+		URL:          newClient(config).RunURL(runID),
+		DispatchedAt: time.Now().UTC(),
+	}
+	if err := writeState(config.StateFile, &state); err != nil {
+		logf(trigwait.LevelWarn, "⚠ Failed to write state file: %v", err)
+		return
+	}
+	logf(trigwait.LevelDebug, "Recorded run #%d in %s", runID, config.StateFile)
+}
+
+// writeState writes the state through a temporary file so that a runner
+// dying mid-write never leaves a truncated state behind.
+func writeState(path string, state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// clearState removes the state file once the run has completed, so the
+// next attempt of the job dispatches a fresh run.
+func clearState(config *Config) {
+	if config.StateFile == "" {
+		return
+	}
+	if err := os.Remove(config.StateFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logf(trigwait.LevelWarn, "⚠ Failed to remove state file: %v", err)
+	}
+}
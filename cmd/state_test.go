@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+)
+
+func TestStateFile_ResumesWait(t *testing.T) {
+	sink := newJSONFileSink(filepath.Join(t.TempDir(), "outputs.json"))
+	outputSinks = []OutputSink{sink}
+	defer func() { outputSinks = []OutputSink{githubOutputSink{}} }()
+
+	fake, config := newDeployFake(t, fakegh.Workflow{RunTime: fakegh.Duration(100 * time.Millisecond)})
+	config.StateFile = filepath.Join(t.TempDir(), "state", "trigwait.json")
+	setDistinctID(config, "AAAAAAAA")
+
+	// The first attempt dispatches, then the runner dies
+	runID, err := triggerWorkflow(context.Background(), config)
+	if err != nil {
+		t.Fatalf("triggerWorkflow failed: %v", err)
+	}
+	saveState(config, runID)
+
+	// The re-run generated a new distinct ID but resumes the original run
+	config.DistinctID = "BBBBBBBB"
+	if resumed := resumeRun(config); resumed != runID {
+		t.Fatalf("expected to resume run %d, got %d", runID, resumed)
+	}
+	if config.DistinctID != "AAAAAAAA" || sink.values["distinct_id"] != "AAAAAAAA" {
+		t.Errorf("expected the original distinct ID, got %q", config.DistinctID)
+	}
+	if len(fake.Runs()) != 1 {
+		t.Errorf("expected no second dispatch, got %d runs", len(fake.Runs()))
+	}
+
+	other := *config
+	other.Ref = "release"
+	if resumed := resumeRun(&other); resumed != 0 {
+		t.Errorf("expected state of another target to be ignored, got run %d", resumed)
+	}
+
+	if err := waitForWorkflow(context.Background(), config, runID); err != nil {
+		t.Fatalf("waitForWorkflow failed: %v", err)
+	}
+	if _, err := os.Stat(config.StateFile); !os.IsNotExist(err) {
+		t.Errorf("expected the state file to be removed after completion, got %v", err)
+	}
+	if resumed := resumeRun(config); resumed != 0 {
+		t.Errorf("expected a fresh dispatch after completion, got run %d", resumed)
+	}
+}
+
+func TestStateFile_Unreadable(t *testing.T) {
+	config := &Config{StateFile: filepath.Join(t.TempDir(), "state.json")}
+	if err := os.WriteFile(config.StateFile, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if resumed := resumeRun(config); resumed != 0 {
+		t.Errorf("expected a corrupt state file to be ignored, got run %d", resumed)
+	}
+}
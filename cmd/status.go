@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// StatusReporter mirrors the downstream run on the commit that triggered the
+// calling workflow, as a commit status or a check run.
+type StatusReporter struct {
+	// Kind is status or check.
+	Kind string
+	// Owner, Repo and SHA identify the calling commit.
+	Owner   string
+	Repo    string
+	SHA     string
+	Token   string
+	Context string
+
+	client     *trigwait.Client
+	checkRunID int64
+	runID      int64
+	url        string
+	distinctID string
+}
+
+// newStatusReporter builds the reporter described by the report_status,
+// status_context and status_token inputs, or returns nil when report_status
+// is not set.
+func newStatusReporter(kind, context, token string) (*StatusReporter, error) {
+	switch kind {
+	case "", "off", "false":
+		return nil, nil
+	case "status", "check":
+	default:
+		return nil, fmt.Errorf("invalid report_status %q: must be status or check", kind)
+	}
+	owner, repo, err := callerRepo("report_status")
+	if err != nil {
+		return nil, err
+	}
+	sha := callerSHA()
+	if sha == "" {
+		return nil, fmt.Errorf("report_status requires GITHUB_SHA to name the calling commit")
+	}
+	return &StatusReporter{Kind: kind, Owner: owner, Repo: repo, SHA: sha, Token: token, Context: context}, nil
+}
+
+// callerRepo splits GITHUB_REPOSITORY, naming input in the error when it
+// is not set.
+func callerRepo(input string) (owner, repo string, err error) {
+	owner, repo, ok := strings.Cut(os.Getenv("GITHUB_REPOSITORY"), "/")
+	if !ok || owner == "" || repo == "" {
+		return "", "", fmt.Errorf("%s requires GITHUB_REPOSITORY to name the calling repository", input)
+	}
+	return owner, repo, nil
+}
+
+// callerSHA returns the commit the calling workflow runs for: the head of
+// the pull request for pull_request events, where GITHUB_SHA is a merge
+// commit that PR checks are not shown on, the head of the triggering run for
+// workflow_run events, and GITHUB_SHA otherwise.
+func callerSHA() string {
+	if data, err := os.ReadFile(os.Getenv("GITHUB_EVENT_PATH")); err == nil {
+		var event struct {
+			PullRequest struct {
+				Head struct {
+					SHA string `json:"sha"`
+				} `json:"head"`
+			} `json:"pull_request"`
+			WorkflowRun struct {
+				HeadSHA string `json:"head_sha"`
+			} `json:"workflow_run"`
+		}
+		if json.Unmarshal(data, &event) == nil {
+			if event.PullRequest.Head.SHA != "" {
+				return event.PullRequest.Head.SHA
+			}
+			if event.WorkflowRun.HeadSHA != "" {
+				return event.WorkflowRun.HeadSHA
+			}
+		}
+	}
+	return os.Getenv("GITHUB_SHA")
+}
+
+// callerClient returns a client for the calling repository.
+func callerClient(config *Config, owner, repo, token string) *trigwait.Client {
+	opts := []trigwait.Option{
+		trigwait.WithAPIURL(config.GitHubAPIURL),
+		trigwait.WithServerURL(config.GitHubServerURL),
+	}
+	if config.tracer != nil {
+		opts = append(opts, trigwait.WithTracer(config.tracer))
+	}
+	return trigwait.New(owner, repo, token, opts...)
+}
+
+// statusStarted creates the pending status or check run for runID.
+func statusStarted(ctx context.Context, config *Config, runID int64) {
+	r := config.StatusReporter
+	if r == nil {
+		return
+	}
+	if r.Context == "" {
+		r.Context = fmt.Sprintf("trigwait / %s/%s %s", config.Owner, config.Repo, config.WorkflowFileName)
+	}
+	r.client = callerClient(config, r.Owner, r.Repo, r.Token)
+	r.runID = runID
+	r.url = newClient(config).RunURL(runID)
+	r.distinctID = config.DistinctID
+
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+
+	status := "queued"
+	if !config.WaitWorkflow {
+		status = "dispatched"
+	}
+	var err error
+	if r.Kind == "check" {
+		r.checkRunID, err = r.client.CreateCheckRun(ctx, trigwait.CheckRun{
+			Name:       r.Context,
+			HeadSHA:    r.SHA,
+			Status:     "queued",
+			DetailsURL: r.url,
+			ExternalID: config.DistinctID,
+			Output:     r.output(status),
+		})
+	} else {
+		err = r.client.CreateCommitStatus(ctx, r.SHA, r.commitStatus("pending", status))
+	}
+	if err != nil {
+		logf(trigwait.LevelWarn, "⚠ Failed to report %s on %s/%s@%s: %v", r.Kind, r.Owner, r.Repo, shortSHA(r.SHA), err)
+		r.client = nil
+		return
+	}
+	logf(trigwait.LevelInfo, "📌 Reporting run #%d as %s %q on %s/%s@%s", runID, r.Kind, r.Context, r.Owner, r.Repo, shortSHA(r.SHA))
+}
+
+// recordStatus is chained to the client's status hook and updates the
+// pending status or check run while the run is queued or in progress. The
+// final state is reported by statusCompleted, which knows the policy outcome.
+func (r *StatusReporter) recordStatus(run *trigwait.WorkflowRun) {
+	if r == nil || r.client == nil || run.Status == "completed" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+
+	var err error
+	if r.Kind == "check" {
+		status := "in_progress"
+		if run.Status == "queued" || run.Status == "waiting" || run.Status == "pending" || run.Status == "requested" {
+			status = "queued"
+		}
+		err = r.client.UpdateCheckRun(ctx, r.checkRunID, trigwait.CheckRun{Status: status, Output: r.output(run.Status)})
+	} else {
+		err = r.client.CreateCommitStatus(ctx, r.SHA, r.commitStatus("pending", run.Status))
+	}
+	if err != nil {
+		logf(trigwait.LevelWarn, "⚠ Failed to update %s: %v", r.Kind, err)
+	}
+}
+
+// statusCompleted reports the final state of the run. Both a commit status
+// and a check run are success when the failure policy accepts the run, given
+// as policyErr, so they agree with the step; otherwise a check run carries
+// the run's own conclusion, or failure when the run succeeded but the policy
+// rejected it.
+func statusCompleted(ctx context.Context, config *Config, run *trigwait.WorkflowRun, policyErr error) {
+	r := config.StatusReporter
+	if r == nil || r.client == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+
+	if _, err := r.complete(ctx, run, policyErr); err != nil {
+		logf(trigwait.LevelWarn, "⚠ Failed to update %s: %v", r.Kind, err)
+	}
+}
+
+// statusFailed reports that waiting on the run failed with err before the
+// run completed, so the status or check run is not left pending. A check run
+// is timed_out when the wait timed out or breached an SLO, cancelled when
+// the step was cancelled, and failure otherwise; a commit status, which has
+// no such states, is error.
+func statusFailed(ctx context.Context, config *Config, err error) {
+	r := config.StatusReporter
+	if r == nil || r.client == nil {
+		return
+	}
+	// The step's context may be the one that was cancelled
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), reportTimeout)
+	defer cancel()
+
+	kind := trigwait.KindOf(err)
+	detail := fmt.Sprintf("not completed (%s)", kind)
+	var uerr error
+	if r.Kind == "check" {
+		conclusion := "failure"
+		switch {
+		case kind == trigwait.KindWaitTimeout, kind == trigwait.KindSLOBreached:
+			conclusion = "timed_out"
+		case errors.Is(err, context.Canceled):
+			conclusion = "cancelled"
+		}
+		uerr = r.client.UpdateCheckRun(ctx, r.checkRunID, trigwait.CheckRun{
+			Status:     "completed",
+			Conclusion: conclusion,
+			Output:     r.output(detail),
+		})
+	} else {
+		uerr = r.client.CreateCommitStatus(ctx, r.SHA, r.commitStatus("error", detail))
+	}
+	if uerr != nil {
+		logf(trigwait.LevelWarn, "⚠ Failed to update %s: %v", r.Kind, uerr)
+	}
+}
+
+// complete reports the final state of the run and returns the commit status
+// state or check run conclusion it set.
+func (r *StatusReporter) complete(ctx context.Context, run *trigwait.WorkflowRun, policyErr error) (string, error) {
+	if r.Kind == "check" {
+		conclusion := "success"
+		if policyErr != nil {
+			if conclusion = checkConclusion(run.Conclusion); conclusion == "success" {
+				conclusion = "failure"
+			}
+		}
+		return conclusion, r.client.UpdateCheckRun(ctx, r.checkRunID, trigwait.CheckRun{
+			Status:     "completed",
+			Conclusion: conclusion,
+			Output:     r.output(run.Conclusion),
+		})
+	}
+	state := "success"
+	if policyErr != nil {
+		state = "failure"
+	}
+	return state, r.client.CreateCommitStatus(ctx, r.SHA, r.commitStatus(state, run.Conclusion))
+}
+
+// commitStatus describes the run, ending with the distinct ID in brackets
+// so reconcile can check it still refers to the same run. The detail is
+// shortened to fit, so the bracketed ID is never cut off.
+func (r *StatusReporter) commitStatus(state, detail string) trigwait.CommitStatus {
+	prefix := fmt.Sprintf("Run #%d: ", r.runID)
+	suffix := ""
+	if r.distinctID != "" {
+		suffix = " [" + r.distinctID + "]"
+	}
+	room := trigwait.MaxStatusDescription - utf8.RuneCountInString(prefix+suffix)
+	if runes := []rune(detail); len(runes) > room {
+		detail = ""
+		if room > 0 {
+			detail = string(runes[:room-1]) + "…"
+		}
+	}
+	return trigwait.CommitStatus{
+		State:       state,
+		TargetURL:   r.url,
+		Description: prefix + detail + suffix,
+		Context:     r.Context,
+	}
+}
+
+func (r *StatusReporter) output(detail string) *trigwait.CheckRunOutput {
+	return &trigwait.CheckRunOutput{
+		Title:   fmt.Sprintf("Run #%d: %s", r.runID, detail),
+		Summary: fmt.Sprintf("Downstream run: %s", r.url),
+	}
+}
+
+// checkConclusion maps a workflow run conclusion to one check runs accept.
+func checkConclusion(conclusion string) string {
+	switch conclusion {
+	case "success", "failure", "cancelled", "neutral", "skipped", "timed_out", "action_required", "stale":
+		return conclusion
+	}
+	// startup_failure and anything GitHub adds later
+	return "failure"
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
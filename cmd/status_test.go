@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func TestWaitForWorkflow_ReportStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		kind       string
+		conclusion string
+		wantState  string
+	}{
+		{name: "status success", kind: "status", conclusion: "success", wantState: "success"},
+		{name: "status failure", kind: "status", conclusion: "failure", wantState: "failure"},
+		{name: "check success", kind: "check", conclusion: "success", wantState: "success"},
+		{name: "check startup failure", kind: "check", conclusion: "startup_failure", wantState: "failure"},
+		{name: "status accepted by policy", kind: "status", conclusion: "neutral", wantState: "success"},
+		{name: "check accepted by policy", kind: "check", conclusion: "neutral", wantState: "success"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, config := newDeployFake(t, fakegh.Workflow{Conclusion: tt.conclusion})
+			config.WaitWorkflow = true
+			// The caller and the downstream workflow share a repository here
+			config.StatusReporter = &StatusReporter{Kind: tt.kind, Owner: "owner", Repo: "repo", SHA: "abc1234def", Token: "test-token"}
+
+			ctx := context.Background()
+			runID, err := triggerWorkflow(ctx, config)
+			if err != nil {
+				t.Fatalf("triggerWorkflow failed: %v", err)
+			}
+			statusStarted(ctx, config, runID)
+			waitForWorkflow(ctx, config, runID)
+
+			url := newClient(config).RunURL(runID)
+			wantContext := "trigwait / owner/repo deploy.yml"
+			if tt.kind == "status" {
+				statuses := fake.Statuses("owner", "repo")
+				if len(statuses) < 2 || statuses[0].State != "pending" {
+					t.Fatalf("expected pending then final statuses, got %+v", statuses)
+				}
+				last := statuses[len(statuses)-1]
+				if last.State != tt.wantState || last.TargetURL != url || last.Context != wantContext || last.SHA != "abc1234def" {
+					t.Errorf("unexpected final status %+v", last)
+				}
+				return
+			}
+
+			checkRuns := fake.CheckRuns("owner", "repo")
+			if len(checkRuns) != 1 {
+				t.Fatalf("expected 1 check run, got %+v", checkRuns)
+			}
+			cr := checkRuns[0]
+			if cr.Status != "completed" || cr.Conclusion != tt.wantState {
+				t.Errorf("expected completed/%s, got %s/%s", tt.wantState, cr.Status, cr.Conclusion)
+			}
+			if cr.Name != wantContext || cr.DetailsURL != url || cr.ExternalID != config.DistinctID || cr.HeadSHA != "abc1234def" {
+				t.Errorf("unexpected check run %+v", cr)
+			}
+		})
+	}
+}
+
+func TestWaitForWorkflow_ReportStatusTimeout(t *testing.T) {
+	for kind, want := range map[string]string{"status": "error", "check": "timed_out"} {
+		t.Run(kind, func(t *testing.T) {
+			fake, config := newDeployFake(t, fakegh.Workflow{QueueTime: fakegh.Duration(time.Hour)})
+			config.WaitTimeout = 50 * time.Millisecond
+			config.WaitWorkflow = true
+			config.StatusReporter = &StatusReporter{Kind: kind, Owner: "owner", Repo: "repo", SHA: "abc1234def", Token: "test-token"}
+
+			ctx := context.Background()
+			runID, err := triggerWorkflow(ctx, config)
+			if err != nil {
+				t.Fatalf("triggerWorkflow failed: %v", err)
+			}
+			statusStarted(ctx, config, runID)
+			if err := waitForWorkflow(ctx, config, runID); trigwait.KindOf(err) != trigwait.KindWaitTimeout {
+				t.Fatalf("expected %s, got %v", trigwait.KindWaitTimeout, err)
+			}
+
+			if kind == "status" {
+				statuses := fake.Statuses("owner", "repo")
+				if last := statuses[len(statuses)-1]; last.State != want || !strings.Contains(last.Description, "wait-timeout") {
+					t.Errorf("expected a final %s status, got %+v", want, last)
+				}
+				return
+			}
+			checkRuns := fake.CheckRuns("owner", "repo")
+			if len(checkRuns) != 1 || checkRuns[0].Status != "completed" || checkRuns[0].Conclusion != want {
+				t.Errorf("expected a completed/%s check run, got %+v", want, checkRuns)
+			}
+		})
+	}
+}
+
+func TestStatusStarted_FireAndForget(t *testing.T) {
+	fake, config := newFakeGitHub(t, &fakegh.Repo{
+		Owner:     "owner",
+		Name:      "repo",
+		Workflows: []*fakegh.Workflow{{Name: "Deploy", Path: "deploy.yml"}},
+	})
+	config.StatusReporter = &StatusReporter{Kind: "status", Owner: "owner", Repo: "repo", SHA: "abc1234def", Token: "test-token", Context: "deploy"}
+
+	statusStarted(context.Background(), config, 42)
+
+	statuses := fake.Statuses("owner", "repo")
+	if len(statuses) != 1 || statuses[0].State != "pending" || statuses[0].Context != "deploy" {
+		t.Fatalf("expected one pending status, got %+v", statuses)
+	}
+	if !strings.Contains(statuses[0].Description, "dispatched") {
+		t.Errorf("expected description to say the run was dispatched, got %q", statuses[0].Description)
+	}
+}
+
+func TestCommitStatus_LongDetail(t *testing.T) {
+	r := &StatusReporter{Context: "deploy", runID: 1234567, distinctID: "4242-release-deploy-1a2b3c4d"}
+	status := r.commitStatus("pending", strings.Repeat("queued behind a long line of other runs ", 10))
+
+	if n := utf8.RuneCountInString(status.Description); n != trigwait.MaxStatusDescription {
+		t.Errorf("expected the description to be shortened to %d characters, got %d", trigwait.MaxStatusDescription, n)
+	}
+	if !strings.HasPrefix(status.Description, "Run #1234567: queued") {
+		t.Errorf("expected the run number first, got %q", status.Description)
+	}
+	if m := bracketedIDPattern.FindStringSubmatch(status.Description); m == nil || m[1] != r.distinctID {
+		t.Errorf("expected the whole distinct ID at the end, got %q", status.Description)
+	}
+}
+
+func TestNewStatusReporter(t *testing.T) {
+	eventPath := filepath.Join(t.TempDir(), "event.json")
+	os.WriteFile(eventPath, []byte(`{"pull_request": {"head": {"sha": "feedface"}}}`), 0o644)
+	defer func() {
+		os.Unsetenv("GITHUB_REPOSITORY")
+		os.Unsetenv("GITHUB_SHA")
+		os.Unsetenv("GITHUB_EVENT_PATH")
+	}()
+
+	if r, err := newStatusReporter("", "", "token"); r != nil || err != nil {
+		t.Errorf("expected no reporter when report_status is unset, got %v, %v", r, err)
+	}
+	if _, err := newStatusReporter("comment", "", "token"); err == nil {
+		t.Error("expected error for an invalid report_status")
+	}
+	if _, err := newStatusReporter("status", "", "token"); err == nil {
+		t.Error("expected error without GITHUB_REPOSITORY")
+	}
+
+	os.Setenv("GITHUB_REPOSITORY", "caller/app")
+	os.Setenv("GITHUB_SHA", "deadbeef")
+	r, err := newStatusReporter("check", "ci", "token")
+	if err != nil {
+		t.Fatalf("newStatusReporter failed: %v", err)
+	}
+	if r.Owner != "caller" || r.Repo != "app" || r.SHA != "deadbeef" || r.Context != "ci" {
+		t.Errorf("unexpected reporter %+v", r)
+	}
+
+	os.Setenv("GITHUB_EVENT_PATH", eventPath)
+	if r, _ := newStatusReporter("status", "", "token"); r.SHA != "feedface" {
+		t.Errorf("expected the pull request head SHA, got %q", r.SHA)
+	}
+}
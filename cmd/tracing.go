@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/internal/otlp"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// startTracing starts the root span of the step at start, when the process
+// began, with loadConfig as its first child. The returned context carries
+// the root span, so clients created from it record their spans beneath it.
+//
+// A TRACEPARENT environment variable makes the step part of the caller's
+// trace, and is forwarded to the downstream run even without an endpoint.
+func startTracing(ctx context.Context, config *Config, start time.Time) context.Context {
+	ctx = otlp.WithTraceParent(ctx, os.Getenv("TRACEPARENT"))
+	config.traceParent = otlp.TraceParent(ctx)
+	if config.OTLPEndpoint == "" {
+		return ctx
+	}
+
+	config.tracer = otlp.New(config.OTLPEndpoint, "workflow-trigwait", config.OTLPHeaders)
+	ctx, config.traceSpan = config.tracer.StartAt(ctx, "trigwait", start)
+	config.traceSpan.SetAttr("github.owner", config.Owner)
+	config.traceSpan.SetAttr("github.repo", config.Repo)
+	config.traceSpan.SetAttr("github.workflow", config.WorkflowFileName)
+	config.traceSpan.SetAttr("github.ref", config.Ref)
+	if config.DistinctID != "" {
+		config.traceSpan.SetAttr("trigwait.distinct_id", config.DistinctID)
+	}
+	_, span := config.tracer.StartAt(ctx, "loadConfig", start)
+	span.End(nil)
+
+	config.traceParent = otlp.TraceParent(ctx)
+	logf(trigwait.LevelDebug, "Tracing to %s as %s", config.OTLPEndpoint, config.traceParent)
+	return ctx
+}
+
+// finishTracing ends the root span and exports the recorded spans.
+func finishTracing(config *Config, err error) {
+	if config.tracer == nil {
+		return
+	}
+	if config.result != nil {
+		if config.result.RunID != 0 {
+			config.traceSpan.SetAttr("github.run_id", config.result.RunID)
+		}
+		if config.result.Conclusion != "" {
+			config.traceSpan.SetAttr("github.conclusion", config.result.Conclusion)
+		}
+	}
+	if err != nil {
+		config.traceSpan.SetAttr("trigwait.error_kind", string(trigwait.KindOf(err)))
+	}
+	config.traceSpan.End(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+	if ferr := config.tracer.Flush(ctx); ferr != nil {
+		logf(trigwait.LevelWarn, "⚠ Failed to export trace: %v", ferr)
+	}
+}
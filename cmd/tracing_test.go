@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+)
+
+func TestTracing(t *testing.T) {
+	type span struct {
+		TraceID      string `json:"traceId"`
+		SpanID       string `json:"spanId"`
+		ParentSpanID string `json:"parentSpanId"`
+		Name         string `json:"name"`
+	}
+	var mu sync.Mutex
+	var spans []span
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []span `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	defer collector.Close()
+
+	const upstream = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	os.Setenv("TRACEPARENT", upstream)
+	defer os.Unsetenv("TRACEPARENT")
+
+	fake, config := newDeployFake(t, fakegh.Workflow{RunTime: fakegh.Duration(50 * time.Millisecond)})
+	config.OTLPEndpoint = collector.URL
+	config.TraceParentName = "traceparent"
+
+	ctx := startTracing(context.Background(), config, time.Now())
+	runID, err := triggerWorkflow(ctx, config)
+	if err != nil {
+		t.Fatalf("triggerWorkflow failed: %v", err)
+	}
+	if err := waitForWorkflow(ctx, config, runID); err != nil {
+		t.Fatalf("waitForWorkflow failed: %v", err)
+	}
+	finishTracing(config, nil)
+
+	names := map[string]int{}
+	ids := map[string]string{}
+	for _, s := range spans {
+		names[s.Name]++
+		ids[s.Name] = s.SpanID
+		if s.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("span %s is not part of the upstream trace: %s", s.Name, s.TraceID)
+		}
+	}
+	for _, name := range []string{"trigwait", "loadConfig", "dispatch", "find_run", "poll", "POST", "GET"} {
+		if names[name] == 0 {
+			t.Errorf("expected a %s span, got %v", name, names)
+		}
+	}
+	for _, s := range spans {
+		switch s.Name {
+		case "trigwait":
+			if s.ParentSpanID != "00f067aa0ba902b7" {
+				t.Errorf("expected the root span to continue the upstream span, got parent %q", s.ParentSpanID)
+			}
+		case "POST":
+			if s.ParentSpanID != ids["dispatch"] {
+				t.Errorf("expected the dispatch request inside the dispatch span")
+			}
+		}
+	}
+
+	inputs := fake.Runs()[0].Inputs
+	traceparent, _ := inputs["traceparent"].(string)
+	if !strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+ids["trigwait"]) {
+		t.Errorf("expected the downstream run to receive the root span's context, got %q", traceparent)
+	}
+	if _, ok := config.ClientPayload["traceparent"]; ok {
+		t.Error("expected client_payload to be left unchanged")
+	}
+}
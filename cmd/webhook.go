@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// relayRetryInterval is how long to wait before reconnecting to the relay.
+var relayRetryInterval = 10 * time.Second
+
+// startWebhook starts receiving workflow_run events on the configured
+// listener and relay. The receiver stops when ctx is done. It does nothing
+// when neither is configured.
+func startWebhook(ctx context.Context, config *Config) error {
+	if config.WebhookListen == "" && config.WebhookRelayURL == "" {
+		return nil
+	}
+	receiver := trigwait.NewWebhookReceiver(config.WebhookSecret)
+
+	if config.WebhookListen != "" {
+		listener, err := net.Listen("tcp", config.WebhookListen)
+		if err != nil {
+			return &trigwait.Error{Kind: trigwait.KindConfig, Err: fmt.Errorf("failed to listen for webhooks: %w", err)}
+		}
+		server := &http.Server{Handler: receiver, ReadHeaderTimeout: 10 * time.Second}
+		go server.Serve(listener)
+		go func() {
+			<-ctx.Done()
+			server.Close()
+		}()
+		logf(trigwait.LevelInfo, "📡 Listening for workflow_run webhooks on %s", listener.Addr())
+	}
+
+	if config.WebhookRelayURL != "" {
+		go relay(ctx, receiver, config.WebhookRelayURL)
+		logf(trigwait.LevelInfo, "📡 Reading workflow_run webhooks from relay %s", config.WebhookRelayURL)
+	}
+
+	config.events = receiver
+	return nil
+}
+
+// relay reads deliveries from the relay until ctx is done, reconnecting
+// whenever the stream ends.
+func relay(ctx context.Context, receiver *trigwait.WebhookReceiver, url string) {
+	// The stream stays open, so it must not be cut by a client timeout
+	httpClient := &http.Client{}
+	for {
+		err := receiver.Relay(ctx, httpClient, url)
+		if ctx.Err() != nil {
+			return
+		}
+		logf(trigwait.LevelDebug, "Relay disconnected (%v); reconnecting in %v", err, relayRetryInterval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(relayRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func TestLoadConfig_WebhookRequiresSecret(t *testing.T) {
+	os.Setenv("INPUT_OWNER", "test-owner")
+	os.Setenv("INPUT_REPO", "test-repo")
+	os.Setenv("INPUT_GITHUB_TOKEN", "test-token")
+	os.Setenv("INPUT_WORKFLOW_FILE_NAME", "test.yml")
+	os.Setenv("INPUT_WEBHOOK_LISTEN", ":8080")
+	defer func() {
+		os.Unsetenv("INPUT_OWNER")
+		os.Unsetenv("INPUT_REPO")
+		os.Unsetenv("INPUT_GITHUB_TOKEN")
+		os.Unsetenv("INPUT_WORKFLOW_FILE_NAME")
+		os.Unsetenv("INPUT_WEBHOOK_LISTEN")
+		os.Unsetenv("INPUT_WEBHOOK_SECRET")
+	}()
+
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error when webhook_secret is missing")
+	}
+
+	os.Setenv("INPUT_WEBHOOK_SECRET", "s3cret")
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if config.WebhookFallback != 300*time.Second {
+		t.Errorf("expected fallback interval 300s, got %v", config.WebhookFallback)
+	}
+}
+
+func TestWaitForWorkflow_RelayedEvent(t *testing.T) {
+	sink := newJSONFileSink(filepath.Join(t.TempDir(), "outputs.json"))
+	outputSinks = []OutputSink{sink}
+	defer func() { outputSinks = []OutputSink{githubOutputSink{}} }()
+
+	var polls int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&polls, 1)
+		json.NewEncoder(w).Encode(trigwait.WorkflowRun{ID: 12345, Status: "in_progress"})
+	}))
+	defer api.Close()
+
+	body := `{"action":"completed","workflow_run":{"id":12345,"status":"completed","conclusion":"success"}}`
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(body))
+	delivery, _ := json.Marshal(map[string]interface{}{
+		"x-github-event":      "workflow_run",
+		"x-hub-signature-256": "sha256=" + hex.EncodeToString(mac.Sum(nil)),
+		"body":                json.RawMessage(body),
+	})
+	relayServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: %s\n\n", delivery)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer relayServer.Close()
+
+	config := &Config{
+		Owner:            "owner",
+		Repo:             "repo",
+		GitHubToken:      "test-token",
+		GitHubAPIURL:     api.URL,
+		GitHubServerURL:  "https://github.com",
+		WaitInterval:     10 * time.Millisecond,
+		PropagateFailure: true,
+		WebhookRelayURL:  relayServer.URL,
+		WebhookSecret:    "s3cret",
+		WebhookFallback:  time.Hour,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := startWebhook(ctx, config); err != nil {
+		t.Fatalf("startWebhook failed: %v", err)
+	}
+	if err := waitForWorkflow(ctx, config, 12345); err != nil {
+		t.Fatalf("waitForWorkflow failed: %v", err)
+	}
+	if got := sink.values["conclusion"]; got != "success" {
+		t.Errorf("expected conclusion success, got %q", got)
+	}
+	if n := atomic.LoadInt32(&polls); n != 0 {
+		t.Errorf("expected no polling, got %d requests", n)
+	}
+}
@@ -0,0 +1,549 @@
+// Package miniyaml parses the subset of YAML used by GitHub workflow files
+// and workflow-trigwait pipeline files: block mappings and sequences, flow
+// sequences and mappings, quoted and plain scalars, literal (|) and folded
+// (>) block scalars, and comments. Anchors, aliases, tags and multiple
+// documents are not supported.
+//
+// Values decode to map[string]interface{}, []interface{}, string, bool,
+// int64, float64 or nil, like encoding/json decodes into interface{}.
+package miniyaml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// line is a source line with its comment stripped. text is empty for blank
+// and comment-only lines.
+type line struct {
+	num    int
+	indent int
+	text   string
+	raw    string
+}
+
+// Unmarshal parses a YAML document.
+func Unmarshal(data []byte) (interface{}, error) {
+	p := &parser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if indentation := raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))]; strings.ContainsRune(indentation, '\t') && strings.TrimSpace(raw) != "" {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		text := strings.TrimRight(stripComment(raw), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "---" || strings.HasPrefix(trimmed, "%") && len(p.lines) == 0 {
+			continue
+		}
+		p.lines = append(p.lines, line{num: i + 1, indent: len(text) - len(trimmed), text: trimmed, raw: raw})
+	}
+
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	v, err := p.parseBlock(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, p.errorf("unexpected content %q", p.lines[p.pos].text)
+	}
+	return v, nil
+}
+
+type parser struct {
+	lines []line
+	pos   int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	num := 0
+	if p.pos < len(p.lines) {
+		num = p.lines[p.pos].num
+	} else if len(p.lines) > 0 {
+		num = p.lines[len(p.lines)-1].num
+	}
+	return fmt.Errorf("line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].text == "" {
+		p.pos++
+	}
+}
+
+// parseBlock parses the mapping or sequence whose entries start at indent.
+func (p *parser) parseBlock(indent int) (interface{}, error) {
+	p.skipBlank()
+	if isSeqItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *parser) parseSequence(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return items, nil
+		}
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			return items, nil
+		}
+		if l.indent > indent {
+			return nil, p.errorf("bad indentation of a sequence entry")
+		}
+		if !isSeqItem(l.text) {
+			return items, nil
+		}
+
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			item, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+
+		// "- key: value" and "- - item" start a nested block at the
+		// column of their content
+		col := l.indent + len(l.text) - len(rest)
+		if isSeqItem(rest) || isMappingEntry(rest) {
+			p.lines[p.pos] = line{num: l.num, indent: col, text: rest, raw: l.raw}
+			item, err := p.parseBlock(col)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+
+		p.pos++
+		item, err := p.parseValue(rest, indent)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+func (p *parser) parseMapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) {
+			return m, nil
+		}
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			return m, nil
+		}
+		if l.indent > indent {
+			return nil, p.errorf("bad indentation of a mapping entry")
+		}
+		if isSeqItem(l.text) {
+			return m, nil
+		}
+
+		key, rest, ok := splitMappingEntry(l.text)
+		if !ok {
+			return nil, p.errorf("expected a mapping entry, got %q", l.text)
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		p.pos++
+
+		if rest == "" {
+			// A sequence may start at the key's own indentation
+			p.skipBlank()
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSeqItem(p.lines[p.pos].text) {
+				v, err := p.parseSequence(indent)
+				if err != nil {
+					return nil, err
+				}
+				m[key] = v
+				continue
+			}
+			v, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+
+		v, err := p.parseValue(rest, indent)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+}
+
+// parseNested parses the block indented deeper than parent, or returns nil
+// if there is none.
+func (p *parser) parseNested(parent int) (interface{}, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= parent {
+		return nil, nil
+	}
+	return p.parseBlock(p.lines[p.pos].indent)
+}
+
+// parseValue parses an inline value; block scalars and multi-line flow
+// collections consume the following lines.
+func (p *parser) parseValue(text string, parent int) (interface{}, error) {
+	switch {
+	case text == "|" || text == ">" || strings.HasPrefix(text, "|") && len(text) <= 3 || strings.HasPrefix(text, ">") && len(text) <= 3:
+		return p.parseBlockScalar(text, parent), nil
+	case strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{"):
+		for !balanced(text) && p.pos < len(p.lines) {
+			text += " " + p.lines[p.pos].text
+			p.pos++
+		}
+		f := &flowParser{s: text}
+		v, err := f.parseValue()
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		f.skipSpace()
+		if f.i < len(f.s) {
+			return nil, p.errorf("unexpected %q after flow collection", f.s[f.i:])
+		}
+		return v, nil
+	case strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'"):
+		s, n, err := unquote(text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if strings.TrimSpace(text[n:]) != "" {
+			return nil, p.errorf("unexpected %q after quoted string", text[n:])
+		}
+		return s, nil
+	}
+
+	// Plain scalars may continue on more deeply indented lines
+	for p.pos < len(p.lines) && p.lines[p.pos].indent > parent && p.lines[p.pos].text != "" &&
+		!isMappingEntry(p.lines[p.pos].text) && !isSeqItem(p.lines[p.pos].text) {
+		text += " " + p.lines[p.pos].text
+		p.pos++
+	}
+	return plainScalar(text), nil
+}
+
+// parseBlockScalar reads a literal or folded block scalar. Comment stripping
+// is undone by reading the raw lines.
+func (p *parser) parseBlockScalar(header string, parent int) string {
+	folded := header[0] == '>'
+	chomp := strings.TrimLeft(header[1:], "0123456789")
+
+	var lines []string
+	indent := -1
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		raw := strings.TrimRight(l.raw, " \t\r")
+		trimmed := strings.TrimLeft(raw, " ")
+		if trimmed != "" {
+			rawIndent := len(raw) - len(trimmed)
+			if rawIndent <= parent {
+				break
+			}
+			if indent < 0 {
+				indent = rawIndent
+			}
+			if rawIndent < indent {
+				break
+			}
+			lines = append(lines, raw[indent:])
+		} else {
+			lines = append(lines, "")
+		}
+		p.pos++
+	}
+	// Trailing blank lines belong to the document, not the scalar
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var s string
+	if folded {
+		var b strings.Builder
+		for i, l := range lines {
+			switch {
+			case i == 0:
+			case l == "" || lines[i-1] == "":
+				b.WriteString("\n")
+			default:
+				b.WriteString(" ")
+			}
+			b.WriteString(l)
+		}
+		s = b.String()
+	} else {
+		s = strings.Join(lines, "\n")
+	}
+	if chomp != "-" && s != "" {
+		s += "\n"
+	}
+	return s
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func isMappingEntry(text string) bool {
+	_, _, ok := splitMappingEntry(text)
+	return ok
+}
+
+// splitMappingEntry splits "key: value" at the first ": " outside quotes.
+func splitMappingEntry(text string) (key, rest string, ok bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		k, n, err := unquote(text)
+		if err != nil || !strings.HasPrefix(text[n:], ":") {
+			return "", "", false
+		}
+		after := text[n+1:]
+		if after != "" && after[0] != ' ' {
+			return "", "", false
+		}
+		return k, strings.TrimSpace(after), true
+	}
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return "", "", false
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i == len(text)-1 || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// stripComment removes a trailing "# comment" that is outside quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+					i++
+				} else {
+					quote = 0
+				}
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" \t[{,:-", rune(s[i-1])) {
+				quote = c
+			}
+		case c == '#':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '\t' {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
+
+func balanced(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// unquote parses the quoted string at the start of s and returns it with
+// the number of bytes consumed.
+func unquote(s string) (string, int, error) {
+	q := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case q == '\'' && c == '\'':
+			if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), i + 1, nil
+		case q == '"' && c == '"':
+			return b.String(), i + 1, nil
+		case q == '"' && c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '0':
+				b.WriteByte(0)
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted string %s", s)
+}
+
+// plainScalar resolves an unquoted scalar to its YAML 1.2 core schema type.
+func plainScalar(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if strings.ContainsAny(s, ".eE") && strings.IndexFunc(s, func(r rune) bool { return r >= '0' && r <= '9' }) >= 0 {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+// flowParser parses flow collections such as [a, b] and {k: v}.
+type flowParser struct {
+	s string
+	i int
+}
+
+func (f *flowParser) skipSpace() {
+	for f.i < len(f.s) && (f.s[f.i] == ' ' || f.s[f.i] == '\t') {
+		f.i++
+	}
+}
+
+func (f *flowParser) parseValue() (interface{}, error) {
+	f.skipSpace()
+	if f.i >= len(f.s) {
+		return nil, fmt.Errorf("unexpected end of flow collection")
+	}
+	switch f.s[f.i] {
+	case '[':
+		return f.parseSequence()
+	case '{':
+		return f.parseMapping()
+	case '"', '\'':
+		s, n, err := unquote(f.s[f.i:])
+		if err != nil {
+			return nil, err
+		}
+		f.i += n
+		return s, nil
+	}
+	start := f.i
+	for f.i < len(f.s) && !strings.ContainsRune(",]}", rune(f.s[f.i])) {
+		if f.s[f.i] == ':' && (f.i+1 == len(f.s) || f.s[f.i+1] == ' ') {
+			break
+		}
+		f.i++
+	}
+	return plainScalar(strings.TrimSpace(f.s[start:f.i])), nil
+}
+
+func (f *flowParser) parseSequence() (interface{}, error) {
+	f.i++ // [
+	items := []interface{}{}
+	for {
+		f.skipSpace()
+		if f.i < len(f.s) && f.s[f.i] == ']' {
+			f.i++
+			return items, nil
+		}
+		v, err := f.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		f.skipSpace()
+		if f.i >= len(f.s) {
+			return nil, fmt.Errorf("unterminated flow sequence")
+		}
+		switch f.s[f.i] {
+		case ',':
+			f.i++
+		case ']':
+		default:
+			return nil, fmt.Errorf("unexpected %q in flow sequence", f.s[f.i])
+		}
+	}
+}
+
+func (f *flowParser) parseMapping() (interface{}, error) {
+	f.i++ // {
+	m := map[string]interface{}{}
+	for {
+		f.skipSpace()
+		if f.i < len(f.s) && f.s[f.i] == '}' {
+			f.i++
+			return m, nil
+		}
+		k, err := f.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		key := fmt.Sprint(k)
+		if k == nil {
+			key = ""
+		}
+		f.skipSpace()
+		var v interface{}
+		if f.i < len(f.s) && f.s[f.i] == ':' {
+			f.i++
+			if v, err = f.parseValue(); err != nil {
+				return nil, err
+			}
+			f.skipSpace()
+		}
+		m[key] = v
+		if f.i >= len(f.s) {
+			return nil, fmt.Errorf("unterminated flow mapping")
+		}
+		switch f.s[f.i] {
+		case ',':
+			f.i++
+		case '}':
+		default:
+			return nil, fmt.Errorf("unexpected %q in flow mapping", f.s[f.i])
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package miniyaml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshal_Workflow(t *testing.T) {
+	src := `# Deploy workflow
+name: Deploy
+run-name: "Deploy ${{ inputs.distinct_id }}"
+
+on:
+  push:
+    branches: [main, 'release/*']
+  workflow_dispatch:
+    inputs:
+      environment:
+        description: Target environment # trailing comment
+        required: true
+        type: choice
+        options:
+          - staging
+          - production
+      dry:
+        type: boolean
+        default: false
+
+jobs:
+  deploy:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+      - name: Deploy
+        run: |
+          echo "deploying" # not a comment
+          ./deploy.sh
+`
+	got, err := Unmarshal([]byte(src))
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	doc := got.(map[string]interface{})
+	if doc["run-name"] != "Deploy ${{ inputs.distinct_id }}" {
+		t.Errorf("unexpected run-name: %v", doc["run-name"])
+	}
+
+	on := doc["on"].(map[string]interface{})
+	branches := on["push"].(map[string]interface{})["branches"]
+	if !reflect.DeepEqual(branches, []interface{}{"main", "release/*"}) {
+		t.Errorf("unexpected branches: %#v", branches)
+	}
+
+	inputs := on["workflow_dispatch"].(map[string]interface{})["inputs"].(map[string]interface{})
+	env := inputs["environment"].(map[string]interface{})
+	if env["required"] != true || env["description"] != "Target environment" {
+		t.Errorf("unexpected environment input: %#v", env)
+	}
+	if !reflect.DeepEqual(env["options"], []interface{}{"staging", "production"}) {
+		t.Errorf("unexpected options: %#v", env["options"])
+	}
+	if inputs["dry"].(map[string]interface{})["default"] != false {
+		t.Errorf("expected boolean default")
+	}
+
+	steps := doc["jobs"].(map[string]interface{})["deploy"].(map[string]interface{})["steps"].([]interface{})
+	if len(steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(steps))
+	}
+	run := steps[1].(map[string]interface{})["run"]
+	if run != "echo \"deploying\" # not a comment\n./deploy.sh\n" {
+		t.Errorf("unexpected block scalar: %q", run)
+	}
+}
+
+func TestUnmarshal_Scalars(t *testing.T) {
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{"a: 1", map[string]interface{}{"a": int64(1)}},
+		{"a: 1.5", map[string]interface{}{"a": 1.5}},
+		{"a: ~", map[string]interface{}{"a": nil}},
+		{"a: 'it''s'", map[string]interface{}{"a": "it's"}},
+		{`a: "x\ty"`, map[string]interface{}{"a": "x\ty"}},
+		{"a: v1.2.3", map[string]interface{}{"a": "v1.2.3"}},
+		{"a: {b: 1, c: [x, y]}", map[string]interface{}{"a": map[string]interface{}{"b": int64(1), "c": []interface{}{"x", "y"}}}},
+		{"a:\n- x\n- y", map[string]interface{}{"a": []interface{}{"x", "y"}}},
+		{"- a: 1\n  b: 2\n- c", []interface{}{map[string]interface{}{"a": int64(1), "b": int64(2)}, "c"}},
+		{"a: >-\n  folded\n  text\n", map[string]interface{}{"a": "folded text"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		got, err := Unmarshal([]byte(tt.src))
+		if err != nil {
+			t.Errorf("Unmarshal(%q) failed: %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Unmarshal(%q) = %#v, want %#v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshal_Errors(t *testing.T) {
+	for _, src := range []string{
+		"a: 1\na: 2",
+		"a:\n\tb: 1",
+		"a: [1, 2",
+		"a: 'open",
+		"a: 1\n  b: 2",
+	} {
+		if _, err := Unmarshal([]byte(src)); err == nil {
+			t.Errorf("Unmarshal(%q): expected error", src)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package otlp records trace spans and exports them to an OpenTelemetry
+// collector with the OTLP/HTTP JSON protocol. Spans are kept in memory and
+// sent in a single request by Flush, which suits a short-lived process.
+//
+// Trace context is propagated in the W3C traceparent format.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+// maxSpans bounds memory use during very long waits. Later spans are
+// dropped and counted.
+const maxSpans = 10000
+
+// Tracer records spans for one service. It implements trigwait.Tracer.
+type Tracer struct {
+	endpoint   string
+	headers    map[string]string
+	service    string
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	spans   []*spanData
+	dropped int
+}
+
+// New returns a tracer exporting to the OTLP/HTTP endpoint, e.g.
+// http://localhost:4318. Spans are sent to its /v1/traces path.
+func New(endpoint, service string, headers map[string]string) *Tracer {
+	return &Tracer{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		headers:    headers,
+		service:    service,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ParseHeaders parses headers in the OTEL_EXPORTER_OTLP_HEADERS format:
+// comma-separated key=value pairs with URL-encoded values.
+func ParseHeaders(s string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid header %q: expected key=value", pair)
+		}
+		value = strings.TrimSpace(value)
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			value = unescaped
+		}
+		headers[strings.TrimSpace(key)] = value
+	}
+	return headers, nil
+}
+
+// spanContext identifies a span, local or remote.
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+type contextKey struct{}
+
+// TraceParent returns the W3C traceparent of the span in ctx, or "" when
+// there is none.
+func TraceParent(ctx context.Context) string {
+	sc, ok := ctx.Value(contextKey{}).(spanContext)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("00-%x-%x-01", sc.traceID, sc.spanID)
+}
+
+// WithTraceParent returns a context whose spans continue the trace of the
+// remote parent traceparent. An invalid traceparent is ignored.
+func WithTraceParent(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+	var sc spanContext
+	if _, err := hex.Decode(sc.traceID[:], []byte(parts[1])); err != nil {
+		return ctx
+	}
+	if _, err := hex.Decode(sc.spanID[:], []byte(parts[2])); err != nil {
+		return ctx
+	}
+	if sc.traceID == ([16]byte{}) || sc.spanID == ([8]byte{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, sc)
+}
+
+// Start starts a span as a child of the span in ctx, or as the root of a
+// new trace.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, trigwait.Span) {
+	return t.StartAt(ctx, name, time.Now())
+}
+
+// StartAt starts a span that began at start, for work measured before the
+// tracer existed.
+func (t *Tracer) StartAt(ctx context.Context, name string, start time.Time) (context.Context, trigwait.Span) {
+	s := &span{tracer: t, name: name, start: start}
+	if parent, ok := ctx.Value(contextKey{}).(spanContext); ok {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return context.WithValue(ctx, contextKey{}, spanContext{traceID: s.traceID, spanID: s.spanID}), s
+}
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+type span struct {
+	tracer   *Tracer
+	name     string
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	start    time.Time
+
+	mu    sync.Mutex
+	attrs []attribute
+	ended bool
+}
+
+func (s *span) SetAttr(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attribute{key, value})
+}
+
+func (s *span) End(err error) {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	end := time.Now()
+	data := spanData{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        encodeAttributes(s.attrs),
+		Status:            status{Code: statusOK},
+	}
+	s.mu.Unlock()
+
+	if s.parentID != ([8]byte{}) {
+		data.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	if err != nil {
+		data.Status = status{Code: statusError, Message: err.Error()}
+	}
+	s.tracer.record(&data)
+}
+
+func (t *Tracer) record(data *spanData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.spans) >= maxSpans {
+		t.dropped++
+		return
+	}
+	t.spans = append(t.spans, data)
+}
+
+// Flush sends the ended spans to the collector and forgets them.
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	spans := t.spans
+	dropped := t.dropped
+	t.spans, t.dropped = nil, 0
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(exportRequest{ResourceSpans: []resourceSpans{{
+		Resource: resource{Attributes: encodeAttributes([]attribute{{"service.name", t.service}})},
+		ScopeSpans: []scopeSpans{{
+			Scope: scope{Name: t.service},
+			Spans: spans,
+		}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.endpoint+"/v1/traces", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	if dropped > 0 {
+		return fmt.Errorf("exported %d spans, dropped %d over the limit of %d", len(spans), dropped, maxSpans)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// collector records the spans of the export requests it receives.
+func collector(t *testing.T, headers http.Header) (*httptest.Server, *[]spanData) {
+	t.Helper()
+	var spans []spanData
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		for k := range headers {
+			if r.Header.Get(k) != headers.Get(k) {
+				t.Errorf("expected header %s: %s, got %q", k, headers.Get(k), r.Header.Get(k))
+			}
+		}
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []spanData `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid export request: %v", err)
+		}
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &spans
+}
+
+func TestTracer_Flush(t *testing.T) {
+	server, spans := collector(t, http.Header{"Authorization": []string{"Bearer secret"}})
+	tracer := New(server.URL+"/", "test", map[string]string{"Authorization": "Bearer secret"})
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.SetAttr("run.id", int64(42))
+	child.SetAttr("ok", true)
+	child.End(errors.New("boom"))
+	child.End(nil) // ignored
+	root.End(nil)
+
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if len(*spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(*spans))
+	}
+	c, r := (*spans)[0], (*spans)[1]
+	if c.Name != "child" || r.Name != "root" {
+		t.Fatalf("unexpected spans %q, %q", c.Name, r.Name)
+	}
+	if c.TraceID != r.TraceID || c.ParentSpanID != r.SpanID || r.ParentSpanID != "" {
+		t.Errorf("expected child of root in one trace, got %+v and %+v", c, r)
+	}
+	if len(r.TraceID) != 32 || len(r.SpanID) != 16 {
+		t.Errorf("expected hex IDs, got %q and %q", r.TraceID, r.SpanID)
+	}
+	if c.Status.Code != statusError || c.Status.Message != "boom" || r.Status.Code != statusOK {
+		t.Errorf("unexpected statuses %+v and %+v", c.Status, r.Status)
+	}
+	if len(c.Attributes) != 2 || *c.Attributes[0].Value.IntValue != "42" || !*c.Attributes[1].Value.BoolValue {
+		t.Errorf("unexpected attributes %+v", c.Attributes)
+	}
+
+	// Flushed spans are not sent again
+	*spans = nil
+	if err := tracer.Flush(context.Background()); err != nil || len(*spans) != 0 {
+		t.Errorf("expected nothing to flush, got %d spans (%v)", len(*spans), err)
+	}
+}
+
+func TestTraceParent(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := WithTraceParent(context.Background(), parent)
+	if got := TraceParent(ctx); got != parent {
+		t.Errorf("expected %s, got %s", parent, got)
+	}
+
+	tracer := New("http://localhost:4318", "test", nil)
+	ctx, _ = tracer.Start(ctx, "child")
+	got := TraceParent(ctx)
+	if !strings.HasPrefix(got, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || got == parent {
+		t.Errorf("expected a child in the remote trace, got %s", got)
+	}
+
+	for _, invalid := range []string{"", "garbage", "00-zz-00f067aa0ba902b7-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+		if got := TraceParent(WithTraceParent(context.Background(), invalid)); got != "" {
+			t.Errorf("expected %q to be ignored, got %s", invalid, got)
+		}
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders("Authorization=Bearer%20secret, x-tenant = a=b")
+	if err != nil {
+		t.Fatalf("ParseHeaders failed: %v", err)
+	}
+	if headers["Authorization"] != "Bearer secret" || headers["x-tenant"] != "a=b" {
+		t.Errorf("unexpected headers %v", headers)
+	}
+	if _, err := ParseHeaders("novalue"); err == nil {
+		t.Error("expected error for a header without a value")
+	}
+}
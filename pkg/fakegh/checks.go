@@ -0,0 +1,235 @@
+package fakegh
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CommitStatus is a commit status created through the API.
+type CommitStatus struct {
+	ID          int64     `json:"id"`
+	Owner       string    `json:"-"`
+	Repo        string    `json:"-"`
+	SHA         string    `json:"-"`
+	State       string    `json:"state"`
+	TargetURL   string    `json:"target_url,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Context     string    `json:"context"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CheckRun is a check run created through the API.
+type CheckRun struct {
+	ID         int64  `json:"id"`
+	Owner      string `json:"-"`
+	Repo       string `json:"-"`
+	HeadSHA    string `json:"head_sha"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion,omitempty"`
+	DetailsURL string `json:"details_url,omitempty"`
+	ExternalID string `json:"external_id,omitempty"`
+	Output     struct {
+		Title   string `json:"title,omitempty"`
+		Summary string `json:"summary,omitempty"`
+	} `json:"output"`
+}
+
+// Statuses returns a snapshot of the commit statuses created in a
+// repository, oldest first.
+func (s *Server) Statuses(owner, repo string) []CommitStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var statuses []CommitStatus
+	for _, status := range s.statuses {
+		if status.Owner == owner && status.Repo == repo {
+			statuses = append(statuses, *status)
+		}
+	}
+	return statuses
+}
+
+// CheckRuns returns a snapshot of the check runs created in a repository,
+// oldest first.
+func (s *Server) CheckRuns(owner, repo string) []CheckRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var checkRuns []CheckRun
+	for _, checkRun := range s.checkRuns {
+		if checkRun.Owner == owner && checkRun.Repo == repo {
+			checkRuns = append(checkRuns, *checkRun)
+		}
+	}
+	return checkRuns
+}
+
+var validStates = []string{"error", "failure", "pending", "success"}
+
+func (s *Server) handleCreateStatus(w http.ResponseWriter, r *http.Request, repo *Repo, sha string) {
+	status := &CommitStatus{}
+	if err := json.NewDecoder(r.Body).Decode(status); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+	if !contains(validStates, status.State) {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: state is not included in the list")
+		return
+	}
+	if len(status.Description) > 140 {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: description is too long (maximum is 140 characters)")
+		return
+	}
+	if status.Context == "" {
+		status.Context = "default"
+	}
+	status.ID = int64(len(s.statuses) + 1)
+	status.Owner, status.Repo, status.SHA = repo.Owner, repo.Name, sha
+	status.CreatedAt = s.Now().UTC()
+	s.statuses = append(s.statuses, status)
+	writeJSON(w, http.StatusCreated, status)
+}
+
+var (
+	validCheckStatuses    = []string{"queued", "in_progress", "completed"}
+	validCheckConclusions = []string{"action_required", "cancelled", "failure", "neutral", "success", "skipped", "stale", "timed_out"}
+)
+
+func (s *Server) handleCreateCheckRun(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	checkRun := &CheckRun{Status: "queued"}
+	if err := json.NewDecoder(r.Body).Decode(checkRun); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+	if checkRun.Name == "" || checkRun.HeadSHA == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: name and head_sha are required")
+		return
+	}
+	if !s.validCheckRun(w, checkRun) {
+		return
+	}
+	checkRun.ID = int64(len(s.checkRuns) + 1)
+	checkRun.Owner, checkRun.Repo = repo.Owner, repo.Name
+	s.checkRuns = append(s.checkRuns, checkRun)
+	writeJSON(w, http.StatusCreated, checkRun)
+}
+
+func (s *Server) handleUpdateCheckRun(w http.ResponseWriter, r *http.Request, repo *Repo, id string) {
+	var checkRun *CheckRun
+	for _, c := range s.checkRuns {
+		if strconv.FormatInt(c.ID, 10) == id && c.Owner == repo.Owner && c.Repo == repo.Name {
+			checkRun = c
+		}
+	}
+	if checkRun == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	updated := *checkRun
+	if err := json.NewDecoder(r.Body).Decode(&updated); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+	if updated.Conclusion != "" && updated.Status != "completed" {
+		// Setting a conclusion completes the check run, like on GitHub
+		updated.Status = "completed"
+	}
+	if !s.validCheckRun(w, &updated) {
+		return
+	}
+	updated.ID, updated.Owner, updated.Repo = checkRun.ID, checkRun.Owner, checkRun.Repo
+	*checkRun = updated
+	writeJSON(w, http.StatusOK, checkRun)
+}
+
+func (s *Server) validCheckRun(w http.ResponseWriter, checkRun *CheckRun) bool {
+	switch {
+	case !contains(validCheckStatuses, checkRun.Status):
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: status is not included in the list")
+	case checkRun.Conclusion != "" && !contains(validCheckConclusions, checkRun.Conclusion):
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: conclusion is not included in the list")
+	case checkRun.Status == "completed" && checkRun.Conclusion == "":
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed: conclusion is required when status is completed")
+	default:
+		return true
+	}
+	return false
+}
+
+// handleCombinedStatus lists the latest status for each context on ref,
+// newest first and paginated. Refs are matched against the SHAs statuses
+// were created on.
+func (s *Server) handleCombinedStatus(w http.ResponseWriter, r *http.Request, repo *Repo, ref string) {
+	latest := []*CommitStatus{}
+	seen := map[string]bool{}
+	for i := len(s.statuses) - 1; i >= 0; i-- {
+		status := s.statuses[i]
+		if status.Owner != repo.Owner || status.Repo != repo.Name || status.SHA != ref || seen[status.Context] {
+			continue
+		}
+		seen[status.Context] = true
+		latest = append(latest, status)
+	}
+
+	state := "success"
+	for _, status := range latest {
+		if status.State != "success" {
+			state = status.State
+			if state != "pending" {
+				break
+			}
+		}
+	}
+	if len(latest) == 0 {
+		state = "pending"
+	}
+	page, perPage := pagination(r.URL.Query())
+	total := len(latest)
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"state":       state,
+		"sha":         ref,
+		"total_count": total,
+		"statuses":    latest[start:end],
+	})
+}
+
+// handleListCheckRuns lists the latest check run of each name on ref, newest
+// first, like GitHub's default filter=latest.
+func (s *Server) handleListCheckRuns(w http.ResponseWriter, r *http.Request, repo *Repo, ref string) {
+	all := r.URL.Query().Get("filter") == "all"
+	checkRuns := []*CheckRun{}
+	seen := map[string]bool{}
+	for i := len(s.checkRuns) - 1; i >= 0; i-- {
+		checkRun := s.checkRuns[i]
+		if checkRun.Owner != repo.Owner || checkRun.Repo != repo.Name || checkRun.HeadSHA != ref || (!all && seen[checkRun.Name]) {
+			continue
+		}
+		seen[checkRun.Name] = true
+		checkRuns = append(checkRuns, checkRun)
+	}
+	page, perPage := pagination(r.URL.Query())
+	total := len(checkRuns)
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total_count": total,
+		"check_runs":  checkRuns[start:end],
+	})
+}
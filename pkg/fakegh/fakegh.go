@@ -0,0 +1,374 @@
+// Package fakegh is a stateful, in-memory fake of the parts of the GitHub
+// REST API used by workflow-trigwait: repositories, branches, workflows,
+// workflow dispatches, runs, jobs, logs, artifacts, cancellation, commit
+// statuses and check runs.
+//
+// Dispatched runs progress from queued to in_progress to completed based on
+// the workflow's QueueTime and RunTime, measured with the server's clock.
+package fakegh
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Duration is a time.Duration that is read from JSON as a number of seconds
+// or a duration string such as "1m30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).Seconds())
+}
+
+// Config describes the repositories served by a Server. It is the format of
+// the fakegh binary's -config file.
+type Config struct {
+	Repos []*Repo `json:"repos"`
+}
+
+// Repo is a repository and its workflows.
+type Repo struct {
+	Owner         string   `json:"owner"`
+	Name          string   `json:"name"`
+	DefaultBranch string   `json:"default_branch,omitempty"`
+	Branches      []string `json:"branches,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	Private       bool     `json:"private,omitempty"`
+	// ReadOnly makes the token lack write access: the repository reports
+	// push: false and dispatches are rejected with 403.
+	ReadOnly  bool        `json:"read_only,omitempty"`
+	Workflows []*Workflow `json:"workflows,omitempty"`
+}
+
+// Workflow describes a workflow file and how its runs behave.
+type Workflow struct {
+	ID    int64  `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	State string `json:"state,omitempty"`
+	// RunName is the workflow's run-name. ${{ inputs.<name> }} expressions
+	// are replaced with the dispatched inputs. Defaults to Name.
+	RunName string `json:"run_name,omitempty"`
+	// Inputs are the declared workflow_dispatch inputs. When non-nil,
+	// dispatches with undeclared inputs are rejected with 422.
+	Inputs []string `json:"inputs,omitempty"`
+	// NoDispatch makes the workflow lack a workflow_dispatch trigger.
+	NoDispatch bool `json:"no_dispatch,omitempty"`
+	// Source is the content of the workflow file. When empty, a file
+	// declaring the workflow's trigger, run-name and Inputs is generated.
+	Source string `json:"source,omitempty"`
+	// Conclusion of completed runs. Defaults to success. It can be
+	// overridden per dispatch with the fake_conclusion input.
+	Conclusion string `json:"conclusion,omitempty"`
+	// Jobs of each run. Defaults to a single job named after the workflow.
+	Jobs []JobSpec `json:"jobs,omitempty"`
+	// QueueTime is how long runs stay queued; RunTime how long they stay
+	// in_progress before completing.
+	QueueTime Duration `json:"queue_time,omitempty"`
+	RunTime   Duration `json:"run_time,omitempty"`
+	// DiscoveryDelay is how long a dispatched run stays invisible in run
+	// listings, like the lag of the real API.
+	DiscoveryDelay Duration `json:"discovery_delay,omitempty"`
+}
+
+// JobSpec describes a job of a workflow.
+type JobSpec struct {
+	Name string `json:"name"`
+	// Conclusion defaults to the run's conclusion.
+	Conclusion string `json:"conclusion,omitempty"`
+}
+
+// Run is a dispatched workflow run.
+type Run struct {
+	ID           int64
+	Owner        string
+	Repo         string
+	Workflow     *Workflow
+	Ref          string
+	Inputs       map[string]interface{}
+	DisplayTitle string
+	Event        string
+	CreatedAt    time.Time
+	Conclusion   string
+	// CancelledAt is set when the run was cancelled through the API.
+	CancelledAt time.Time
+}
+
+// Server is a fake GitHub API. The zero value is not usable; use New.
+type Server struct {
+	// Token, when set, is the only bearer token accepted.
+	Token string
+	// Scopes, when non-nil, are reported in the X-OAuth-Scopes header like
+	// for a classic personal access token.
+	Scopes []string
+	// Now is the server's clock. Defaults to time.Now.
+	Now func() time.Time
+	// RateLimit is the number of requests allowed per RateLimitWindow.
+	// Zero means unlimited.
+	RateLimit       int
+	RateLimitWindow time.Duration
+
+	mu          sync.Mutex
+	repos       map[string]*Repo
+	runs        []*Run
+	statuses    []*CommitStatus
+	checkRuns   []*CheckRun
+	nextID      int64
+	requests    int
+	notModified int
+	windowStart time.Time
+	windowUsed  int
+	failures    []injectedFailure
+}
+
+type injectedFailure struct {
+	status int
+	count  int
+}
+
+// New returns a server serving the repositories in config, which may be nil.
+func New(config *Config) *Server {
+	s := &Server{
+		Now:             time.Now,
+		RateLimitWindow: time.Hour,
+		repos:           make(map[string]*Repo),
+		nextID:          1000,
+	}
+	if config != nil {
+		for _, repo := range config.Repos {
+			s.AddRepo(repo)
+		}
+	}
+	return s
+}
+
+// AddRepo adds or replaces a repository, filling in defaults.
+func (s *Server) AddRepo(repo *Repo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if repo.DefaultBranch == "" {
+		repo.DefaultBranch = "main"
+	}
+	if !contains(repo.Branches, repo.DefaultBranch) {
+		repo.Branches = append(repo.Branches, repo.DefaultBranch)
+	}
+	for _, wf := range repo.Workflows {
+		s.fillWorkflowDefaults(wf)
+	}
+	s.repos[repoKey(repo.Owner, repo.Name)] = repo
+}
+
+// AddWorkflow adds a workflow to an existing repository.
+func (s *Server) AddWorkflow(owner, repo string, wf *Workflow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.repos[repoKey(owner, repo)]
+	if !ok {
+		return fmt.Errorf("unknown repository %s/%s", owner, repo)
+	}
+	s.fillWorkflowDefaults(wf)
+	r.Workflows = append(r.Workflows, wf)
+	return nil
+}
+
+func (s *Server) fillWorkflowDefaults(wf *Workflow) {
+	if wf.ID == 0 {
+		s.nextID++
+		wf.ID = s.nextID
+	}
+	if wf.Path == "" {
+		wf.Path = ".github/workflows/" + strings.ToLower(strings.ReplaceAll(wf.Name, " ", "-")) + ".yml"
+	} else if !strings.Contains(wf.Path, "/") {
+		wf.Path = ".github/workflows/" + wf.Path
+	}
+	if wf.Name == "" {
+		wf.Name = wf.Path
+	}
+	if wf.State == "" {
+		wf.State = "active"
+	}
+	if wf.Conclusion == "" {
+		wf.Conclusion = "success"
+	}
+}
+
+// FailNext makes the next count requests fail with status.
+func (s *Server) FailNext(status, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, injectedFailure{status: status, count: count})
+}
+
+// Requests returns the number of requests served so far.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// NotModified returns the number of requests answered with 304 Not Modified.
+func (s *Server) NotModified() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notModified
+}
+
+// Runs returns a snapshot of all runs, oldest first.
+func (s *Server) Runs() []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := make([]Run, len(s.runs))
+	for i, run := range s.runs {
+		runs[i] = *run
+	}
+	return runs
+}
+
+// SetConclusion changes the conclusion a run completes with.
+func (s *Server) SetConclusion(runID int64, conclusion string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run := s.findRun(runID)
+	if run == nil {
+		return fmt.Errorf("unknown run %d", runID)
+	}
+	run.Conclusion = conclusion
+	return nil
+}
+
+func (s *Server) findRun(runID int64) *Run {
+	for _, run := range s.runs {
+		if run.ID == runID {
+			return run
+		}
+	}
+	return nil
+}
+
+func (s *Server) findWorkflow(repo *Repo, idOrFile string) *Workflow {
+	for _, wf := range repo.Workflows {
+		if fmt.Sprint(wf.ID) == idOrFile || wf.Path == idOrFile || pathBase(wf.Path) == idOrFile {
+			return wf
+		}
+	}
+	return nil
+}
+
+// status returns the run's status and conclusion at the current time.
+func (s *Server) status(run *Run) (status, conclusion string) {
+	if !run.CancelledAt.IsZero() {
+		return "completed", "cancelled"
+	}
+	elapsed := s.Now().Sub(run.CreatedAt)
+	queueTime := time.Duration(run.Workflow.QueueTime)
+	runTime := time.Duration(run.Workflow.RunTime)
+	switch {
+	case elapsed < queueTime:
+		return "queued", ""
+	case elapsed < queueTime+runTime:
+		return "in_progress", ""
+	}
+	return "completed", run.Conclusion
+}
+
+// source returns the workflow file content.
+func (wf *Workflow) source() string {
+	if wf.Source != "" {
+		return wf.Source
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "name: %q\n", wf.Name)
+	if wf.RunName != "" {
+		fmt.Fprintf(&b, "run-name: %q\n", wf.RunName)
+	}
+	b.WriteString("on:\n")
+	if wf.NoDispatch {
+		b.WriteString("  push:\n")
+	} else {
+		b.WriteString("  workflow_dispatch:\n    inputs:\n")
+		for _, name := range append(append([]string{}, wf.Inputs...), "fake_conclusion") {
+			fmt.Fprintf(&b, "      %s:\n        required: false\n", name)
+		}
+	}
+	b.WriteString("jobs:\n")
+	for i, job := range wf.jobSpecs() {
+		fmt.Fprintf(&b, "  job%d:\n    name: %q\n    runs-on: ubuntu-latest\n    steps:\n      - run: echo ok\n", i+1, job.Name)
+	}
+	return b.String()
+}
+
+func (wf *Workflow) jobSpecs() []JobSpec {
+	if len(wf.Jobs) > 0 {
+		return wf.Jobs
+	}
+	return []JobSpec{{Name: wf.Name}}
+}
+
+var inputExpr = regexp.MustCompile(`\$\{\{\s*inputs\.([A-Za-z0-9_-]+)\s*\}\}`)
+
+// renderRunName evaluates ${{ inputs.<name> }} expressions in a run-name.
+func renderRunName(template string, inputs map[string]interface{}) string {
+	return inputExpr.ReplaceAllStringFunc(template, func(expr string) string {
+		name := inputExpr.FindStringSubmatch(expr)[1]
+		if v, ok := inputs[name]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	})
+}
+
+func repoKey(owner, repo string) string {
+	return strings.ToLower(owner + "/" + repo)
+}
+
+func pathBase(p string) string {
+	return p[strings.LastIndex(p, "/")+1:]
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
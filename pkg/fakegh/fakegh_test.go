@@ -0,0 +1,344 @@
+package fakegh
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clock is a manually advanced time source for Server.Now.
+type clock struct{ now time.Time }
+
+func newClock() *clock {
+	return &clock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) Now() time.Time          { return c.now }
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func testConfig(wf *Workflow) *Config {
+	return &Config{Repos: []*Repo{{Owner: "o", Name: "r", Branches: []string{"dev"}, Workflows: []*Workflow{wf}}}}
+}
+
+func do(t *testing.T, server *httptest.Server, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, server.URL+path, reader)
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestDispatchAndProgression(t *testing.T) {
+	c := newClock()
+	s := New(testConfig(&Workflow{
+		Name:      "Deploy",
+		Path:      "deploy.yml",
+		RunName:   "Deploy [${{ inputs.distinct_id }}]",
+		Inputs:    []string{"distinct_id", "env"},
+		QueueTime: Duration(10 * time.Second),
+		RunTime:   Duration(20 * time.Second),
+	}))
+	s.Now = c.Now
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	status, _ := do(t, server, "POST", "/repos/o/r/actions/workflows/deploy.yml/dispatches", map[string]interface{}{
+		"ref":    "dev",
+		"inputs": map[string]interface{}{"distinct_id": "abc123", "env": "prod"},
+	})
+	if status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", status)
+	}
+
+	_, list := do(t, server, "GET", "/repos/o/r/actions/workflows/deploy.yml/runs?event=workflow_dispatch&branch=dev", nil)
+	runs := list["workflow_runs"].([]interface{})
+	if len(runs) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(runs))
+	}
+	run := runs[0].(map[string]interface{})
+	if run["display_title"] != "Deploy [abc123]" {
+		t.Errorf("unexpected display_title: %v", run["display_title"])
+	}
+	id := int64(run["id"].(float64))
+	path := "/repos/o/r/actions/runs/" + strconv.FormatInt(id, 10)
+
+	for _, step := range []struct {
+		advance    time.Duration
+		status     string
+		conclusion interface{}
+	}{
+		{0, "queued", nil},
+		{15 * time.Second, "in_progress", nil},
+		{20 * time.Second, "completed", "success"},
+	} {
+		c.Advance(step.advance)
+		_, got := do(t, server, "GET", path, nil)
+		if got["status"] != step.status || got["conclusion"] != step.conclusion {
+			t.Errorf("after %v: expected %s/%v, got %v/%v", step.advance, step.status, step.conclusion, got["status"], got["conclusion"])
+		}
+	}
+
+	_, jobs := do(t, server, "GET", path+"/jobs", nil)
+	if jobs["total_count"].(float64) != 1 {
+		t.Errorf("expected 1 job, got %v", jobs["total_count"])
+	}
+}
+
+func TestDispatch_Rejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		workflow *Workflow
+		body     map[string]interface{}
+		status   int
+	}{
+		{"unknown workflow", &Workflow{Name: "Other"}, map[string]interface{}{"ref": "main"}, http.StatusNotFound},
+		{"no dispatch trigger", &Workflow{Name: "CI", Path: "ci.yml", NoDispatch: true}, map[string]interface{}{"ref": "main"}, http.StatusUnprocessableEntity},
+		{"unknown ref", &Workflow{Name: "CI", Path: "ci.yml"}, map[string]interface{}{"ref": "nope"}, http.StatusUnprocessableEntity},
+		{"undeclared input", &Workflow{Name: "CI", Path: "ci.yml", Inputs: []string{}}, map[string]interface{}{"ref": "main", "inputs": map[string]interface{}{"x": "1"}}, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(New(testConfig(tt.workflow)))
+			defer server.Close()
+
+			status, _ := do(t, server, "POST", "/repos/o/r/actions/workflows/ci.yml/dispatches", tt.body)
+			if status != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, status)
+			}
+		})
+	}
+}
+
+func TestListRuns_FiltersAndPagination(t *testing.T) {
+	c := newClock()
+	s := New(testConfig(&Workflow{Name: "CI", Path: "ci.yml", DiscoveryDelay: Duration(5 * time.Second)}))
+	s.Now = c.Now
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	for i := 0; i < 3; i++ {
+		do(t, server, "POST", "/repos/o/r/actions/workflows/ci.yml/dispatches", map[string]interface{}{"ref": "main"})
+		c.Advance(time.Second)
+	}
+	do(t, server, "POST", "/repos/o/r/actions/workflows/ci.yml/dispatches", map[string]interface{}{"ref": "dev"})
+
+	_, list := do(t, server, "GET", "/repos/o/r/actions/runs", nil)
+	if got := list["total_count"].(float64); got != 0 {
+		t.Errorf("expected runs to be hidden during discovery delay, got %v", got)
+	}
+
+	c.Advance(10 * time.Second)
+	_, list = do(t, server, "GET", "/repos/o/r/actions/workflows/ci.yml/runs?branch=main&per_page=2&page=2", nil)
+	if got := list["total_count"].(float64); got != 3 {
+		t.Errorf("expected 3 runs on main, got %v", got)
+	}
+	runs := list["workflow_runs"].([]interface{})
+	if len(runs) != 1 {
+		t.Fatalf("expected 1 run on page 2, got %d", len(runs))
+	}
+	if id := runs[0].(map[string]interface{})["id"].(float64); int64(id) != s.Runs()[0].ID {
+		t.Errorf("expected oldest run last, got %v", id)
+	}
+
+	_, list = do(t, server, "GET", "/repos/o/r/actions/runs?status=success", nil)
+	if got := list["total_count"].(float64); got != 4 {
+		t.Errorf("expected 4 successful runs, got %v", got)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s := New(testConfig(&Workflow{Name: "CI", Path: "ci.yml", RunTime: Duration(time.Hour)}))
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	do(t, server, "POST", "/repos/o/r/actions/workflows/ci.yml/dispatches", map[string]interface{}{"ref": "main"})
+	path := "/repos/o/r/actions/runs/" + strconv.FormatInt(s.Runs()[0].ID, 10)
+
+	if status, _ := do(t, server, "POST", path+"/cancel", nil); status != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", status)
+	}
+	_, run := do(t, server, "GET", path, nil)
+	if run["status"] != "completed" || run["conclusion"] != "cancelled" {
+		t.Errorf("expected completed/cancelled, got %v/%v", run["status"], run["conclusion"])
+	}
+	if status, _ := do(t, server, "POST", path+"/cancel", nil); status != http.StatusConflict {
+		t.Errorf("expected 409 cancelling a completed run, got %d", status)
+	}
+}
+
+func TestStatusesAndCheckRuns(t *testing.T) {
+	s := New(testConfig(&Workflow{Name: "CI", Path: "ci.yml"}))
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	if status, _ := do(t, server, "POST", "/repos/o/r/statuses/abc123", map[string]interface{}{"state": "pending", "context": "ci"}); status != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", status)
+	}
+	if status, _ := do(t, server, "POST", "/repos/o/r/statuses/abc123", map[string]interface{}{"state": "done"}); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an invalid state, got %d", status)
+	}
+	if statuses := s.Statuses("o", "r"); len(statuses) != 1 || statuses[0].SHA != "abc123" || statuses[0].State != "pending" {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+
+	status, created := do(t, server, "POST", "/repos/o/r/check-runs", map[string]interface{}{"name": "ci", "head_sha": "abc123", "status": "in_progress"})
+	if status != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", status)
+	}
+	path := "/repos/o/r/check-runs/" + strconv.FormatFloat(created["id"].(float64), 'f', -1, 64)
+	if status, _ := do(t, server, "PATCH", path, map[string]interface{}{"status": "completed"}); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 completing without a conclusion, got %d", status)
+	}
+	if status, _ := do(t, server, "PATCH", path, map[string]interface{}{"conclusion": "failure"}); status != http.StatusOK {
+		t.Errorf("expected 200, got %d", status)
+	}
+	if checkRuns := s.CheckRuns("o", "r"); len(checkRuns) != 1 || checkRuns[0].Status != "completed" || checkRuns[0].Conclusion != "failure" {
+		t.Errorf("unexpected check runs %+v", checkRuns)
+	}
+
+	do(t, server, "POST", "/repos/o/r/statuses/abc123", map[string]interface{}{"state": "success", "context": "ci"})
+	do(t, server, "POST", "/repos/o/r/statuses/abc123", map[string]interface{}{"state": "pending", "context": "lint"})
+	_, combined := do(t, server, "GET", "/repos/o/r/commits/abc123/status", nil)
+	if combined["state"] != "pending" || combined["total_count"].(float64) != 2 {
+		t.Errorf("expected the latest status of 2 contexts, got %v", combined)
+	}
+
+	do(t, server, "POST", "/repos/o/r/check-runs", map[string]interface{}{"name": "ci", "head_sha": "abc123"})
+	_, list := do(t, server, "GET", "/repos/o/r/commits/abc123/check-runs", nil)
+	if list["total_count"].(float64) != 1 || list["check_runs"].([]interface{})[0].(map[string]interface{})["status"] != "queued" {
+		t.Errorf("expected only the latest check run named ci, got %v", list)
+	}
+	if _, list := do(t, server, "GET", "/repos/o/r/commits/abc123/check-runs?filter=all", nil); list["total_count"].(float64) != 2 {
+		t.Errorf("expected 2 check runs with filter=all, got %v", list)
+	}
+}
+
+func TestLogsAndArtifacts(t *testing.T) {
+	s := New(testConfig(&Workflow{
+		Name: "CI",
+		Path: "ci.yml",
+		Jobs: []JobSpec{{Name: "build"}, {Name: "lint", Conclusion: "failure"}},
+	}))
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	do(t, server, "POST", "/repos/o/r/actions/workflows/ci.yml/dispatches", map[string]interface{}{"ref": "main"})
+	path := "/repos/o/r/actions/runs/" + strconv.FormatInt(s.Runs()[0].ID, 10)
+
+	resp, err := http.Get(server.URL + path + "/logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("logs are not a zip archive: %v", err)
+	}
+	if len(zr.File) != 2 || !strings.HasSuffix(zr.File[1].Name, "lint.txt") {
+		t.Errorf("unexpected log files: %v", zr.File)
+	}
+
+	_, artifacts := do(t, server, "GET", path+"/artifacts", nil)
+	if artifacts["total_count"].(float64) != 1 {
+		t.Errorf("expected 1 artifact, got %v", artifacts["total_count"])
+	}
+}
+
+func TestAuthRateLimitAndFailures(t *testing.T) {
+	s := New(testConfig(&Workflow{Name: "CI", Path: "ci.yml"}))
+	s.Token = "secret"
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	if status, body := do(t, server, "GET", "/repos/o/r", nil); status != http.StatusUnauthorized || body["message"] != "Bad credentials" {
+		t.Errorf("expected 401 Bad credentials, got %d %v", status, body)
+	}
+
+	s.Token = ""
+	s.RateLimit = 2
+	s.FailNext(http.StatusBadGateway, 1)
+	if status, _ := do(t, server, "GET", "/repos/o/r", nil); status != http.StatusBadGateway {
+		t.Errorf("expected injected 502, got %d", status)
+	}
+
+	for i := 0; i < 2; i++ {
+		if status, _ := do(t, server, "GET", "/repos/o/r", nil); status != http.StatusOK {
+			t.Errorf("request %d: expected 200, got %d", i, status)
+		}
+	}
+	resp, _ := http.Get(server.URL + "/repos/o/r")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("expected 403 with no remaining requests, got %d remaining=%q", resp.StatusCode, resp.Header.Get("X-RateLimit-Remaining"))
+	}
+
+	if got := s.Requests(); got != 5 {
+		t.Errorf("expected 5 requests, got %d", got)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	s := New(testConfig(&Workflow{Name: "CI", Path: "ci.yml"}))
+	s.RateLimit = 10
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	get := func(etag string) *http.Response {
+		req, _ := http.NewRequest("GET", server.URL+"/repos/o/r", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	first := get("")
+	etag := first.Header.Get("ETag")
+	if first.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with an ETag, got %d %q", first.StatusCode, etag)
+	}
+	if resp := get(etag); resp.StatusCode != http.StatusNotModified || resp.Header.Get("X-RateLimit-Used") != "1" {
+		t.Errorf("expected uncharged 304, got %d used=%q", resp.StatusCode, resp.Header.Get("X-RateLimit-Used"))
+	}
+	if resp := get(`"stale"`); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for a stale ETag, got %d", resp.StatusCode)
+	}
+	if got := s.NotModified(); got != 1 {
+		t.Errorf("expected 1 not modified response, got %d", got)
+	}
+}
+
+func TestDuration_UnmarshalJSON(t *testing.T) {
+	var wf Workflow
+	if err := json.Unmarshal([]byte(`{"queue_time": 5, "run_time": "1m30s"}`), &wf); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(wf.QueueTime) != 5*time.Second || time.Duration(wf.RunTime) != 90*time.Second {
+		t.Errorf("unexpected durations: %v %v", time.Duration(wf.QueueTime), time.Duration(wf.RunTime))
+	}
+}
@@ -0,0 +1,660 @@
+package fakegh
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+
+	if len(s.failures) > 0 {
+		failure := &s.failures[0]
+		failure.count--
+		if failure.count <= 0 {
+			s.failures = s.failures[1:]
+		}
+		writeError(w, failure.status, http.StatusText(failure.status))
+		return
+	}
+
+	if !s.checkRateLimit(w) {
+		return
+	}
+
+	if s.Scopes != nil {
+		w.Header().Set("X-OAuth-Scopes", strings.Join(s.Scopes, ", "))
+	}
+
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeError(w, http.StatusUnauthorized, "Bad credentials")
+		return
+	}
+
+	segments, err := splitPath(r.URL.EscapedPath())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(segments) == 1 && segments[0] == "rate_limit" {
+		s.handleRateLimit(w)
+		return
+	}
+	if len(segments) < 3 || segments[0] != "repos" {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	repo, ok := s.repos[repoKey(segments[1], segments[2])]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	if r.Method != http.MethodGet {
+		s.route(w, r, repo, segments[3:])
+		return
+	}
+
+	// Answer GETs with an ETag and honour If-None-Match like GitHub
+	buf := &bufferedResponse{header: w.Header(), status: http.StatusOK}
+	s.route(buf, r, repo, segments[3:])
+	if buf.status == http.StatusOK {
+		sum := sha256.Sum256(buf.body.Bytes())
+		etag := `"` + hex.EncodeToString(sum[:]) + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			s.notModified++
+			s.refundRateLimit(w)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.WriteHeader(buf.status)
+	w.Write(buf.body.Bytes())
+}
+
+// bufferedResponse holds a response until its ETag is known.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request, repo *Repo, rest []string) {
+	get := r.Method == http.MethodGet
+	post := r.Method == http.MethodPost
+
+	switch {
+	case len(rest) == 0 && get:
+		s.handleRepo(w, repo)
+	case len(rest) >= 2 && rest[0] == "branches" && get:
+		s.handleBranch(w, repo, strings.Join(rest[1:], "/"))
+	case len(rest) >= 4 && rest[0] == "git" && rest[1] == "ref" && rest[2] == "tags" && get:
+		s.handleTag(w, repo, strings.Join(rest[3:], "/"))
+	case len(rest) == 2 && rest[0] == "commits" && get:
+		s.handleCommit(w, rest[1])
+	case len(rest) == 3 && rest[0] == "commits" && rest[2] == "status" && get:
+		s.handleCombinedStatus(w, r, repo, rest[1])
+	case len(rest) == 3 && rest[0] == "commits" && rest[2] == "check-runs" && get:
+		s.handleListCheckRuns(w, r, repo, rest[1])
+	case len(rest) == 2 && rest[0] == "statuses" && post:
+		s.handleCreateStatus(w, r, repo, rest[1])
+	case len(rest) == 1 && rest[0] == "check-runs" && post:
+		s.handleCreateCheckRun(w, r, repo)
+	case len(rest) == 2 && rest[0] == "check-runs" && r.Method == http.MethodPatch:
+		s.handleUpdateCheckRun(w, r, repo, rest[1])
+	case len(rest) >= 2 && rest[0] == "contents" && get:
+		s.handleContents(w, r, repo, strings.Join(rest[1:], "/"))
+	case len(rest) < 2 || rest[0] != "actions":
+		writeError(w, http.StatusNotFound, "Not Found")
+	case len(rest) == 2 && rest[1] == "workflows" && get:
+		s.handleListWorkflows(w, repo)
+	case len(rest) == 3 && rest[1] == "workflows" && get:
+		s.handleGetWorkflow(w, repo, rest[2])
+	case len(rest) == 4 && rest[1] == "workflows" && rest[3] == "dispatches" && post:
+		s.handleDispatch(w, r, repo, rest[2])
+	case len(rest) == 4 && rest[1] == "workflows" && rest[3] == "runs" && get:
+		wf := s.findWorkflow(repo, rest[2])
+		if wf == nil {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		s.handleListRuns(w, r, repo, wf)
+	case len(rest) == 2 && rest[1] == "runs" && get:
+		s.handleListRuns(w, r, repo, nil)
+	case len(rest) >= 3 && rest[1] == "runs":
+		runID, err := strconv.ParseInt(rest[2], 10, 64)
+		run := s.findRun(runID)
+		if err != nil || run == nil || run.Owner != repo.Owner || run.Repo != repo.Name {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		s.routeRun(w, r, run, rest[3:])
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (s *Server) routeRun(w http.ResponseWriter, r *http.Request, run *Run, rest []string) {
+	get := r.Method == http.MethodGet
+
+	switch {
+	case len(rest) == 0 && get:
+		writeJSON(w, http.StatusOK, s.runJSON(run))
+	case len(rest) == 1 && rest[0] == "jobs" && get:
+		s.handleJobs(w, r, run)
+	case len(rest) == 1 && rest[0] == "logs" && get:
+		s.handleLogs(w, run)
+	case len(rest) == 1 && rest[0] == "artifacts" && get:
+		s.handleArtifacts(w, run)
+	case len(rest) == 1 && rest[0] == "cancel" && r.Method == http.MethodPost:
+		s.handleCancel(w, run)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (s *Server) checkRateLimit(w http.ResponseWriter) bool {
+	if s.RateLimit <= 0 {
+		return true
+	}
+
+	now := s.Now()
+	if s.windowStart.IsZero() || now.Sub(s.windowStart) >= s.RateLimitWindow {
+		s.windowStart = now
+		s.windowUsed = 0
+	}
+	reset := s.windowStart.Add(s.RateLimitWindow).Unix()
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.RateLimit))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+	w.Header().Set("X-RateLimit-Resource", "core")
+
+	if s.windowUsed >= s.RateLimit {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Used", strconv.Itoa(s.windowUsed))
+		writeError(w, http.StatusForbidden, "API rate limit exceeded")
+		return false
+	}
+
+	s.windowUsed++
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.RateLimit-s.windowUsed))
+	w.Header().Set("X-RateLimit-Used", strconv.Itoa(s.windowUsed))
+	return true
+}
+
+// refundRateLimit gives back the request that was just counted; GitHub does
+// not charge for 304 Not Modified responses.
+func (s *Server) refundRateLimit(w http.ResponseWriter) {
+	if s.RateLimit <= 0 {
+		return
+	}
+	s.windowUsed--
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.RateLimit-s.windowUsed))
+	w.Header().Set("X-RateLimit-Used", strconv.Itoa(s.windowUsed))
+}
+
+func (s *Server) handleRateLimit(w http.ResponseWriter) {
+	limit, remaining := s.RateLimit, s.RateLimit-s.windowUsed
+	if limit <= 0 {
+		limit, remaining = 5000, 5000
+	}
+	core := map[string]interface{}{
+		"limit":     limit,
+		"remaining": remaining,
+		"used":      limit - remaining,
+		"reset":     s.windowStart.Add(s.RateLimitWindow).Unix(),
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"resources": map[string]interface{}{"core": core},
+		"rate":      core,
+	})
+}
+
+func (s *Server) handleRepo(w http.ResponseWriter, repo *Repo) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":           repo.Name,
+		"full_name":      repo.Owner + "/" + repo.Name,
+		"owner":          map[string]interface{}{"login": repo.Owner},
+		"default_branch": repo.DefaultBranch,
+		"private":        repo.Private,
+		"permissions": map[string]bool{
+			"admin": false,
+			"push":  !repo.ReadOnly,
+			"pull":  true,
+		},
+	})
+}
+
+func (s *Server) handleBranch(w http.ResponseWriter, repo *Repo, branch string) {
+	if !contains(repo.Branches, branch) {
+		writeError(w, http.StatusNotFound, "Branch not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":   branch,
+		"commit": map[string]interface{}{"sha": fakeSHA(branch)},
+	})
+}
+
+func (s *Server) handleTag(w http.ResponseWriter, repo *Repo, tag string) {
+	if !contains(repo.Tags, tag) {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ref":    "refs/tags/" + tag,
+		"object": map[string]interface{}{"type": "commit", "sha": fakeSHA(tag)},
+	})
+}
+
+func (s *Server) handleCommit(w http.ResponseWriter, sha string) {
+	if !isSHA(sha) {
+		writeError(w, http.StatusUnprocessableEntity, "No commit found for SHA: "+sha)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sha": sha})
+}
+
+func (s *Server) handleContents(w http.ResponseWriter, r *http.Request, repo *Repo, path string) {
+	if ref := r.URL.Query().Get("ref"); ref != "" && !s.refExists(repo, ref) {
+		writeError(w, http.StatusNotFound, "No commit found for the ref "+ref)
+		return
+	}
+	for _, wf := range repo.Workflows {
+		if wf.Path == path {
+			content := wf.source()
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"type":     "file",
+				"name":     pathBase(path),
+				"path":     path,
+				"size":     len(content),
+				"encoding": "base64",
+				"content":  base64.StdEncoding.EncodeToString([]byte(content)),
+			})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) handleListWorkflows(w http.ResponseWriter, repo *Repo) {
+	workflows := make([]interface{}, 0, len(repo.Workflows))
+	for _, wf := range repo.Workflows {
+		workflows = append(workflows, workflowJSON(repo, wf))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total_count": len(workflows),
+		"workflows":   workflows,
+	})
+}
+
+func (s *Server) handleGetWorkflow(w http.ResponseWriter, repo *Repo, idOrFile string) {
+	wf := s.findWorkflow(repo, idOrFile)
+	if wf == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, workflowJSON(repo, wf))
+}
+
+func (s *Server) handleDispatch(w http.ResponseWriter, r *http.Request, repo *Repo, idOrFile string) {
+	wf := s.findWorkflow(repo, idOrFile)
+	if wf == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var body struct {
+		Ref    string                 `json:"ref"`
+		Inputs map[string]interface{} `json:"inputs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	switch {
+	case repo.ReadOnly:
+		writeError(w, http.StatusForbidden, "Resource not accessible by personal access token")
+		return
+	case wf.NoDispatch:
+		writeError(w, http.StatusUnprocessableEntity, "Workflow does not have 'workflow_dispatch' trigger")
+		return
+	case wf.State != "active":
+		writeError(w, http.StatusUnprocessableEntity, "Cannot trigger a 'workflow_dispatch' on a disabled workflow")
+		return
+	case body.Ref == "":
+		writeError(w, http.StatusUnprocessableEntity, "Invalid request.\n\n\"ref\" wasn't supplied.")
+		return
+	case !s.refExists(repo, body.Ref):
+		writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("No ref found for: %s", body.Ref))
+		return
+	}
+
+	if wf.Inputs != nil {
+		var unexpected []string
+		for _, name := range sortedKeys(body.Inputs) {
+			if name != "fake_conclusion" && !contains(wf.Inputs, name) {
+				unexpected = append(unexpected, name)
+			}
+		}
+		if len(unexpected) > 0 {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Unexpected inputs provided: %q", unexpected))
+			return
+		}
+	}
+
+	conclusion := wf.Conclusion
+	if c, ok := body.Inputs["fake_conclusion"].(string); ok && c != "" {
+		conclusion = c
+	}
+
+	runName := wf.RunName
+	if runName == "" {
+		runName = wf.Name
+	}
+
+	s.nextID++
+	s.runs = append(s.runs, &Run{
+		ID:           s.nextID,
+		Owner:        repo.Owner,
+		Repo:         repo.Name,
+		Workflow:     wf,
+		Ref:          strings.TrimPrefix(body.Ref, "refs/heads/"),
+		Inputs:       body.Inputs,
+		DisplayTitle: renderRunName(runName, body.Inputs),
+		Event:        "workflow_dispatch",
+		CreatedAt:    s.Now().UTC(),
+		Conclusion:   conclusion,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) refExists(repo *Repo, ref string) bool {
+	if strings.HasPrefix(ref, "refs/tags/") {
+		return contains(repo.Tags, strings.TrimPrefix(ref, "refs/tags/"))
+	}
+	ref = strings.TrimPrefix(ref, "refs/heads/")
+	return contains(repo.Branches, ref) || contains(repo.Tags, ref) || isSHA(ref)
+}
+
+func (s *Server) handleListRuns(w http.ResponseWriter, r *http.Request, repo *Repo, wf *Workflow) {
+	query := r.URL.Query()
+	now := s.Now()
+
+	var matched []interface{}
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		run := s.runs[i]
+		if run.Owner != repo.Owner || run.Repo != repo.Name {
+			continue
+		}
+		if wf != nil && run.Workflow != wf {
+			continue
+		}
+		if now.Sub(run.CreatedAt) < time.Duration(run.Workflow.DiscoveryDelay) {
+			continue
+		}
+		if event := query.Get("event"); event != "" && event != run.Event {
+			continue
+		}
+		if branch := query.Get("branch"); branch != "" && branch != run.Ref {
+			continue
+		}
+		if created := query.Get("created"); strings.HasPrefix(created, ">=") {
+			// Only the >= form of GitHub's date range syntax is supported
+			if since, err := time.Parse(time.RFC3339, created[2:]); err == nil && run.CreatedAt.Before(since) {
+				continue
+			}
+		}
+		if status := query.Get("status"); status != "" {
+			runStatus, conclusion := s.status(run)
+			if status != runStatus && status != conclusion {
+				continue
+			}
+		}
+		matched = append(matched, s.runJSON(run))
+	}
+
+	page, perPage := pagination(query)
+	total := len(matched)
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total_count":   total,
+		"workflow_runs": append([]interface{}{}, matched[start:end]...),
+	})
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request, run *Run) {
+	jobs := s.jobsJSON(run)
+	page, perPage := pagination(r.URL.Query())
+	total := len(jobs)
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total_count": total,
+		"jobs":        append([]interface{}{}, jobs[start:end]...),
+	})
+}
+
+func (s *Server) handleLogs(w http.ResponseWriter, run *Run) {
+	status, conclusion := s.status(run)
+	if status != "completed" {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i, job := range run.Workflow.jobSpecs() {
+		f, _ := zw.Create(fmt.Sprintf("%d_%s.txt", i, job.Name))
+		fmt.Fprintf(f, "%s Run %s\n", run.CreatedAt.Format(time.RFC3339), job.Name)
+		fmt.Fprintf(f, "%s Job completed with conclusion %s\n", run.CreatedAt.Format(time.RFC3339), jobConclusion(job, conclusion))
+	}
+	zw.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) handleArtifacts(w http.ResponseWriter, run *Run) {
+	artifacts := []interface{}{}
+	if status, _ := s.status(run); status == "completed" {
+		artifacts = append(artifacts, map[string]interface{}{
+			"id":            run.ID*10 + 1,
+			"name":          "logs",
+			"size_in_bytes": 1024,
+			"expired":       false,
+			"workflow_run":  map[string]interface{}{"id": run.ID},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total_count": len(artifacts),
+		"artifacts":   artifacts,
+	})
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, run *Run) {
+	if status, _ := s.status(run); status == "completed" {
+		writeError(w, http.StatusConflict, "Cannot cancel a workflow run that is completed.")
+		return
+	}
+	run.CancelledAt = s.Now().UTC()
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{})
+}
+
+func (s *Server) runJSON(run *Run) map[string]interface{} {
+	status, conclusion := s.status(run)
+	updatedAt := s.Now().UTC()
+	if status == "completed" {
+		updatedAt = s.completedAt(run)
+	}
+	obj := map[string]interface{}{
+		"id":             run.ID,
+		"name":           run.Workflow.Name,
+		"display_title":  run.DisplayTitle,
+		"status":         status,
+		"conclusion":     nil,
+		"head_branch":    run.Ref,
+		"head_sha":       fakeSHA(run.Ref),
+		"event":          run.Event,
+		"workflow_id":    run.Workflow.ID,
+		"path":           run.Workflow.Path,
+		"run_attempt":    1,
+		"created_at":     run.CreatedAt.Format(time.RFC3339),
+		"updated_at":     updatedAt.Format(time.RFC3339),
+		"run_started_at": run.CreatedAt.Format(time.RFC3339),
+		"html_url":       fmt.Sprintf("https://github.com/%s/%s/actions/runs/%d", run.Owner, run.Repo, run.ID),
+	}
+	if conclusion != "" {
+		obj["conclusion"] = conclusion
+	}
+	return obj
+}
+
+func (s *Server) completedAt(run *Run) time.Time {
+	if !run.CancelledAt.IsZero() {
+		return run.CancelledAt
+	}
+	return run.CreatedAt.Add(time.Duration(run.Workflow.QueueTime) + time.Duration(run.Workflow.RunTime))
+}
+
+func (s *Server) jobsJSON(run *Run) []interface{} {
+	status, conclusion := s.status(run)
+	startedAt := run.CreatedAt.Add(time.Duration(run.Workflow.QueueTime))
+
+	var jobs []interface{}
+	for i, job := range run.Workflow.jobSpecs() {
+		obj := map[string]interface{}{
+			"id":           run.ID*100 + int64(i) + 1,
+			"run_id":       run.ID,
+			"name":         job.Name,
+			"status":       status,
+			"conclusion":   nil,
+			"started_at":   nil,
+			"completed_at": nil,
+		}
+		if status != "queued" {
+			obj["started_at"] = startedAt.UTC().Format(time.RFC3339)
+		}
+		if status == "completed" {
+			obj["conclusion"] = jobConclusion(job, conclusion)
+			obj["completed_at"] = s.completedAt(run).UTC().Format(time.RFC3339)
+		}
+		jobs = append(jobs, obj)
+	}
+	return jobs
+}
+
+func jobConclusion(job JobSpec, runConclusion string) string {
+	if job.Conclusion != "" && runConclusion != "cancelled" {
+		return job.Conclusion
+	}
+	return runConclusion
+}
+
+func workflowJSON(repo *Repo, wf *Workflow) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       wf.ID,
+		"name":     wf.Name,
+		"path":     wf.Path,
+		"state":    wf.State,
+		"html_url": fmt.Sprintf("https://github.com/%s/%s/blob/%s/%s", repo.Owner, repo.Name, repo.DefaultBranch, wf.Path),
+	}
+}
+
+func pagination(query url.Values) (page, perPage int) {
+	page, _ = strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ = strconv.Atoi(query.Get("per_page"))
+	if perPage < 1 {
+		perPage = 30
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	return page, perPage
+}
+
+// splitPath splits an escaped URL path into unescaped segments.
+func splitPath(escaped string) ([]string, error) {
+	var segments []string
+	for _, part := range strings.Split(strings.Trim(escaped, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		segment, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// isSHA reports whether s looks like a full commit SHA.
+func isSHA(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
+
+// fakeSHA returns a stable 40 character hex string for ref.
+func fakeSHA(ref string) string {
+	var sum uint64 = 14695981039346656037
+	for _, b := range []byte(ref) {
+		sum ^= uint64(b)
+		sum *= 1099511628211
+	}
+	return strings.Repeat(fmt.Sprintf("%016x", sum), 3)[:40]
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{
+		"message":           message,
+		"documentation_url": "https://docs.github.com/rest",
+	})
+}
@@ -0,0 +1,82 @@
+package trigwait
+
+import (
+	"net/http"
+	"sync"
+)
+
+// ResponseCache remembers GET responses by URL so they can be revalidated
+// with conditional requests. GitHub answers an unchanged resource with 304
+// Not Modified, which does not count against the rate limit. A cache may be
+// shared by several clients.
+type ResponseCache struct {
+	mu          sync.Mutex
+	entries     map[string]cachedResponse
+	requests    int
+	notModified int
+}
+
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+// NewResponseCache returns an empty cache.
+func NewResponseCache() *ResponseCache {
+	return &ResponseCache{entries: make(map[string]cachedResponse)}
+}
+
+// Stats returns the number of API requests sent and how many of them were
+// answered with 304 Not Modified.
+func (c *ResponseCache) Stats() (requests, notModified int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests, c.notModified
+}
+
+// prepare counts req and adds validators of a cached response for its URL.
+func (c *ResponseCache) prepare(req *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests++
+	if req.Method != http.MethodGet {
+		return
+	}
+	entry, ok := c.entries[req.URL.String()]
+	if !ok {
+		return
+	}
+	if entry.etag != "" {
+		req.Header.Set("If-None-Match", entry.etag)
+	} else if entry.lastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.lastModified)
+	}
+}
+
+// update records a successful response, or returns the cached body when the
+// response is 304 Not Modified. ok is false if nothing was cached.
+func (c *ResponseCache) update(req *http.Request, resp *http.Response, body []byte) (cached []byte, ok bool) {
+	if req.Method != http.MethodGet {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	url := req.URL.String()
+	if resp.StatusCode == http.StatusNotModified {
+		entry, ok := c.entries[url]
+		if ok {
+			c.notModified++
+		}
+		return entry.body, ok
+	}
+	if resp.StatusCode == http.StatusOK {
+		etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			c.entries[url] = cachedResponse{etag: etag, lastModified: lastModified, body: body}
+		}
+	}
+	return nil, false
+}
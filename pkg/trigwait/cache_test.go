@@ -0,0 +1,66 @@
+package trigwait
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseCache_ConditionalRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		validator string
+		value     string
+		condition string
+	}{
+		{"etag", "ETag", `"abc"`, "If-None-Match"},
+		{"last modified", "Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT", "If-Modified-Since"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(tt.validator, tt.value)
+				if r.Header.Get(tt.condition) == tt.value {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Write([]byte(`{"id":1,"status":"in_progress"}`))
+			}))
+			defer server.Close()
+
+			client := New("owner", "repo", "token", WithAPIURL(server.URL))
+			for i := 0; i < 3; i++ {
+				run, err := client.GetRun(context.Background(), 1)
+				if err != nil {
+					t.Fatalf("GetRun %d failed: %v", i, err)
+				}
+				if run.Status != "in_progress" {
+					t.Errorf("GetRun %d: expected cached status in_progress, got %q", i, run.Status)
+				}
+			}
+			if requests, notModified := client.RequestStats(); requests != 3 || notModified != 2 {
+				t.Errorf("expected 3 requests with 2 not modified, got %d and %d", requests, notModified)
+			}
+		})
+	}
+}
+
+func TestResponseCache_OnlyCachesGET(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			t.Errorf("unexpected conditional %s request", r.Method)
+		}
+		w.Header().Set("ETag", `"abc"`)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := New("owner", "repo", "token", WithAPIURL(server.URL))
+	for i := 0; i < 2; i++ {
+		if _, err := client.apiRequest(context.Background(), "POST", "workflows/ci.yml/dispatches", []byte(`{}`)); err != nil {
+			t.Fatalf("apiRequest failed: %v", err)
+		}
+	}
+}
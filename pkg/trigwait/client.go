@@ -0,0 +1,377 @@
+// Package trigwait dispatches GitHub Actions workflows and waits for the
+// resulting runs to complete.
+package trigwait
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WorkflowRun is the subset of the workflow run API object used by trigwait.
+type WorkflowRun struct {
+	ID           int64  `json:"id"`
+	Status       string `json:"status"`
+	Conclusion   string `json:"conclusion"`
+	CreatedAt    string `json:"created_at"`
+	DisplayTitle string `json:"display_title"`
+	RunAttempt   int    `json:"run_attempt"`
+	HTMLURL      string `json:"html_url"`
+}
+
+// WorkflowRunsResponse is the response body of the list workflow runs API.
+type WorkflowRunsResponse struct {
+	WorkflowRuns []WorkflowRun `json:"workflow_runs"`
+}
+
+// Job is the subset of the workflow job API object used by trigwait.
+type Job struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Conclusion  string `json:"conclusion"`
+	StartedAt   string `json:"started_at"`
+	CompletedAt string `json:"completed_at"`
+}
+
+// JobsResponse is the response body of the list jobs for a workflow run API.
+type JobsResponse struct {
+	TotalCount int   `json:"total_count"`
+	Jobs       []Job `json:"jobs"`
+}
+
+// Client talks to the GitHub Actions API of a single repository.
+type Client struct {
+	owner string
+	repo  string
+	token string
+
+	apiURL         string
+	serverURL      string
+	httpClient     *http.Client
+	waitInterval   time.Duration
+	triggerTimeout time.Duration
+	waitTimeout    time.Duration
+
+	logger Logger
+	tracer Tracer
+	cache  *ResponseCache
+
+	statusHook    func(run *WorkflowRun)
+	durationsHook func(queued, running time.Duration)
+	requestHook   func(ctx context.Context, info RequestInfo)
+	retryHook     func(ctx context.Context, err error)
+	slo           SLO
+
+	events           RunEvents
+	fallbackInterval time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithAPIURL sets the base URL of the GitHub REST API.
+func WithAPIURL(url string) Option {
+	return func(c *Client) {
+		c.apiURL = url
+	}
+}
+
+// WithServerURL sets the base URL used to build links to workflow runs.
+func WithServerURL(url string) Option {
+	return func(c *Client) {
+		c.serverURL = url
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for API requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithWaitInterval sets the base interval between status checks.
+func WithWaitInterval(d time.Duration) Option {
+	return func(c *Client) {
+		c.waitInterval = d
+	}
+}
+
+// WithTriggerTimeout sets how long Trigger waits for the dispatched run to appear.
+func WithTriggerTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.triggerTimeout = d
+	}
+}
+
+// WithWaitTimeout limits how long Wait polls before giving up with a
+// KindWaitTimeout error. Zero, the default, means no limit.
+func WithWaitTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.waitTimeout = d
+	}
+}
+
+// WithResponseCache sets the cache used for conditional requests, letting
+// several clients share one. By default each client has its own.
+func WithResponseCache(cache *ResponseCache) Option {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// WithLogger sets the logger progress messages are written to.
+// By default the client logs nothing.
+func WithLogger(logger Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithStatusHook registers fn to be called by Wait every time the polled run
+// changes status, including the final transition to completed.
+func WithStatusHook(fn func(run *WorkflowRun)) Option {
+	return func(c *Client) {
+		c.statusHook = fn
+	}
+}
+
+// WithDurationsHook registers fn to be called when Wait returns with how
+// long the run was queued and how long it ran.
+func WithDurationsHook(fn func(queued, running time.Duration)) Option {
+	return func(c *Client) {
+		c.durationsHook = fn
+	}
+}
+
+// RequestInfo describes a completed API request.
+type RequestInfo struct {
+	Method string
+	// Path is relative to the repository, e.g. actions/runs/123.
+	Path string
+	// StatusCode is 0 when no response was received.
+	StatusCode int
+	Start      time.Time
+	Duration   time.Duration
+	// Err is the error returned to the caller, if any.
+	Err error
+}
+
+// WithRequestHook registers fn to be called after every API request,
+// including ones answered with 304 Not Modified and ones that failed.
+func WithRequestHook(fn func(ctx context.Context, info RequestInfo)) Option {
+	return func(c *Client) {
+		c.requestHook = fn
+	}
+}
+
+// WithRetryHook registers fn to be called each time Trigger or Wait retries
+// after a failed lookup, with the error that caused the retry.
+func WithRetryHook(fn func(ctx context.Context, err error)) Option {
+	return func(c *Client) {
+		c.retryHook = fn
+	}
+}
+
+// WithSLO makes Wait enforce limits on queue and run time.
+func WithSLO(slo SLO) Option {
+	return func(c *Client) {
+		c.slo = slo
+	}
+}
+
+// WithRunEvents makes Wait complete on pushed run events instead of polling.
+// Wait still polls every fallbackInterval in case an event is lost.
+func WithRunEvents(events RunEvents, fallbackInterval time.Duration) Option {
+	return func(c *Client) {
+		c.events = events
+		c.fallbackInterval = fallbackInterval
+	}
+}
+
+// New returns a Client for the owner/repo repository authenticated with token.
+func New(owner, repo, token string, opts ...Option) *Client {
+	c := &Client{
+		owner:          owner,
+		repo:           repo,
+		token:          token,
+		apiURL:         "https://api.github.com",
+		serverURL:      "https://github.com",
+		httpClient:     &http.Client{Timeout: 30 * time.Second},
+		waitInterval:   10 * time.Second,
+		triggerTimeout: 120 * time.Second,
+		logger:         discardLogger{},
+		tracer:         noopTracer{},
+		cache:          NewResponseCache(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// RequestStats returns the number of API requests sent through the client's
+// response cache and how many were answered with 304 Not Modified.
+func (c *Client) RequestStats() (requests, notModified int) {
+	return c.cache.Stats()
+}
+
+// RunURL returns the web URL of the given workflow run.
+func (c *Client) RunURL(runID int64) string {
+	return fmt.Sprintf("%s/%s/%s/actions/runs/%d", c.serverURL, c.owner, c.repo, runID)
+}
+
+// GetRun fetches a single workflow run.
+func (c *Client) GetRun(ctx context.Context, runID int64) (*WorkflowRun, error) {
+	path := fmt.Sprintf("runs/%d", runID)
+	respBody, err := c.apiRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var run WorkflowRun
+	if err := json.Unmarshal(respBody, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &run, nil
+}
+
+// CancelRun requests cancellation of a workflow run.
+func (c *Client) CancelRun(ctx context.Context, runID int64) error {
+	_, err := c.apiRequest(ctx, "POST", fmt.Sprintf("runs/%d/cancel", runID), nil)
+	return err
+}
+
+// ListJobs returns the jobs of the latest attempt of a workflow run.
+func (c *Client) ListJobs(ctx context.Context, runID int64) ([]Job, error) {
+	var jobs []Job
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("runs/%d/jobs?per_page=100&page=%d", runID, page)
+		respBody, err := c.apiRequest(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var response JobsResponse
+		if err := json.Unmarshal(respBody, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		jobs = append(jobs, response.Jobs...)
+		if len(response.Jobs) == 0 || len(jobs) >= response.TotalCount {
+			return jobs, nil
+		}
+	}
+}
+
+func (c *Client) apiRequest(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	respBody, _, err := c.repoRequest(ctx, method, "actions/"+path, body)
+	return respBody, err
+}
+
+// repoURL returns the API URL of path relative to the repository.
+func (c *Client) repoURL(path string) string {
+	url := fmt.Sprintf("%s/repos/%s/%s", c.apiURL, c.owner, c.repo)
+	if path != "" {
+		url += "/" + path
+	}
+	return url
+}
+
+// repoRequest sends a request to path relative to the repository and returns
+// the body and headers of a successful response.
+func (c *Client) repoRequest(ctx context.Context, method, path string, body []byte) ([]byte, http.Header, error) {
+	ctx, span := c.tracer.Start(ctx, method)
+	span.SetAttr("http.request.method", method)
+	span.SetAttr("url.path", path)
+	start := time.Now()
+	respBody, header, status, err := c.send(ctx, method, path, body)
+	if status != 0 {
+		span.SetAttr("http.response.status_code", status)
+	}
+	span.End(err)
+	if c.requestHook != nil {
+		c.requestHook(ctx, RequestInfo{
+			Method:     method,
+			Path:       path,
+			StatusCode: status,
+			Start:      start,
+			Duration:   time.Since(start),
+			Err:        err,
+		})
+	}
+	return respBody, header, err
+}
+
+// send performs a request for repoRequest and also returns the status code
+// of the response, or 0 when there was none.
+func (c *Client) send(ctx context.Context, method, path string, body []byte) ([]byte, http.Header, int, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.repoURL(path), reqBody)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Content-Type", "application/json")
+	c.cache.prepare(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, resp.StatusCode, err
+	}
+
+	// 304 Not Modified means the cached response is still current
+	if cached, ok := c.cache.update(req, resp, respBody); ok {
+		c.logf(LevelDebug, "%s %s: not modified", method, path)
+		return cached, resp.Header, resp.StatusCode, nil
+	}
+
+	// 204 No Content is success for dispatch
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return respBody, resp.Header, resp.StatusCode, nil
+	}
+
+	return nil, resp.Header, resp.StatusCode, classifyAPIError(&APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(respBody),
+	})
+}
+
+// sleep waits for d or until ctx is done, whichever comes first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
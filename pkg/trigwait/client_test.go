@@ -0,0 +1,182 @@
+package trigwait
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIRequest_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Error("missing or incorrect Authorization header")
+		}
+		if r.Header.Get("Accept") != "application/vnd.github.v3+json" {
+			t.Error("missing or incorrect Accept header")
+		}
+		if r.URL.Path != "/repos/owner/repo/actions/test/path" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}))
+	defer server.Close()
+
+	client := New("owner", "repo", "test-token", WithAPIURL(server.URL))
+
+	resp, err := client.apiRequest(context.Background(), "GET", "test/path", nil)
+	if err != nil {
+		t.Fatalf("apiRequest failed: %v", err)
+	}
+
+	var result map[string]string
+	json.Unmarshal(resp, &result)
+	if result["status"] != "ok" {
+		t.Errorf("unexpected response: %s", string(resp))
+	}
+}
+
+func TestAPIRequest_Failure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"message": "Validation failed"}`))
+	}))
+	defer server.Close()
+
+	client := New("owner", "repo", "test-token", WithAPIURL(server.URL))
+
+	_, err := client.apiRequest(context.Background(), "POST", "test/path", []byte(`{}`))
+	if err == nil {
+		t.Fatal("expected error for 422 response, got nil")
+	}
+	if !strings.Contains(err.Error(), "422") {
+		t.Errorf("expected error containing '422', got '%s'", err.Error())
+	}
+}
+
+func TestAPIRequest_RequestHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	var infos []RequestInfo
+	client := New("owner", "repo", "test-token",
+		WithAPIURL(server.URL),
+		WithRequestHook(func(_ context.Context, info RequestInfo) { infos = append(infos, info) }),
+	)
+
+	client.apiRequest(context.Background(), "GET", "runs/1", nil)
+	client.apiRequest(context.Background(), "POST", "runs/1/fail", []byte(`{}`))
+
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(infos))
+	}
+	if infos[0].Method != "GET" || infos[0].Path != "actions/runs/1" || infos[0].StatusCode != 200 || infos[0].Err != nil {
+		t.Errorf("unexpected first request %+v", infos[0])
+	}
+	if infos[1].StatusCode != 500 || infos[1].Err == nil || infos[1].Duration <= 0 {
+		t.Errorf("unexpected second request %+v", infos[1])
+	}
+}
+
+func TestWait_RetryHook(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(WorkflowRun{ID: 1, Status: "completed", Conclusion: "success"})
+	}))
+	defer server.Close()
+
+	var retries []error
+	client := New("owner", "repo", "token",
+		WithAPIURL(server.URL),
+		WithWaitInterval(10*time.Millisecond),
+		WithRetryHook(func(_ context.Context, err error) { retries = append(retries, err) }),
+	)
+
+	if _, err := client.Wait(context.Background(), 1); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if len(retries) != 2 || retries[0] == nil {
+		t.Errorf("expected 2 retries, got %v", retries)
+	}
+}
+
+func TestNew_Defaults(t *testing.T) {
+	client := New("owner", "repo", "token")
+
+	if client.apiURL != "https://api.github.com" {
+		t.Errorf("expected default API URL, got %s", client.apiURL)
+	}
+	if client.waitInterval != 10*time.Second {
+		t.Errorf("expected wait interval 10s, got %v", client.waitInterval)
+	}
+	if client.triggerTimeout != 120*time.Second {
+		t.Errorf("expected trigger timeout 120s, got %v", client.triggerTimeout)
+	}
+	if got := client.RunURL(42); got != "https://github.com/owner/repo/actions/runs/42" {
+		t.Errorf("unexpected run URL: %s", got)
+	}
+}
+
+func TestWait_ContextCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(WorkflowRun{ID: 1, Status: "in_progress"})
+	}))
+	defer server.Close()
+
+	client := New("owner", "repo", "token",
+		WithAPIURL(server.URL),
+		WithWaitInterval(10*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := client.Wait(ctx, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestTrigger_ContextCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "dispatches") {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(WorkflowRunsResponse{})
+	}))
+	defer server.Close()
+
+	client := New("owner", "repo", "token",
+		WithAPIURL(server.URL),
+		WithWaitInterval(time.Hour),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := client.Trigger(ctx, Dispatch{Workflow: "test.yml", Ref: "main"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package trigwait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ConcurrencyPolicy selects what to do when a run of the workflow is already
+// active on the ref about to be dispatched.
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyAllow dispatches regardless of active runs.
+	ConcurrencyAllow ConcurrencyPolicy = "allow"
+	// ConcurrencyWait waits on the active run instead of dispatching.
+	ConcurrencyWait ConcurrencyPolicy = "wait"
+	// ConcurrencyCancel cancels the active runs, then dispatches.
+	ConcurrencyCancel ConcurrencyPolicy = "cancel"
+	// ConcurrencySkip neither dispatches nor waits.
+	ConcurrencySkip ConcurrencyPolicy = "skip"
+)
+
+// ParseConcurrencyPolicy parses a concurrency policy name.
+func ParseConcurrencyPolicy(s string) (ConcurrencyPolicy, error) {
+	switch policy := ConcurrencyPolicy(s); policy {
+	case ConcurrencyAllow, ConcurrencyWait, ConcurrencyCancel, ConcurrencySkip:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid concurrency policy %q (expected allow, wait, cancel or skip)", s)
+}
+
+// ActiveRuns returns the queued and in-progress workflow_dispatch runs of
+// d.Workflow on d.Ref, newest first. When titleMatch is not empty only runs
+// whose display title contains it are returned.
+func (c *Client) ActiveRuns(ctx context.Context, d Dispatch, titleMatch string) ([]WorkflowRun, error) {
+	path := fmt.Sprintf("workflows/%s/runs?event=workflow_dispatch&branch=%s&per_page=100", d.Workflow, url.QueryEscape(d.Ref))
+	respBody, err := c.apiRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response WorkflowRunsResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	var active []WorkflowRun
+	for _, run := range response.WorkflowRuns {
+		if run.Status == "completed" {
+			continue
+		}
+		if titleMatch != "" && !strings.Contains(run.DisplayTitle, titleMatch) {
+			continue
+		}
+		active = append(active, run)
+	}
+	return active, nil
+}
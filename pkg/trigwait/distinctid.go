@@ -0,0 +1,137 @@
+package trigwait
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// DefaultIDAlphabet is Crockford's base32 alphabet: digits and upper-case
+// letters without I, L, O and U, which are easily misread in run names.
+const DefaultIDAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// DefaultIDLength gives 60 random bits with DefaultIDAlphabet.
+const DefaultIDLength = 12
+
+// IDFormat describes how NewDistinctID generates distinct IDs. The zero
+// value generates DefaultIDLength characters of DefaultIDAlphabet.
+type IDFormat struct {
+	// Length is the number of random characters.
+	Length int
+	// Alphabet holds the ASCII characters the ID is drawn from.
+	Alphabet string
+	// UUID generates a random (version 4) UUID instead, ignoring Length and
+	// Alphabet.
+	UUID bool
+	// Prefix is prepended to the random part with a "-", for example the ID
+	// of the upstream run.
+	Prefix string
+}
+
+// Validate reports whether f can generate IDs.
+func (f IDFormat) Validate() error {
+	if f.UUID {
+		return nil
+	}
+	if f.Length < 0 || f.Length > 64 {
+		return fmt.Errorf("invalid distinct ID length %d: must be between 1 and 64", f.Length)
+	}
+	if f.Alphabet == "" {
+		return nil
+	}
+	if len(f.Alphabet) < 2 || len(f.Alphabet) > 256 {
+		return fmt.Errorf("invalid distinct ID alphabet %q: must have between 2 and 256 characters", f.Alphabet)
+	}
+	for i := 0; i < len(f.Alphabet); i++ {
+		c := f.Alphabet[i]
+		if c <= ' ' || c > '~' {
+			return fmt.Errorf("invalid distinct ID alphabet %q: only printable ASCII characters are allowed", f.Alphabet)
+		}
+		if strings.IndexByte(f.Alphabet[i+1:], c) >= 0 {
+			return fmt.Errorf("invalid distinct ID alphabet %q: %q appears more than once", f.Alphabet, c)
+		}
+	}
+	return nil
+}
+
+// NewDistinctID generates a random ID in format f from crypto/rand. Every
+// character is drawn uniformly from the alphabet.
+func NewDistinctID(f IDFormat) (string, error) {
+	if err := f.Validate(); err != nil {
+		return "", err
+	}
+	var id string
+	var err error
+	if f.UUID {
+		id, err = newUUID()
+	} else {
+		id, err = randomString(f.Length, f.Alphabet)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to generate distinct ID: %w", err)
+	}
+	if f.Prefix != "" {
+		id = f.Prefix + "-" + id
+	}
+	return id, nil
+}
+
+func randomString(length int, alphabet string) (string, error) {
+	if length == 0 {
+		length = DefaultIDLength
+	}
+	if alphabet == "" {
+		alphabet = DefaultIDAlphabet
+	}
+	// Bytes at or above limit are rejected so that no character is more
+	// likely than another when the alphabet size does not divide 256.
+	limit := 256 - 256%len(alphabet)
+	id := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(id) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(id) < length {
+				id = append(id, alphabet[int(b)%len(alphabet)])
+			}
+		}
+	}
+	return string(id), nil
+}
+
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// ContainsDistinctID reports whether id appears in title as a whole word:
+// the characters around it, if any, must not be letters or digits. This
+// keeps "ABC" from matching a run named "XABCY".
+func ContainsDistinctID(title, id string) bool {
+	if id == "" {
+		return false
+	}
+	for offset := 0; ; {
+		i := strings.Index(title[offset:], id)
+		if i < 0 {
+			return false
+		}
+		start := offset + i
+		end := start + len(id)
+		if (start == 0 || !isWordChar(title[start-1])) && (end == len(title) || !isWordChar(title[end])) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,89 @@
+package trigwait
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNewDistinctID(t *testing.T) {
+	tests := []struct {
+		name   string
+		format IDFormat
+		want   *regexp.Regexp
+	}{
+		{name: "default", want: regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{12}$`)},
+		{name: "length and alphabet", format: IDFormat{Length: 20, Alphabet: "abc"}, want: regexp.MustCompile(`^[abc]{20}$`)},
+		{name: "prefix", format: IDFormat{Length: 4, Prefix: "4242"}, want: regexp.MustCompile(`^4242-[0-9A-Z]{4}$`)},
+		{name: "uuid", format: IDFormat{UUID: true}, want: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := map[string]bool{}
+			for i := 0; i < 100; i++ {
+				id, err := NewDistinctID(tt.format)
+				if err != nil {
+					t.Fatalf("NewDistinctID failed: %v", err)
+				}
+				if !tt.want.MatchString(id) {
+					t.Fatalf("unexpected ID %q", id)
+				}
+				seen[id] = true
+			}
+			if len(seen) < 90 {
+				t.Errorf("expected mostly unique IDs, got %d of 100", len(seen))
+			}
+		})
+	}
+}
+
+func TestNewDistinctID_Uniform(t *testing.T) {
+	// 3 does not divide 256; without rejection "a" would be drawn more often
+	id, err := randomString(30000, "abc")
+	if err != nil {
+		t.Fatalf("randomString failed: %v", err)
+	}
+	for _, c := range "abc" {
+		if n := strings.Count(id, string(c)); n < 9500 || n > 10500 {
+			t.Errorf("expected about 10000 %q, got %d", c, n)
+		}
+	}
+}
+
+func TestIDFormat_Validate(t *testing.T) {
+	invalid := []IDFormat{
+		{Length: -1},
+		{Length: 65},
+		{Alphabet: "a"},
+		{Alphabet: "abca"},
+		{Alphabet: "ab c"},
+		{Alphabet: "abç"},
+	}
+	for _, f := range invalid {
+		if _, err := NewDistinctID(f); err == nil {
+			t.Errorf("expected error for %+v", f)
+		}
+	}
+}
+
+func TestContainsDistinctID(t *testing.T) {
+	tests := []struct {
+		title string
+		want  bool
+	}{
+		{title: "ABC", want: true},
+		{title: "Deploy [ABC]", want: true},
+		{title: "Deploy ABC", want: true},
+		{title: "Deploy-ABC-2", want: true},
+		{title: "Deploy XABCY", want: false},
+		{title: "Deploy ABCD", want: false},
+		{title: "Deploy XABC", want: false},
+		{title: "Deploy XABC ABC", want: true},
+		{title: "Deploy", want: false},
+	}
+	for _, tt := range tests {
+		if got := ContainsDistinctID(tt.title, "ABC"); got != tt.want {
+			t.Errorf("ContainsDistinctID(%q, ABC) = %v, want %v", tt.title, got, tt.want)
+		}
+	}
+}
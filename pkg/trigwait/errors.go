@@ -0,0 +1,108 @@
+package trigwait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrorKind classifies why an operation failed.
+type ErrorKind string
+
+const (
+	KindUnknown             ErrorKind = "unknown"
+	KindConfig              ErrorKind = "config"
+	KindAuth                ErrorKind = "auth"
+	KindNotFound            ErrorKind = "not-found"
+	KindDispatchRejected    ErrorKind = "dispatch-rejected"
+	KindDiscoveryTimeout    ErrorKind = "discovery-timeout"
+	KindWaitTimeout         ErrorKind = "wait-timeout"
+	KindDownstreamFailed    ErrorKind = "downstream-failed"
+	KindDownstreamCancelled ErrorKind = "downstream-cancelled"
+	KindSLOBreached         ErrorKind = "slo-breached"
+)
+
+// Error is an error with a kind. Use KindOf to classify any error.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errorf returns an *Error of the given kind with a formatted message.
+// The %w verb is supported.
+func Errorf(kind ErrorKind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// KindOf returns the kind of the outermost *Error in err's chain, or
+// KindUnknown if there is none. It returns "" for a nil error.
+func KindOf(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindUnknown
+}
+
+// APIError is returned for non-2xx responses from the GitHub API.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed: %s: %s", e.Status, e.Body)
+}
+
+// kindForStatus maps authentication and lookup failures to their kinds.
+func kindForStatus(statusCode int) ErrorKind {
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return KindAuth
+	case http.StatusNotFound:
+		return KindNotFound
+	}
+	return KindUnknown
+}
+
+// classifyAPIError wraps an API error with the kind implied by its status
+// code. Other errors are returned unchanged.
+func classifyAPIError(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if kind := kindForStatus(apiErr.StatusCode); kind != KindUnknown {
+			return &Error{Kind: kind, Err: err}
+		}
+	}
+	return err
+}
+
+// RunError returns an error describing a completed run that did not succeed,
+// or nil if it succeeded.
+func RunError(run *WorkflowRun) error {
+	switch run.Conclusion {
+	case "success":
+		return nil
+	case "cancelled":
+		return Errorf(KindDownstreamCancelled, "workflow failed with conclusion: %s", run.Conclusion)
+	}
+	return Errorf(KindDownstreamFailed, "workflow failed with conclusion: %s", run.Conclusion)
+}
+
+// isTimeout reports whether err is due to a context deadline.
+func isTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
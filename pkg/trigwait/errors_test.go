@@ -0,0 +1,113 @@
+package trigwait
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestKindOf(t *testing.T) {
+	if kind := KindOf(nil); kind != "" {
+		t.Errorf("expected empty kind for nil, got %q", kind)
+	}
+	if kind := KindOf(errors.New("boom")); kind != KindUnknown {
+		t.Errorf("expected unknown kind, got %q", kind)
+	}
+	wrapped := fmt.Errorf("context: %w", Errorf(KindAuth, "bad token"))
+	if kind := KindOf(wrapped); kind != KindAuth {
+		t.Errorf("expected auth kind through wrapping, got %q", kind)
+	}
+}
+
+func TestDispatch_ErrorKinds(t *testing.T) {
+	tests := []struct {
+		status int
+		kind   ErrorKind
+	}{
+		{http.StatusUnauthorized, KindAuth},
+		{http.StatusForbidden, KindAuth},
+		{http.StatusNotFound, KindNotFound},
+		{http.StatusUnprocessableEntity, KindDispatchRejected},
+		{http.StatusInternalServerError, KindUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"message": "nope"}`))
+			}))
+			defer server.Close()
+
+			client := New("owner", "repo", "token", WithAPIURL(server.URL))
+			err := client.Dispatch(context.Background(), Dispatch{Workflow: "test.yml", Ref: "main"})
+			if kind := KindOf(err); kind != tt.kind {
+				t.Errorf("expected kind %q, got %q (%v)", tt.kind, kind, err)
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Errorf("expected APIError with status %d in chain, got %v", tt.status, err)
+			}
+		})
+	}
+}
+
+func TestTrigger_DiscoveryTimeoutKind(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(WorkflowRunsResponse{})
+	}))
+	defer server.Close()
+
+	client := New("owner", "repo", "token",
+		WithAPIURL(server.URL),
+		WithWaitInterval(20*time.Millisecond),
+		WithTriggerTimeout(50*time.Millisecond),
+	)
+
+	_, err := client.Trigger(context.Background(), Dispatch{Workflow: "test.yml", Ref: "main"})
+	if kind := KindOf(err); kind != KindDiscoveryTimeout {
+		t.Errorf("expected discovery-timeout, got %q (%v)", kind, err)
+	}
+}
+
+func TestWait_WaitTimeoutKind(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(WorkflowRun{ID: 1, Status: "in_progress"})
+	}))
+	defer server.Close()
+
+	client := New("owner", "repo", "token",
+		WithAPIURL(server.URL),
+		WithWaitInterval(10*time.Millisecond),
+		WithWaitTimeout(50*time.Millisecond),
+	)
+
+	_, err := client.Wait(context.Background(), 1)
+	if kind := KindOf(err); kind != KindWaitTimeout {
+		t.Errorf("expected wait-timeout, got %q (%v)", kind, err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded in chain, got %v", err)
+	}
+}
+
+func TestRunError(t *testing.T) {
+	if err := RunError(&WorkflowRun{Conclusion: "success"}); err != nil {
+		t.Errorf("expected nil for success, got %v", err)
+	}
+	if kind := KindOf(RunError(&WorkflowRun{Conclusion: "cancelled"})); kind != KindDownstreamCancelled {
+		t.Errorf("expected downstream-cancelled, got %q", kind)
+	}
+	if kind := KindOf(RunError(&WorkflowRun{Conclusion: "timed_out"})); kind != KindDownstreamFailed {
+		t.Errorf("expected downstream-failed, got %q", kind)
+	}
+}
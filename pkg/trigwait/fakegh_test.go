@@ -0,0 +1,104 @@
+package trigwait_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func TestTriggerAndWait_FakeGitHub(t *testing.T) {
+	fake := fakegh.New(&fakegh.Config{Repos: []*fakegh.Repo{{
+		Owner: "owner",
+		Name:  "repo",
+		Workflows: []*fakegh.Workflow{{
+			Name:           "Deploy",
+			Path:           "deploy.yml",
+			RunName:        "Deploy ${{ inputs.distinct_id }}",
+			Inputs:         []string{"distinct_id", "env"},
+			QueueTime:      fakegh.Duration(20 * time.Millisecond),
+			RunTime:        fakegh.Duration(50 * time.Millisecond),
+			DiscoveryDelay: fakegh.Duration(10 * time.Millisecond),
+		}},
+	}}})
+	fake.Token = "token"
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := trigwait.New("owner", "repo", "token",
+		trigwait.WithAPIURL(server.URL),
+		trigwait.WithWaitInterval(10*time.Millisecond),
+		trigwait.WithTriggerTimeout(5*time.Second),
+	)
+
+	ctx := context.Background()
+	runID, err := client.Trigger(ctx, trigwait.Dispatch{
+		Workflow:   "deploy.yml",
+		Ref:        "main",
+		Inputs:     map[string]interface{}{"distinct_id": "xyz789", "env": "prod"},
+		DistinctID: "xyz789",
+	})
+	if err != nil {
+		t.Fatalf("Trigger failed: %v", err)
+	}
+
+	run, err := client.Wait(ctx, runID)
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if run.Status != "completed" || run.Conclusion != "success" {
+		t.Errorf("expected completed/success, got %s/%s", run.Status, run.Conclusion)
+	}
+
+	jobs, err := client.ListJobs(ctx, runID)
+	if err != nil || len(jobs) != 1 {
+		t.Errorf("expected 1 job, got %d (%v)", len(jobs), err)
+	}
+}
+
+func TestDispatch_FakeGitHubRejectsUnknownInput(t *testing.T) {
+	fake := fakegh.New(&fakegh.Config{Repos: []*fakegh.Repo{{
+		Owner:     "owner",
+		Name:      "repo",
+		Workflows: []*fakegh.Workflow{{Name: "CI", Path: "ci.yml", Inputs: []string{}}},
+	}}})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := trigwait.New("owner", "repo", "token", trigwait.WithAPIURL(server.URL))
+	err := client.Dispatch(context.Background(), trigwait.Dispatch{
+		Workflow: "ci.yml",
+		Ref:      "main",
+		Inputs:   map[string]interface{}{"unknown": "x"},
+	})
+	if kind := trigwait.KindOf(err); kind != trigwait.KindDispatchRejected {
+		t.Errorf("expected %s, got %s (%v)", trigwait.KindDispatchRejected, kind, err)
+	}
+}
+
+func TestGetCombinedStatus_FakeGitHubPaginates(t *testing.T) {
+	fake := fakegh.New(&fakegh.Config{Repos: []*fakegh.Repo{{Owner: "owner", Name: "repo"}}})
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := trigwait.New("owner", "repo", "token", trigwait.WithAPIURL(server.URL))
+	for i := 0; i < 150; i++ {
+		status := trigwait.CommitStatus{State: "pending", Context: fmt.Sprintf("ci/%d", i)}
+		if err := client.CreateCommitStatus(ctx, "abc1234def", status); err != nil {
+			t.Fatalf("CreateCommitStatus failed: %v", err)
+		}
+	}
+
+	combined, err := client.GetCombinedStatus(ctx, "abc1234def")
+	if err != nil {
+		t.Fatalf("GetCombinedStatus failed: %v", err)
+	}
+	if len(combined.Statuses) != 150 || combined.SHA != "abc1234def" {
+		t.Errorf("expected 150 statuses on abc1234def, got %d on %s", len(combined.Statuses), combined.SHA)
+	}
+}
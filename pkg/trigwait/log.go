@@ -0,0 +1,180 @@
+package trigwait
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Level is the severity of a log message.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// ParseLevel parses debug, info, warn (or warning) and error.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", s)
+}
+
+// Format selects how a TextLogger renders messages.
+type Format int
+
+const (
+	// FormatPretty prints messages as written, emoji included.
+	FormatPretty Format = iota
+	// FormatPlain strips emoji and prefixes each line with its level.
+	FormatPlain
+	// FormatJSON prints one JSON object per line.
+	FormatJSON
+)
+
+// ParseFormat parses pretty, plain and json.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "pretty":
+		return FormatPretty, nil
+	case "plain":
+		return FormatPlain, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return FormatPretty, fmt.Errorf("invalid log format %q (expected pretty, plain or json)", s)
+}
+
+// Logger receives progress messages from a Client. Messages are written in
+// the pretty style; implementations may strip decoration as they see fit.
+type Logger interface {
+	Log(level Level, msg string)
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Log(Level, string) {}
+
+// TextLogger is the Logger used by the workflow-trigwait binary.
+type TextLogger struct {
+	w        io.Writer
+	format   Format
+	minLevel Level
+	// actions emits GitHub Actions workflow commands (::warning:: etc.)
+	// for non-info messages.
+	actions bool
+
+	mu sync.Mutex
+}
+
+// NewTextLogger returns a logger writing messages at or above minLevel to w.
+// When actions is true, warnings, errors and debug messages are written as
+// GitHub Actions workflow commands in the pretty and plain formats. Debug
+// messages are then always written, since the runner hides them unless step
+// debug logging is enabled.
+func NewTextLogger(w io.Writer, format Format, minLevel Level, actions bool) *TextLogger {
+	return &TextLogger{w: w, format: format, minLevel: minLevel, actions: actions}
+}
+
+func (l *TextLogger) Log(level Level, msg string) {
+	actionsDebug := l.actions && level == LevelDebug && l.format != FormatJSON
+	if level < l.minLevel && !actionsDebug {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.format == FormatJSON {
+		line, _ := json.Marshal(struct {
+			Time  string `json:"time"`
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}{time.Now().UTC().Format(time.RFC3339Nano), level.String(), plainText(msg)})
+		fmt.Fprintf(l.w, "%s\n", line)
+		return
+	}
+
+	if l.format == FormatPlain {
+		msg = plainText(msg)
+		if msg == "" {
+			return
+		}
+	}
+
+	if l.actions {
+		switch level {
+		case LevelDebug:
+			fmt.Fprintf(l.w, "::debug::%s\n", escapeCommand(strings.TrimSpace(msg)))
+			return
+		case LevelWarn:
+			fmt.Fprintf(l.w, "::warning::%s\n", escapeCommand(strings.TrimSpace(msg)))
+			return
+		case LevelError:
+			fmt.Fprintf(l.w, "::error::%s\n", escapeCommand(strings.TrimSpace(msg)))
+			return
+		}
+	}
+
+	if l.format == FormatPlain {
+		fmt.Fprintf(l.w, "%-5s %s\n", strings.ToUpper(level.String()), msg)
+		return
+	}
+	fmt.Fprintln(l.w, msg)
+}
+
+// plainText removes emoji and decorative symbols and surrounding whitespace.
+func plainText(msg string) string {
+	var b strings.Builder
+	for _, r := range msg {
+		switch {
+		case r == '→':
+			b.WriteString("->")
+		case r == '\uFE0F' || r == '\u200D':
+			// Variation selector and zero-width joiner used by emoji
+		case unicode.Is(unicode.So, r) || (unicode.Is(unicode.Sk, r) && r > unicode.MaxASCII):
+			// Emoji, check marks and box drawing characters
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// escapeCommand escapes a workflow command message.
+func escapeCommand(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func (c *Client) logf(level Level, format string, args ...interface{}) {
+	c.logger.Log(level, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,92 @@
+package trigwait
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTextLogger_Pretty(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewTextLogger(&buf, FormatPretty, LevelInfo, false)
+
+	logger.Log(LevelDebug, "hidden")
+	logger.Log(LevelInfo, "🚀 Triggering o/r → deploy.yml @ main")
+	logger.Log(LevelWarn, "⚠ Error checking runs")
+
+	expected := "🚀 Triggering o/r → deploy.yml @ main\n⚠ Error checking runs\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestTextLogger_Plain(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewTextLogger(&buf, FormatPlain, LevelDebug, false)
+
+	logger.Log(LevelInfo, "━━━━━━━━━━")
+	logger.Log(LevelInfo, "🚀 Triggering o/r → deploy.yml @ main")
+	logger.Log(LevelInfo, "   ▶️ Status: running (elapsed: 5s)")
+	logger.Log(LevelDebug, "Run 1: status=queued")
+
+	expected := "INFO  Triggering o/r -> deploy.yml @ main\n" +
+		"INFO  Status: running (elapsed: 5s)\n" +
+		"DEBUG Run 1: status=queued\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestTextLogger_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewTextLogger(&buf, FormatJSON, LevelInfo, true)
+
+	logger.Log(LevelWarn, "⚠ Error fetching status (retrying...)")
+	logger.Log(LevelDebug, "hidden even in Actions")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %d: %q", len(lines), buf.String())
+	}
+	var entry map[string]string
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("invalid JSON line: %v", err)
+	}
+	if entry["level"] != "warn" || entry["msg"] != "Error fetching status (retrying...)" || entry["time"] == "" {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+}
+
+func TestTextLogger_ActionsCommands(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewTextLogger(&buf, FormatPlain, LevelInfo, true)
+
+	logger.Log(LevelDebug, "polled 50%")
+	logger.Log(LevelInfo, "✓ Triggered run #1")
+	logger.Log(LevelWarn, "⚠ slow")
+	logger.Log(LevelError, "❌ Error: boom\nsecond line")
+
+	expected := "::debug::polled 50%25\n" +
+		"INFO  Triggered run #1\n" +
+		"::warning::slow\n" +
+		"::error::Error: boom%0Asecond line\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestParseLevelAndFormat(t *testing.T) {
+	if level, err := ParseLevel("WARNING"); err != nil || level != LevelWarn {
+		t.Errorf("ParseLevel(WARNING) = %v, %v", level, err)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+	if format, err := ParseFormat("json"); err != nil || format != FormatJSON {
+		t.Errorf("ParseFormat(json) = %v, %v", format, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -0,0 +1,119 @@
+package trigwait
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Policy decides whether a completed run counts as a success.
+// The zero Policy behaves like DefaultPolicy.
+type Policy struct {
+	// AcceptedConclusions are the run conclusions treated as success.
+	// Empty means those of DefaultPolicy.
+	AcceptedConclusions []string
+	// NonBlockingJobs are job names, or path.Match patterns, whose failure
+	// does not fail the run. A run that failed only because of non-blocking
+	// jobs is accepted.
+	NonBlockingJobs []string
+}
+
+// DefaultPolicy accepts success, and skipped and neutral runs, which did
+// not fail.
+func DefaultPolicy() Policy {
+	return Policy{AcceptedConclusions: []string{"success", "skipped", "neutral"}}
+}
+
+// Accepts reports whether conclusion is in AcceptedConclusions.
+func (p Policy) Accepts(conclusion string) bool {
+	accepted := p.AcceptedConclusions
+	if len(accepted) == 0 {
+		accepted = DefaultPolicy().AcceptedConclusions
+	}
+	for _, c := range accepted {
+		if strings.EqualFold(c, conclusion) {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsJobs reports whether Evaluate needs the run's jobs to judge it.
+func (p Policy) NeedsJobs(run *WorkflowRun) bool {
+	return !p.Accepts(run.Conclusion) && len(p.NonBlockingJobs) > 0
+}
+
+// Evaluate returns nil if the completed run is acceptable, or an error of
+// kind KindDownstreamFailed or KindDownstreamCancelled. jobs is only
+// consulted when NonBlockingJobs is set; pass nil otherwise.
+func (p Policy) Evaluate(run *WorkflowRun, jobs []Job) error {
+	if p.Accepts(run.Conclusion) {
+		return nil
+	}
+	if run.Conclusion == "cancelled" || len(p.NonBlockingJobs) == 0 || len(jobs) == 0 {
+		return RunError(run)
+	}
+
+	var blocking []string
+	for _, job := range jobs {
+		if !failedJob(job) || p.isNonBlocking(job.Name) {
+			continue
+		}
+		blocking = append(blocking, job.Name)
+	}
+	if len(blocking) == 0 {
+		return nil
+	}
+
+	kind := KindOf(RunError(run))
+	return Errorf(kind, "workflow failed with conclusion: %s (blocking jobs: %s)", run.Conclusion, strings.Join(blocking, ", "))
+}
+
+// failingJobConclusions are the job conclusions that fail a run. Jobs are
+// judged by these rather than by AcceptedConclusions, which applies to the
+// run: a skipped job does not fail a run even when skipped runs are not
+// accepted.
+var failingJobConclusions = []string{"failure", "timed_out", "cancelled", "startup_failure", "action_required"}
+
+func failedJob(job Job) bool {
+	for _, c := range failingJobConclusions {
+		if job.Conclusion == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Policy) isNonBlocking(jobName string) bool {
+	for _, pattern := range p.NonBlockingJobs {
+		if pattern == jobName {
+			return true
+		}
+		if matched, _ := path.Match(pattern, jobName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// validConclusions are the conclusions GitHub reports for completed runs.
+var validConclusions = []string{
+	"success", "failure", "neutral", "cancelled", "skipped", "timed_out", "action_required", "stale", "startup_failure",
+}
+
+// ValidateConclusions returns an error naming the first unknown conclusion.
+func ValidateConclusions(conclusions []string) error {
+	for _, c := range conclusions {
+		known := false
+		for _, v := range validConclusions {
+			if strings.EqualFold(c, v) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown conclusion %q (expected one of %s)", c, strings.Join(validConclusions, ", "))
+		}
+	}
+	return nil
+}
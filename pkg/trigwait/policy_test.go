@@ -0,0 +1,66 @@
+package trigwait
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicy_Evaluate(t *testing.T) {
+	jobs := []Job{
+		{Name: "build", Conclusion: "success"},
+		{Name: "lint", Conclusion: "failure"},
+		{Name: "test (ubuntu)", Conclusion: "failure"},
+		{Name: "deploy", Conclusion: "skipped"},
+	}
+
+	tests := []struct {
+		name       string
+		policy     Policy
+		conclusion string
+		jobs       []Job
+		kind       ErrorKind
+	}{
+		{"success", Policy{}, "success", nil, ""},
+		{"skipped accepted by default", Policy{}, "skipped", nil, ""},
+		{"neutral accepted by default", DefaultPolicy(), "neutral", nil, ""},
+		{"failure propagated", DefaultPolicy(), "failure", nil, KindDownstreamFailed},
+		{"cancelled propagated", DefaultPolicy(), "cancelled", nil, KindDownstreamCancelled},
+		{"cancelled accepted", Policy{AcceptedConclusions: []string{"success", "cancelled"}}, "cancelled", nil, ""},
+		{"skipped not in explicit list", Policy{AcceptedConclusions: []string{"success"}}, "skipped", nil, KindDownstreamFailed},
+		{"all failed jobs non-blocking", Policy{NonBlockingJobs: []string{"lint", "test *"}}, "failure", jobs, ""},
+		{"blocking job remains", Policy{NonBlockingJobs: []string{"lint"}}, "failure", jobs, KindDownstreamFailed},
+		{"skipped jobs do not block", Policy{AcceptedConclusions: []string{"success"}, NonBlockingJobs: []string{"lint", "test *"}}, "failure", jobs, ""},
+		{"non-blocking without jobs", Policy{NonBlockingJobs: []string{"lint", "test *"}}, "failure", nil, KindDownstreamFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Evaluate(&WorkflowRun{Conclusion: tt.conclusion}, tt.jobs)
+			if kind := KindOf(err); kind != tt.kind {
+				t.Errorf("expected kind %q, got %q (%v)", tt.kind, kind, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_EvaluateNamesBlockingJobs(t *testing.T) {
+	policy := Policy{NonBlockingJobs: []string{"lint"}}
+	jobs := []Job{
+		{Name: "lint", Conclusion: "failure"},
+		{Name: "deploy", Conclusion: "failure"},
+	}
+
+	err := policy.Evaluate(&WorkflowRun{Conclusion: "failure"}, jobs)
+	if err == nil || !strings.Contains(err.Error(), "blocking jobs: deploy") {
+		t.Errorf("expected error naming deploy, got %v", err)
+	}
+}
+
+func TestValidateConclusions(t *testing.T) {
+	if err := ValidateConclusions([]string{"success", "Cancelled", "timed_out"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateConclusions([]string{"success", "passed"}); err == nil {
+		t.Error("expected error for unknown conclusion")
+	}
+}
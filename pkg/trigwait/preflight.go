@@ -0,0 +1,179 @@
+package trigwait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// PreflightOptions selects the optional preflight checks.
+type PreflightOptions struct {
+	// ValidateInputs checks the dispatch inputs against the inputs the
+	// workflow file declares.
+	ValidateInputs bool
+}
+
+// CheckResult is the outcome of a single preflight check.
+type CheckResult struct {
+	Name string
+	// Err is nil when the check passed.
+	Err error
+	// Remediation explains how to fix a failed check.
+	Remediation string
+	// Warning is set when the check passed because it could not be verified.
+	Warning string
+}
+
+// Preflight checks that a dispatch can succeed: the repository is reachable,
+// the workflow exists, is active and declares workflow_dispatch, the ref
+// exists and the token may dispatch workflows. Checks that depend on a failed
+// check are skipped. The returned error, of the kind of the first failed
+// check, is nil when all checks passed.
+func (c *Client) Preflight(ctx context.Context, d Dispatch, opts PreflightOptions) ([]CheckResult, error) {
+	c.logf(LevelInfo, "🔍 Preflight checks for %s/%s → %s @ %s", c.owner, c.repo, d.Workflow, d.Ref)
+
+	var results []CheckResult
+	record := func(r CheckResult) {
+		switch {
+		case r.Err != nil:
+			c.logf(LevelError, "   ✗ %s: %v", r.Name, r.Err)
+			if r.Remediation != "" {
+				c.logf(LevelError, "     → %s", r.Remediation)
+			}
+		case r.Warning != "":
+			c.logf(LevelWarn, "⚠ %s: %s", r.Name, r.Warning)
+		default:
+			c.logf(LevelInfo, "   ✓ %s", r.Name)
+		}
+		results = append(results, r)
+	}
+	full := c.owner + "/" + c.repo
+
+	repo, err := c.GetRepository(ctx)
+	if err != nil {
+		record(CheckResult{Name: "repository reachable", Err: err, Remediation: repoRemediation(err, full)})
+		return results, preflightError(results)
+	}
+	record(CheckResult{Name: "repository reachable"})
+
+	switch allowed, known := repo.CanDispatch(); {
+	case !known:
+		record(CheckResult{Name: "token can dispatch workflows", Warning: "actions:write cannot be verified for this token; make sure it has it"})
+	case !allowed:
+		record(CheckResult{
+			Name:        "token can dispatch workflows",
+			Err:         Errorf(KindAuth, "token lacks actions:write on %s", full),
+			Remediation: "Fine-grained tokens need the \"Actions: Read and write\" permission, classic tokens the repo scope, and GITHUB_TOKEN `permissions: actions: write`.",
+		})
+	default:
+		record(CheckResult{Name: "token can dispatch workflows"})
+	}
+
+	if exists, err := c.RefExists(ctx, d.Ref); err != nil {
+		record(CheckResult{Name: "ref exists", Err: fmt.Errorf("could not check ref %s: %w", d.Ref, err)})
+	} else if !exists {
+		record(CheckResult{
+			Name:        "ref exists",
+			Err:         Errorf(KindDispatchRejected, "ref %s is not a branch, tag or commit of %s", d.Ref, full),
+			Remediation: fmt.Sprintf("Set ref to an existing branch or tag; the default branch of %s is %s.", full, repo.DefaultBranch),
+		})
+	} else {
+		record(CheckResult{Name: "ref exists"})
+	}
+
+	wf, err := c.GetWorkflow(ctx, d.Workflow)
+	if err != nil {
+		remediation := ""
+		if KindOf(err) == KindNotFound {
+			err = Errorf(KindNotFound, "workflow %s not found in %s", d.Workflow, full)
+			remediation = fmt.Sprintf("Use the file name of a workflow under .github/workflows (e.g. deploy.yml); it must exist on the default branch (%s).", repo.DefaultBranch)
+			if workflows, listErr := c.ListWorkflows(ctx); listErr == nil {
+				if suggestions := suggestWorkflows(workflows, d.Workflow); len(suggestions) > 0 {
+					remediation += " Did you mean " + strings.Join(suggestions, " or ") + "?"
+				}
+			}
+		}
+		record(CheckResult{Name: "workflow exists", Err: err, Remediation: remediation})
+		return results, preflightError(results)
+	}
+	record(CheckResult{Name: "workflow exists"})
+
+	if wf.State != "" && wf.State != "active" {
+		record(CheckResult{
+			Name:        "workflow active",
+			Err:         Errorf(KindDispatchRejected, "workflow %s is %s", wf.Path, wf.State),
+			Remediation: fmt.Sprintf("Enable it from the Actions tab of %s or with `gh workflow enable %s --repo %s`.", full, d.Workflow, full),
+		})
+	} else {
+		record(CheckResult{Name: "workflow active"})
+	}
+
+	source, err := c.WorkflowFile(ctx, wf.Path, d.Ref)
+	if err != nil {
+		// Reading files needs contents:read, which dispatching does not
+		r := CheckResult{Name: "workflow declares workflow_dispatch"}
+		if opts.ValidateInputs {
+			r.Err = fmt.Errorf("could not read %s at %s: %w", wf.Path, d.Ref, err)
+			r.Remediation = "Input validation reads the workflow file; give the token contents:read or disable validate_inputs."
+		} else {
+			r.Warning = fmt.Sprintf("could not read %s at %s (%v); the trigger was not verified", wf.Path, d.Ref, err)
+		}
+		record(r)
+		return results, preflightError(results)
+	}
+	declared, dispatchable, err := ParseDispatchInputs(source)
+	if err != nil {
+		record(CheckResult{Name: "workflow declares workflow_dispatch", Warning: err.Error()})
+		return results, preflightError(results)
+	}
+	if !dispatchable {
+		record(CheckResult{
+			Name:        "workflow declares workflow_dispatch",
+			Err:         Errorf(KindDispatchRejected, "%s does not declare a workflow_dispatch trigger at %s", wf.Path, d.Ref),
+			Remediation: fmt.Sprintf("Add `workflow_dispatch:` under `on:` in %s on %s.", wf.Path, d.Ref),
+		})
+		return results, preflightError(results)
+	}
+	record(CheckResult{Name: "workflow declares workflow_dispatch"})
+
+	if opts.ValidateInputs {
+		if err := ValidateInputs(declared, d.Inputs); err != nil {
+			record(CheckResult{
+				Name:        "inputs match workflow_dispatch inputs",
+				Err:         err,
+				Remediation: fmt.Sprintf("Update client_payload or the inputs declared in %s so they match.", wf.Path),
+			})
+		} else {
+			record(CheckResult{Name: "inputs match workflow_dispatch inputs"})
+		}
+	}
+
+	return results, preflightError(results)
+}
+
+// preflightError returns the first failed check as an error of its kind.
+func preflightError(results []CheckResult) error {
+	for _, r := range results {
+		if r.Err != nil {
+			kind := KindOf(r.Err)
+			return &Error{Kind: kind, Err: fmt.Errorf("preflight check %q failed: %w", r.Name, r.Err)}
+		}
+	}
+	return nil
+}
+
+func repoRemediation(err error, full string) string {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	switch apiErr.StatusCode {
+	case http.StatusUnauthorized:
+		return "The token is invalid or expired; regenerate it and update the secret passed as github_token."
+	case http.StatusNotFound, http.StatusForbidden:
+		return fmt.Sprintf("Check owner and repo are spelled correctly and the token can access %s. Fine-grained tokens must include it under \"Repository access\"; GITHUB_TOKEN only reaches the current repository.", full)
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package trigwait_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PhuongTMR/workflow-trigwait/pkg/fakegh"
+	"github.com/PhuongTMR/workflow-trigwait/pkg/trigwait"
+)
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name     string
+		repo     *fakegh.Repo
+		owner    string
+		workflow string
+		ref      string
+		inputs   map[string]interface{}
+		check    string
+		kind     trigwait.ErrorKind
+	}{
+		{
+			name: "all checks pass",
+			repo: &fakegh.Repo{Workflows: []*fakegh.Workflow{{Name: "Deploy", Path: "deploy.yml", Inputs: []string{"env"}}}},
+		},
+		{
+			name:  "repository not found",
+			repo:  &fakegh.Repo{},
+			owner: "someone-else",
+			check: "repository reachable",
+			kind:  trigwait.KindNotFound,
+		},
+		{
+			name:  "read-only token",
+			repo:  &fakegh.Repo{ReadOnly: true, Workflows: []*fakegh.Workflow{{Name: "Deploy", Path: "deploy.yml"}}},
+			check: "token can dispatch workflows",
+			kind:  trigwait.KindAuth,
+		},
+		{
+			name:  "missing ref",
+			repo:  &fakegh.Repo{Workflows: []*fakegh.Workflow{{Name: "Deploy", Path: "deploy.yml"}}},
+			ref:   "feature/missing",
+			check: "ref exists",
+			kind:  trigwait.KindDispatchRejected,
+		},
+		{
+			name:     "missing workflow",
+			repo:     &fakegh.Repo{Workflows: []*fakegh.Workflow{{Name: "Deploy", Path: "deploy.yml"}}},
+			workflow: "deploy.yaml",
+			check:    "workflow exists",
+			kind:     trigwait.KindNotFound,
+		},
+		{
+			name:  "disabled workflow",
+			repo:  &fakegh.Repo{Workflows: []*fakegh.Workflow{{Name: "Deploy", Path: "deploy.yml", State: "disabled_manually"}}},
+			check: "workflow active",
+			kind:  trigwait.KindDispatchRejected,
+		},
+		{
+			name:  "no workflow_dispatch trigger",
+			repo:  &fakegh.Repo{Workflows: []*fakegh.Workflow{{Name: "Deploy", Path: "deploy.yml", NoDispatch: true}}},
+			check: "workflow declares workflow_dispatch",
+			kind:  trigwait.KindDispatchRejected,
+		},
+		{
+			name:   "undeclared input",
+			repo:   &fakegh.Repo{Workflows: []*fakegh.Workflow{{Name: "Deploy", Path: "deploy.yml", Inputs: []string{"env"}}}},
+			inputs: map[string]interface{}{"environment": "prod"},
+			check:  "inputs match workflow_dispatch inputs",
+			kind:   trigwait.KindDispatchRejected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.repo.Owner, tt.repo.Name = "owner", "repo"
+			server := httptest.NewServer(fakegh.New(&fakegh.Config{Repos: []*fakegh.Repo{tt.repo}}))
+			defer server.Close()
+
+			owner := "owner"
+			if tt.owner != "" {
+				owner = tt.owner
+			}
+			d := trigwait.Dispatch{Workflow: "deploy.yml", Ref: "main", Inputs: tt.inputs}
+			if tt.workflow != "" {
+				d.Workflow = tt.workflow
+			}
+			if tt.ref != "" {
+				d.Ref = tt.ref
+			}
+
+			client := trigwait.New(owner, "repo", "token", trigwait.WithAPIURL(server.URL))
+			results, err := client.Preflight(context.Background(), d, trigwait.PreflightOptions{ValidateInputs: true})
+
+			if tt.check == "" {
+				if err != nil {
+					t.Fatalf("expected all checks to pass, got %v", err)
+				}
+				return
+			}
+			if kind := trigwait.KindOf(err); kind != tt.kind {
+				t.Errorf("expected kind %s, got %s (%v)", tt.kind, kind, err)
+			}
+			var failed *trigwait.CheckResult
+			for i := range results {
+				if results[i].Err != nil {
+					failed = &results[i]
+					break
+				}
+			}
+			if failed == nil || failed.Name != tt.check {
+				t.Fatalf("expected check %q to fail, got %+v", tt.check, results)
+			}
+			if failed.Remediation == "" {
+				t.Errorf("expected a remediation for %q", failed.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package trigwait
+
+import (
+	"context"
+	"fmt"
+)
+
+// RefMode selects how the dispatch ref is chosen.
+type RefMode string
+
+const (
+	// RefExplicit uses the configured ref as is.
+	RefExplicit RefMode = "explicit"
+	// RefDefault uses the repository's default branch.
+	RefDefault RefMode = "default"
+	// RefSameAsCaller uses the caller's branch when a branch of the same
+	// name exists in the repository, and falls back otherwise.
+	RefSameAsCaller RefMode = "same-as-caller"
+)
+
+// ParseRefMode parses a ref mode name.
+func ParseRefMode(s string) (RefMode, error) {
+	switch mode := RefMode(s); mode {
+	case RefExplicit, RefDefault, RefSameAsCaller:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid ref mode %q (expected explicit, default or same-as-caller)", s)
+}
+
+// ResolveRef returns the ref to dispatch on. ref is used by RefExplicit and
+// as the fallback of RefSameAsCaller; when it is empty the fallback is the
+// default branch. callerBranch is the branch the caller runs on, if any.
+func (c *Client) ResolveRef(ctx context.Context, mode RefMode, ref, callerBranch string) (string, error) {
+	switch mode {
+	case RefExplicit:
+		return ref, nil
+	case RefSameAsCaller:
+		if callerBranch != "" {
+			exists, err := c.BranchExists(ctx, callerBranch)
+			if err != nil {
+				return "", fmt.Errorf("failed to look up branch %s: %w", callerBranch, err)
+			}
+			if exists {
+				c.logf(LevelInfo, "   Using caller branch %s", callerBranch)
+				return callerBranch, nil
+			}
+			c.logf(LevelInfo, "   Branch %s does not exist in %s/%s; falling back", callerBranch, c.owner, c.repo)
+		}
+		if ref != "" {
+			return ref, nil
+		}
+	}
+
+	repo, err := c.GetRepository(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up default branch: %w", err)
+	}
+	c.logf(LevelInfo, "   Using default branch %s", repo.DefaultBranch)
+	return repo.DefaultBranch, nil
+}
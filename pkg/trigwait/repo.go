@@ -0,0 +1,291 @@
+package trigwait
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PhuongTMR/workflow-trigwait/internal/miniyaml"
+)
+
+// Repository is the repository metadata used by pre-dispatch checks.
+type Repository struct {
+	FullName      string                 `json:"full_name"`
+	DefaultBranch string                 `json:"default_branch"`
+	Private       bool                   `json:"private"`
+	Permissions   *RepositoryPermissions `json:"permissions"`
+	// Scopes are the OAuth scopes of a classic token, read from the
+	// X-OAuth-Scopes header. Nil for tokens that do not report scopes.
+	Scopes []string `json:"-"`
+}
+
+// RepositoryPermissions are the token's permissions on the repository.
+type RepositoryPermissions struct {
+	Admin bool `json:"admin"`
+	Push  bool `json:"push"`
+	Pull  bool `json:"pull"`
+}
+
+// CanDispatch reports whether the token may dispatch workflows, which needs
+// actions:write. Only classic tokens report scopes that answer this. For
+// other tokens the repository permissions can only rule dispatching out:
+// push reflects write access to the contents, not the Actions permission of
+// a fine-grained or GitHub App token, so known is false unless the
+// permissions are read-only.
+func (r *Repository) CanDispatch() (allowed, known bool) {
+	if r.Scopes != nil {
+		for _, scope := range r.Scopes {
+			if scope == "repo" || (scope == "public_repo" && !r.Private) {
+				return true, true
+			}
+		}
+		return false, true
+	}
+	if r.Permissions != nil && !r.Permissions.Push && !r.Permissions.Admin {
+		return false, true
+	}
+	return false, false
+}
+
+// WorkflowInfo is a workflow as listed by the workflows API.
+type WorkflowInfo struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	State string `json:"state"`
+}
+
+// WorkflowInput is a declared workflow_dispatch input.
+type WorkflowInput struct {
+	Name        string
+	Description string
+	Type        string
+	Required    bool
+	Default     interface{}
+	Options     []string
+}
+
+// GetRepository fetches the repository, which also verifies the token can
+// read it.
+func (c *Client) GetRepository(ctx context.Context) (*Repository, error) {
+	respBody, header, err := c.repoRequest(ctx, "GET", "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var repo Repository
+	if err := json.Unmarshal(respBody, &repo); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if _, ok := header["X-Oauth-Scopes"]; ok {
+		repo.Scopes = []string{}
+		for _, scope := range strings.Split(header.Get("X-OAuth-Scopes"), ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				repo.Scopes = append(repo.Scopes, scope)
+			}
+		}
+	}
+	return &repo, nil
+}
+
+// RefExists reports whether ref names a branch, a tag or a commit of the
+// repository.
+func (c *Client) RefExists(ctx context.Context, ref string) (bool, error) {
+	ref = strings.TrimPrefix(ref, "refs/heads/")
+	paths := []string{
+		"branches/" + escapePath(ref),
+		"git/ref/tags/" + escapePath(strings.TrimPrefix(ref, "refs/tags/")),
+	}
+	if isCommitSHA(ref) {
+		paths = append(paths, "commits/"+ref)
+	}
+
+	for _, path := range paths {
+		if exists, err := c.exists(ctx, path); exists || err != nil {
+			return exists, err
+		}
+	}
+	return false, nil
+}
+
+// BranchExists reports whether branch is a branch of the repository.
+func (c *Client) BranchExists(ctx context.Context, branch string) (bool, error) {
+	return c.exists(ctx, "branches/"+escapePath(strings.TrimPrefix(branch, "refs/heads/")))
+}
+
+// exists reports whether GET path succeeds, treating 404 and 422 as absent.
+func (c *Client) exists(ctx context.Context, path string) (bool, error) {
+	_, _, err := c.repoRequest(ctx, "GET", path, nil)
+	if err == nil {
+		return true, nil
+	}
+	if KindOf(err) == KindNotFound || isStatus(err, http.StatusUnprocessableEntity) {
+		return false, nil
+	}
+	return false, err
+}
+
+// GetWorkflow fetches a workflow by file name or numeric ID.
+func (c *Client) GetWorkflow(ctx context.Context, workflow string) (*WorkflowInfo, error) {
+	respBody, err := c.apiRequest(ctx, "GET", "workflows/"+url.PathEscape(workflow), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var wf WorkflowInfo
+	if err := json.Unmarshal(respBody, &wf); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &wf, nil
+}
+
+// WorkflowFile returns the content of the workflow file at path on ref.
+func (c *Client) WorkflowFile(ctx context.Context, path, ref string) ([]byte, error) {
+	respBody, _, err := c.repoRequest(ctx, "GET", "contents/"+escapePath(path)+"?ref="+url.QueryEscape(ref), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+	}
+	if err := json.Unmarshal(respBody, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if file.Encoding != "base64" {
+		return nil, fmt.Errorf("unsupported content encoding %q", file.Encoding)
+	}
+	return base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+}
+
+// ParseDispatchInputs reads a workflow file and reports whether it declares
+// a workflow_dispatch trigger, and the inputs it declares, sorted by name.
+func ParseDispatchInputs(source []byte) (inputs []WorkflowInput, dispatchable bool, err error) {
+	doc, err := miniyaml.Unmarshal(source)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse workflow file: %w", err)
+	}
+	root, _ := doc.(map[string]interface{})
+
+	var dispatch interface{}
+	switch on := root["on"].(type) {
+	case string:
+		dispatchable = on == "workflow_dispatch"
+	case []interface{}:
+		for _, event := range on {
+			dispatchable = dispatchable || event == "workflow_dispatch"
+		}
+	case map[string]interface{}:
+		dispatch, dispatchable = on["workflow_dispatch"]
+	}
+
+	config, _ := dispatch.(map[string]interface{})
+	declared, _ := config["inputs"].(map[string]interface{})
+	for name, spec := range declared {
+		input := WorkflowInput{Name: name, Type: "string"}
+		if spec, ok := spec.(map[string]interface{}); ok {
+			input.Description, _ = spec["description"].(string)
+			if t, ok := spec["type"].(string); ok {
+				input.Type = t
+			}
+			input.Required = spec["required"] == true
+			input.Default = spec["default"]
+			if options, ok := spec["options"].([]interface{}); ok {
+				for _, option := range options {
+					input.Options = append(input.Options, fmt.Sprint(option))
+				}
+			}
+		}
+		inputs = append(inputs, input)
+	}
+	sort.Slice(inputs, func(i, j int) bool { return inputs[i].Name < inputs[j].Name })
+	return inputs, dispatchable, nil
+}
+
+// ValidateInputs checks inputs against the declared workflow_dispatch
+// inputs the way GitHub does on dispatch. The returned error, of kind
+// KindDispatchRejected, lists every problem found.
+func ValidateInputs(declared []WorkflowInput, inputs map[string]interface{}) error {
+	var problems []string
+	known := make(map[string]WorkflowInput, len(declared))
+	for _, input := range declared {
+		known[input.Name] = input
+	}
+
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		input, ok := known[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unexpected input %q", name))
+			continue
+		}
+		value := fmt.Sprint(inputs[name])
+		switch input.Type {
+		case "boolean":
+			if value != "true" && value != "false" {
+				problems = append(problems, fmt.Sprintf("input %q must be true or false, got %q", name, value))
+			}
+		case "number":
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				problems = append(problems, fmt.Sprintf("input %q must be a number, got %q", name, value))
+			}
+		case "choice":
+			valid := false
+			for _, option := range input.Options {
+				valid = valid || option == value
+			}
+			if !valid {
+				problems = append(problems, fmt.Sprintf("input %q must be one of [%s], got %q", name, strings.Join(input.Options, ", "), value))
+			}
+		}
+	}
+
+	for _, input := range declared {
+		if _, ok := inputs[input.Name]; !ok && input.Required && input.Default == nil {
+			problems = append(problems, fmt.Sprintf("missing required input %q", input.Name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return Errorf(KindDispatchRejected, "invalid inputs: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// escapePath escapes each segment of a slash-separated path.
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func isCommitSHA(ref string) bool {
+	if len(ref) != 40 {
+		return false
+	}
+	for _, r := range ref {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isStatus(err error, statusCode int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
@@ -0,0 +1,112 @@
+package trigwait
+
+import (
+	"strings"
+	"testing"
+)
+
+const deployWorkflow = `name: Deploy
+on:
+  push:
+    branches: [main]
+  workflow_dispatch:
+    inputs:
+      environment:
+        required: true
+        type: choice
+        options: [staging, production]
+      dry:
+        type: boolean
+        default: false
+      replicas:
+        type: number
+      note:
+        description: Free text
+`
+
+func TestParseDispatchInputs(t *testing.T) {
+	inputs, dispatchable, err := ParseDispatchInputs([]byte(deployWorkflow))
+	if err != nil {
+		t.Fatalf("ParseDispatchInputs failed: %v", err)
+	}
+	if !dispatchable {
+		t.Error("expected workflow to be dispatchable")
+	}
+
+	var names []string
+	for _, input := range inputs {
+		names = append(names, input.Name)
+	}
+	if got := strings.Join(names, ","); got != "dry,environment,note,replicas" {
+		t.Errorf("unexpected inputs: %s", got)
+	}
+	if env := inputs[1]; !env.Required || env.Type != "choice" || len(env.Options) != 2 {
+		t.Errorf("unexpected environment input: %+v", env)
+	}
+
+	for _, src := range []string{"on: push", "on: [push, pull_request]", "on:\n  push:\n"} {
+		if _, dispatchable, _ := ParseDispatchInputs([]byte(src)); dispatchable {
+			t.Errorf("%q: expected workflow not to be dispatchable", src)
+		}
+	}
+	if _, dispatchable, _ := ParseDispatchInputs([]byte("on: [push, workflow_dispatch]")); !dispatchable {
+		t.Error("expected list trigger to be dispatchable")
+	}
+}
+
+func TestValidateInputs(t *testing.T) {
+	declared, _, _ := ParseDispatchInputs([]byte(deployWorkflow))
+
+	tests := []struct {
+		name    string
+		inputs  map[string]interface{}
+		problem string
+	}{
+		{"valid", map[string]interface{}{"environment": "staging", "dry": "true", "replicas": 3}, ""},
+		{"missing required", map[string]interface{}{}, `missing required input "environment"`},
+		{"unexpected", map[string]interface{}{"environment": "staging", "extra": "x"}, `unexpected input "extra"`},
+		{"bad choice", map[string]interface{}{"environment": "qa"}, `must be one of [staging, production]`},
+		{"bad boolean", map[string]interface{}{"environment": "staging", "dry": "yes"}, `"dry" must be true or false`},
+		{"bad number", map[string]interface{}{"environment": "staging", "replicas": "many"}, `"replicas" must be a number`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateInputs(declared, tt.inputs)
+			if tt.problem == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("expected error containing %q, got %v", tt.problem, err)
+			}
+			if KindOf(err) != KindDispatchRejected {
+				t.Errorf("expected kind %s, got %s", KindDispatchRejected, KindOf(err))
+			}
+		})
+	}
+}
+
+func TestRepository_CanDispatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		repo           Repository
+		allowed, known bool
+	}{
+		{"classic repo scope", Repository{Scopes: []string{"repo", "workflow"}}, true, true},
+		{"classic public_repo on private repo", Repository{Private: true, Scopes: []string{"public_repo"}}, false, true},
+		{"classic no scopes", Repository{Scopes: []string{}}, false, true},
+		{"push permission does not imply actions:write", Repository{Permissions: &RepositoryPermissions{Push: true}}, false, false},
+		{"read-only permission", Repository{Permissions: &RepositoryPermissions{Pull: true}}, false, true},
+		{"app token", Repository{}, false, false},
+	}
+
+	for _, tt := range tests {
+		allowed, known := tt.repo.CanDispatch()
+		if allowed != tt.allowed || known != tt.known {
+			t.Errorf("%s: expected (%v, %v), got (%v, %v)", tt.name, tt.allowed, tt.known, allowed, known)
+		}
+	}
+}
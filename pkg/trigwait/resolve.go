@@ -0,0 +1,167 @@
+package trigwait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ListWorkflows returns all workflows of the repository.
+func (c *Client) ListWorkflows(ctx context.Context) ([]WorkflowInfo, error) {
+	var workflows []WorkflowInfo
+	for page := 1; ; page++ {
+		respBody, err := c.apiRequest(ctx, "GET", fmt.Sprintf("workflows?per_page=100&page=%d", page), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var response struct {
+			TotalCount int            `json:"total_count"`
+			Workflows  []WorkflowInfo `json:"workflows"`
+		}
+		if err := json.Unmarshal(respBody, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		workflows = append(workflows, response.Workflows...)
+		if len(response.Workflows) == 0 || len(workflows) >= response.TotalCount {
+			return workflows, nil
+		}
+	}
+}
+
+// IsWorkflowFileName reports whether workflow is a bare file name such as
+// deploy.yml, which the API accepts without resolving it.
+func IsWorkflowFileName(workflow string) bool {
+	return !strings.Contains(workflow, "/") &&
+		(strings.HasSuffix(workflow, ".yml") || strings.HasSuffix(workflow, ".yaml"))
+}
+
+// ResolveWorkflow finds a workflow by numeric ID, file path
+// (.github/workflows/deploy.yml), file name (deploy.yml) or display name
+// (Deploy). Display names are matched exactly, then case-insensitively.
+// Unknown workflows fail with KindNotFound and suggest similar names;
+// ambiguous names fail with KindConfig and list the candidates.
+func (c *Client) ResolveWorkflow(ctx context.Context, workflow string) (*WorkflowInfo, error) {
+	workflows, err := c.ListWorkflows(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflows: %w", err)
+	}
+	return resolveWorkflow(workflows, workflow, c.owner+"/"+c.repo)
+}
+
+func resolveWorkflow(workflows []WorkflowInfo, workflow, repo string) (*WorkflowInfo, error) {
+	match := func(pred func(wf WorkflowInfo) bool) []WorkflowInfo {
+		var matches []WorkflowInfo
+		for _, wf := range workflows {
+			if pred(wf) {
+				matches = append(matches, wf)
+			}
+		}
+		return matches
+	}
+
+	var matches []WorkflowInfo
+	if id, err := strconv.ParseInt(workflow, 10, 64); err == nil {
+		matches = match(func(wf WorkflowInfo) bool { return wf.ID == id })
+	}
+	if len(matches) == 0 {
+		path := strings.TrimPrefix(workflow, "./")
+		matches = match(func(wf WorkflowInfo) bool { return wf.Path == path || workflowFileName(wf) == path })
+	}
+	if len(matches) == 0 {
+		matches = match(func(wf WorkflowInfo) bool { return wf.Name == workflow })
+	}
+	if len(matches) == 0 {
+		matches = match(func(wf WorkflowInfo) bool { return strings.EqualFold(wf.Name, workflow) })
+	}
+
+	switch len(matches) {
+	case 1:
+		return &matches[0], nil
+	case 0:
+		msg := fmt.Sprintf("workflow %q not found in %s", workflow, repo)
+		if suggestions := suggestWorkflows(workflows, workflow); len(suggestions) > 0 {
+			msg += "; did you mean " + strings.Join(suggestions, " or ") + "?"
+		}
+		return nil, Errorf(KindNotFound, "%s", msg)
+	}
+
+	var candidates []string
+	for _, wf := range matches {
+		candidates = append(candidates, fmt.Sprintf("%s (ID %d)", wf.Path, wf.ID))
+	}
+	return nil, Errorf(KindConfig, "workflow name %q is ambiguous in %s: it matches %s; use the file name or ID instead",
+		workflow, repo, strings.Join(candidates, ", "))
+}
+
+// workflowFileName returns the file name of the workflow's path.
+func workflowFileName(wf WorkflowInfo) string {
+	return wf.Path[strings.LastIndex(wf.Path, "/")+1:]
+}
+
+// suggestWorkflows returns up to three workflows whose name or file name is
+// close to workflow, closest first.
+func suggestWorkflows(workflows []WorkflowInfo, workflow string) []string {
+	type candidate struct {
+		label    string
+		distance int
+	}
+	var candidates []candidate
+	target := strings.ToLower(workflow)
+	for _, wf := range workflows {
+		best := -1
+		for _, s := range []string{wf.Name, workflowFileName(wf), wf.Path} {
+			d := levenshtein(target, strings.ToLower(s))
+			if best < 0 || d < best {
+				best = d
+			}
+		}
+		// Allow roughly one typo per three characters
+		if best <= len(workflow)/3+1 || strings.Contains(strings.ToLower(wf.Name), target) {
+			candidates = append(candidates, candidate{fmt.Sprintf("%q (%s)", wf.Name, workflowFileName(wf)), best})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < 3; i++ {
+		suggestions = append(suggestions, candidates[i].label)
+	}
+	return suggestions
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
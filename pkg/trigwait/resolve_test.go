@@ -0,0 +1,60 @@
+package trigwait
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveWorkflow(t *testing.T) {
+	workflows := []WorkflowInfo{
+		{ID: 101, Name: "Deploy Production", Path: ".github/workflows/deploy-prod.yml"},
+		{ID: 102, Name: "Deploy Staging", Path: ".github/workflows/deploy-staging.yml"},
+		{ID: 103, Name: "CI", Path: ".github/workflows/ci.yml"},
+		{ID: 104, Name: "Nightly", Path: ".github/workflows/nightly-a.yml"},
+		{ID: 105, Name: "Nightly", Path: ".github/workflows/nightly-b.yml"},
+	}
+
+	tests := []struct {
+		workflow string
+		id       int64
+		kind     ErrorKind
+		message  string
+	}{
+		{workflow: "103", id: 103},
+		{workflow: ".github/workflows/deploy-prod.yml", id: 101},
+		{workflow: "./.github/workflows/ci.yml", id: 103},
+		{workflow: "deploy-staging.yml", id: 102},
+		{workflow: "Deploy Staging", id: 102},
+		{workflow: "deploy production", id: 101},
+		{workflow: "Nightly", kind: KindConfig, message: "nightly-a.yml (ID 104), .github/workflows/nightly-b.yml (ID 105)"},
+		{workflow: "Deploy Prodution", kind: KindNotFound, message: `did you mean "Deploy Production" (deploy-prod.yml)`},
+		{workflow: "release.yml", kind: KindNotFound, message: `workflow "release.yml" not found in owner/repo`},
+	}
+
+	for _, tt := range tests {
+		wf, err := resolveWorkflow(workflows, tt.workflow, "owner/repo")
+		if tt.kind == "" {
+			if err != nil || wf.ID != tt.id {
+				t.Errorf("%q: expected ID %d, got %+v, %v", tt.workflow, tt.id, wf, err)
+			}
+			continue
+		}
+		if KindOf(err) != tt.kind || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%q: expected %s error containing %q, got %s: %v", tt.workflow, tt.kind, tt.message, KindOf(err), err)
+		}
+	}
+}
+
+func TestIsWorkflowFileName(t *testing.T) {
+	for workflow, want := range map[string]bool{
+		"deploy.yml":                   true,
+		"deploy.yaml":                  true,
+		".github/workflows/deploy.yml": false,
+		"Deploy":                       false,
+		"12345":                        false,
+	} {
+		if got := IsWorkflowFileName(workflow); got != want {
+			t.Errorf("IsWorkflowFileName(%q) = %v, want %v", workflow, got, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package trigwait
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SLOAction is what Wait does when a run exceeds an SLO limit.
+type SLOAction string
+
+const (
+	// SLOWarn logs a warning and keeps waiting.
+	SLOWarn SLOAction = "warn"
+	// SLOFail stops waiting with a KindSLOBreached error.
+	SLOFail SLOAction = "fail"
+	// SLOCancel cancels the run, then fails like SLOFail.
+	SLOCancel SLOAction = "cancel"
+)
+
+// ParseSLOAction parses warn, fail or cancel.
+func ParseSLOAction(s string) (SLOAction, error) {
+	switch action := SLOAction(s); action {
+	case SLOWarn, SLOFail, SLOCancel:
+		return action, nil
+	}
+	return "", fmt.Errorf("invalid slo_action %q: must be warn, fail or cancel", s)
+}
+
+// SLO limits how long a run may be queued and how long it may run.
+type SLO struct {
+	// MaxQueueTime limits the time from the run's creation until it starts.
+	// Zero means no limit.
+	MaxQueueTime time.Duration
+	// MaxRunTime limits the time from the run's start until it completes.
+	// Zero means no limit.
+	MaxRunTime time.Duration
+	// Action defaults to SLOFail.
+	Action SLOAction
+}
+
+// runClock tracks when a run was created, started and completed, as
+// observed by Wait.
+type runClock struct {
+	created   time.Time
+	started   time.Time
+	completed time.Time
+}
+
+// observe records the phase transitions visible in run at now.
+func (rc *runClock) observe(run *WorkflowRun, now time.Time) {
+	if createdAt, err := time.Parse(time.RFC3339, run.CreatedAt); err == nil && createdAt.Before(rc.created) {
+		rc.created = createdAt
+	}
+	if rc.started.IsZero() && !isQueued(run.Status) {
+		rc.started = now
+	}
+	if rc.completed.IsZero() && run.Status == "completed" {
+		rc.completed = now
+	}
+}
+
+// durations returns how long the run was queued and how long it has run
+// as of now.
+func (rc *runClock) durations(now time.Time) (queued, running time.Duration) {
+	if rc.started.IsZero() {
+		return now.Sub(rc.created), 0
+	}
+	end := now
+	if !rc.completed.IsZero() {
+		end = rc.completed
+	}
+	return rc.started.Sub(rc.created), end.Sub(rc.started)
+}
+
+// breach describes the limit the run exceeds at now, or returns "".
+func (s SLO) breach(rc *runClock, now time.Time) string {
+	queued, running := rc.durations(now)
+	if s.MaxQueueTime > 0 && rc.started.IsZero() && queued > s.MaxQueueTime {
+		return fmt.Sprintf("run queued for %v, exceeding max_queue_time %v", queued.Round(time.Second), s.MaxQueueTime)
+	}
+	if s.MaxRunTime > 0 && !rc.started.IsZero() && running > s.MaxRunTime {
+		return fmt.Sprintf("run in progress for %v, exceeding max_run_time %v", running.Round(time.Second), s.MaxRunTime)
+	}
+	return ""
+}
+
+// untilBreach returns how long until the current phase exceeds its limit,
+// or d if that is later.
+func (s SLO) untilBreach(rc *runClock, now time.Time, d time.Duration) time.Duration {
+	queued, running := rc.durations(now)
+	limit, elapsed := s.MaxQueueTime, queued
+	if !rc.started.IsZero() {
+		limit, elapsed = s.MaxRunTime, running
+	}
+	if limit > 0 && elapsed < limit && limit-elapsed < d {
+		// Poll just after the limit so the breach is visible
+		return limit - elapsed + time.Millisecond
+	}
+	return d
+}
+
+// enforceSLO applies the SLO action when the run exceeds a limit. warned
+// remembers breaches already reported with SLOWarn.
+func (c *Client) enforceSLO(ctx context.Context, runID int64, rc *runClock, warned map[string]bool) error {
+	msg := c.slo.breach(rc, time.Now())
+	if msg == "" {
+		return nil
+	}
+
+	switch c.slo.Action {
+	case SLOWarn:
+		phase := "queue"
+		if !rc.started.IsZero() {
+			phase = "run"
+		}
+		if !warned[phase] {
+			c.logf(LevelWarn, "⚠ SLO breached: %s", msg)
+			warned[phase] = true
+		}
+		return nil
+	case SLOCancel:
+		c.logf(LevelError, "   ✗ SLO breached: %s; cancelling run", msg)
+		if err := c.CancelRun(ctx, runID); err != nil {
+			c.logf(LevelWarn, "⚠ Could not cancel run: %v", err)
+		}
+	}
+	return Errorf(KindSLOBreached, "SLO breached: %s", msg)
+}
+
+func isQueued(status string) bool {
+	switch status {
+	case "queued", "waiting", "pending", "requested":
+		return true
+	}
+	return false
+}
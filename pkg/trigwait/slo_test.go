@@ -0,0 +1,152 @@
+package trigwait
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sloServer serves a run that is queued for queueTime, then in progress for
+// runTime, then completed. It counts cancellation requests.
+func sloServer(t *testing.T, queueTime, runTime time.Duration, cancels *int32) *httptest.Server {
+	t.Helper()
+	start := time.Now()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/cancel") {
+			atomic.AddInt32(cancels, 1)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		run := WorkflowRun{ID: 1, Status: "queued"}
+		switch elapsed := time.Since(start); {
+		case elapsed > queueTime+runTime:
+			run.Status, run.Conclusion = "completed", "success"
+		case elapsed > queueTime:
+			run.Status = "in_progress"
+		}
+		json.NewEncoder(w).Encode(run)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWait_SLO(t *testing.T) {
+	hour := time.Hour
+	tests := []struct {
+		name        string
+		queueTime   time.Duration
+		runTime     time.Duration
+		slo         SLO
+		wantKind    ErrorKind
+		wantMessage string
+		wantCancels int32
+	}{
+		{
+			name:    "within limits",
+			runTime: 20 * time.Millisecond,
+			slo:     SLO{MaxQueueTime: time.Second, MaxRunTime: time.Second, Action: SLOFail},
+		},
+		{
+			name:        "queue time exceeded",
+			queueTime:   hour,
+			slo:         SLO{MaxQueueTime: 50 * time.Millisecond, Action: SLOFail},
+			wantKind:    KindSLOBreached,
+			wantMessage: "max_queue_time",
+		},
+		{
+			name:        "run time exceeded",
+			runTime:     hour,
+			slo:         SLO{MaxRunTime: 50 * time.Millisecond},
+			wantKind:    KindSLOBreached,
+			wantMessage: "max_run_time",
+		},
+		{
+			name:        "cancel",
+			runTime:     hour,
+			slo:         SLO{MaxRunTime: 50 * time.Millisecond, Action: SLOCancel},
+			wantKind:    KindSLOBreached,
+			wantMessage: "max_run_time",
+			wantCancels: 1,
+		},
+		{
+			name:    "warn",
+			runTime: 100 * time.Millisecond,
+			slo:     SLO{MaxRunTime: 20 * time.Millisecond, Action: SLOWarn},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cancels int32
+			server := sloServer(t, tt.queueTime, tt.runTime, &cancels)
+
+			client := New("owner", "repo", "token",
+				WithAPIURL(server.URL),
+				WithWaitInterval(10*time.Millisecond),
+				WithSLO(tt.slo),
+			)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err := client.Wait(ctx, 1)
+			if kind := KindOf(err); kind != tt.wantKind {
+				t.Fatalf("expected kind %q, got %q (%v)", tt.wantKind, kind, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Errorf("expected error mentioning %s, got %v", tt.wantMessage, err)
+			}
+			if got := atomic.LoadInt32(&cancels); got != tt.wantCancels {
+				t.Errorf("expected %d cancellations, got %d", tt.wantCancels, got)
+			}
+		})
+	}
+}
+
+// TestRunClock checks the measured phases and limits against fixed times,
+// since Wait only sees the transitions at its polls.
+func TestRunClock(t *testing.T) {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	rc := &runClock{created: created.Add(time.Minute)}
+	slo := SLO{MaxQueueTime: 2 * time.Minute, MaxRunTime: 10 * time.Minute}
+
+	rc.observe(&WorkflowRun{Status: "queued", CreatedAt: created.Format(time.RFC3339)}, created.Add(time.Minute))
+	if !rc.created.Equal(created) {
+		t.Errorf("expected the run's created_at to be used, got %v", rc.created)
+	}
+	if msg := slo.breach(rc, created.Add(3*time.Minute)); !strings.Contains(msg, "max_queue_time") {
+		t.Errorf("expected a queue breach, got %q", msg)
+	}
+	if d := slo.untilBreach(rc, created.Add(time.Minute), time.Hour); d != time.Minute+time.Millisecond {
+		t.Errorf("expected to poll just after the queue limit, got %v", d)
+	}
+
+	rc.observe(&WorkflowRun{Status: "in_progress"}, created.Add(90*time.Second))
+	if msg := slo.breach(rc, created.Add(5*time.Minute)); msg != "" {
+		t.Errorf("expected no breach while within max_run_time, got %q", msg)
+	}
+	if msg := slo.breach(rc, created.Add(12*time.Minute)); !strings.Contains(msg, "max_run_time") {
+		t.Errorf("expected a run breach, got %q", msg)
+	}
+
+	rc.observe(&WorkflowRun{Status: "completed", Conclusion: "success"}, created.Add(6*time.Minute))
+	queued, running := rc.durations(created.Add(time.Hour))
+	if queued != 90*time.Second || running != 270*time.Second {
+		t.Errorf("expected 1m30s queued and 4m30s running, got %v and %v", queued, running)
+	}
+}
+
+func TestParseSLOAction(t *testing.T) {
+	for _, s := range []string{"warn", "fail", "cancel"} {
+		if action, err := ParseSLOAction(s); err != nil || string(action) != s {
+			t.Errorf("ParseSLOAction(%q) = %q, %v", s, action, err)
+		}
+	}
+	if _, err := ParseSLOAction("ignore"); err == nil {
+		t.Error("expected error for invalid action")
+	}
+}
@@ -0,0 +1,134 @@
+package trigwait
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// CommitStatus is a commit status to create on a commit.
+type CommitStatus struct {
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Context     string `json:"context,omitempty"`
+}
+
+// CheckRun is a check run on a commit. Empty fields are left unchanged when
+// updating.
+type CheckRun struct {
+	ID         int64           `json:"id,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	HeadSHA    string          `json:"head_sha,omitempty"`
+	Status     string          `json:"status,omitempty"`
+	Conclusion string          `json:"conclusion,omitempty"`
+	DetailsURL string          `json:"details_url,omitempty"`
+	ExternalID string          `json:"external_id,omitempty"`
+	Output     *CheckRunOutput `json:"output,omitempty"`
+}
+
+// CheckRunOutput is the title and summary shown on a check run.
+type CheckRunOutput struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+}
+
+// MaxStatusDescription is the longest description, in characters, GitHub
+// accepts on a commit status.
+const MaxStatusDescription = 140
+
+// CreateCommitStatus creates a commit status on sha. Descriptions longer than
+// MaxStatusDescription are cut at the end; shorten them beforehand to keep
+// something else.
+func (c *Client) CreateCommitStatus(ctx context.Context, sha string, s CommitStatus) error {
+	if runes := []rune(s.Description); len(runes) > MaxStatusDescription {
+		s.Description = string(runes[:MaxStatusDescription-1]) + "…"
+	}
+	body, _ := json.Marshal(s)
+	_, _, err := c.repoRequest(ctx, "POST", "statuses/"+sha, body)
+	return err
+}
+
+// CreateCheckRun creates a check run and returns its ID.
+func (c *Client) CreateCheckRun(ctx context.Context, cr CheckRun) (int64, error) {
+	cr.ID = 0
+	body, _ := json.Marshal(cr)
+	respBody, _, err := c.repoRequest(ctx, "POST", "check-runs", body)
+	if err != nil {
+		return 0, err
+	}
+
+	var created CheckRun
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return created.ID, nil
+}
+
+// UpdateCheckRun updates the check run with the given ID.
+func (c *Client) UpdateCheckRun(ctx context.Context, id int64, cr CheckRun) error {
+	cr.ID = 0
+	body, _ := json.Marshal(cr)
+	_, _, err := c.repoRequest(ctx, "PATCH", fmt.Sprintf("check-runs/%d", id), body)
+	return err
+}
+
+// CombinedStatus is the latest commit status of each context on a commit.
+type CombinedStatus struct {
+	SHA      string         `json:"sha"`
+	Statuses []CommitStatus `json:"statuses"`
+}
+
+// GetCombinedStatus returns the latest commit status of each context on
+// ref, which may be a SHA, branch or tag. A branch or tag stands for the
+// commit at its tip.
+func (c *Client) GetCombinedStatus(ctx context.Context, ref string) (*CombinedStatus, error) {
+	combined := &CombinedStatus{}
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("commits/%s/status?per_page=100&page=%d", ref, page)
+		respBody, _, err := c.repoRequest(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var response struct {
+			CombinedStatus
+			TotalCount int `json:"total_count"`
+		}
+		if err := json.Unmarshal(respBody, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		combined.SHA = response.SHA
+		combined.Statuses = append(combined.Statuses, response.Statuses...)
+		if len(response.Statuses) == 0 || len(combined.Statuses) >= response.TotalCount {
+			return combined, nil
+		}
+	}
+}
+
+// ListCheckRuns returns the latest check run of each name on ref, which may
+// be a SHA, branch or tag.
+func (c *Client) ListCheckRuns(ctx context.Context, ref string) ([]CheckRun, error) {
+	var checkRuns []CheckRun
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("commits/%s/check-runs?per_page=100&page=%d", ref, page)
+		respBody, _, err := c.repoRequest(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var response struct {
+			TotalCount int        `json:"total_count"`
+			CheckRuns  []CheckRun `json:"check_runs"`
+		}
+		if err := json.Unmarshal(respBody, &response); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+
+		checkRuns = append(checkRuns, response.CheckRuns...)
+		if len(response.CheckRuns) == 0 || len(checkRuns) >= response.TotalCount {
+			return checkRuns, nil
+		}
+	}
+}
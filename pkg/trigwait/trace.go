@@ -0,0 +1,38 @@
+package trigwait
+
+import "context"
+
+// Tracer starts spans around the phases of Trigger, FindRun and Wait and
+// around every API request. Spans started from a context returned by Start
+// are its children.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a unit of work started by a Tracer.
+type Span interface {
+	// SetAttr records an attribute. Values are strings, bools, ints,
+	// int64s or float64s.
+	SetAttr(key string, value interface{})
+	// End finishes the span, marking it failed when err is not nil.
+	End(err error)
+}
+
+// WithTracer sets the tracer spans are started with. By default the client
+// records no spans.
+func WithTracer(tracer Tracer) Option {
+	return func(c *Client) {
+		c.tracer = tracer
+	}
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttr(string, interface{}) {}
+func (noopSpan) End(error)                   {}
@@ -0,0 +1,232 @@
+package trigwait
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Dispatch describes a workflow_dispatch event.
+type Dispatch struct {
+	// Workflow is the workflow file name, e.g. deploy.yml.
+	Workflow string
+	// Ref is the branch, tag or commit SHA to run the workflow on.
+	Ref string
+	// Inputs are passed to the workflow as its workflow_dispatch inputs.
+	Inputs map[string]interface{}
+	// DistinctID, when set, must appear in the run's display title as a
+	// whole word for the run to be matched (see ContainsDistinctID). The
+	// caller is responsible for passing it in Inputs.
+	DistinctID string
+}
+
+// DispatchRequest returns the method, URL and JSON body of the request
+// Dispatch sends for d.
+func (c *Client) DispatchRequest(d Dispatch) (method, url string, body []byte) {
+	inputs := d.Inputs
+	if inputs == nil {
+		inputs = map[string]interface{}{}
+	}
+	payload := map[string]interface{}{
+		"ref":    d.Ref,
+		"inputs": inputs,
+	}
+	body, _ = json.Marshal(payload)
+	return "POST", c.repoURL(dispatchPath(d)), body
+}
+
+func dispatchPath(d Dispatch) string {
+	return fmt.Sprintf("actions/workflows/%s/dispatches", d.Workflow)
+}
+
+// Dispatch sends the workflow_dispatch event without waiting for the run.
+func (c *Client) Dispatch(ctx context.Context, d Dispatch) (err error) {
+	ctx, span := c.tracer.Start(ctx, "dispatch")
+	span.SetAttr("workflow", d.Workflow)
+	span.SetAttr("ref", d.Ref)
+	defer func() { span.End(err) }()
+
+	method, _, payloadBytes := c.DispatchRequest(d)
+	if _, _, err := c.repoRequest(ctx, method, dispatchPath(d), payloadBytes); err != nil {
+		kind := KindOf(err)
+		var apiErr *APIError
+		if kind == KindUnknown && errors.As(err, &apiErr) && apiErr.StatusCode < 500 {
+			// GitHub rejects dispatches with 422 for unknown inputs, refs
+			// and workflows without a workflow_dispatch trigger.
+			kind = KindDispatchRejected
+		}
+		return &Error{Kind: kind, Err: fmt.Errorf("failed to trigger workflow: %w", err)}
+	}
+	return nil
+}
+
+// Trigger dispatches the workflow and waits for the resulting run to appear,
+// returning its ID.
+func (c *Client) Trigger(ctx context.Context, d Dispatch) (int64, error) {
+	startTime := time.Now()
+	deadline := startTime.Add(c.triggerTimeout)
+
+	// Print compact header
+	header := fmt.Sprintf("🚀 Triggering %s/%s → %s @ %s", c.owner, c.repo, d.Workflow, d.Ref)
+	if d.DistinctID != "" {
+		header += fmt.Sprintf(" [%s]", d.DistinctID)
+	}
+	c.logf(LevelInfo, "%s", header)
+	if len(d.Inputs) > 0 {
+		inputsJSON, _ := json.Marshal(d.Inputs)
+		c.logf(LevelInfo, "   Inputs: %s", string(inputsJSON))
+	}
+
+	if err := c.Dispatch(ctx, d); err != nil {
+		return 0, err
+	}
+
+	// Wait for the run to appear
+	retryInterval := c.waitInterval
+	lastPrintTime := time.Now()
+	for {
+		if time.Now().After(deadline) {
+			return 0, Errorf(KindDiscoveryTimeout, "timeout: workflow run did not appear within %v", c.triggerTimeout)
+		}
+
+		if err := sleep(ctx, retryInterval); err != nil {
+			return 0, err
+		}
+
+		runID, err := c.FindRun(ctx, d, startTime)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			c.logf(LevelDebug, "Error checking runs: %v", err)
+			if c.retryHook != nil {
+				c.retryHook(ctx, err)
+			}
+			// Only print errors occasionally to avoid spam
+			if time.Since(lastPrintTime) > 10*time.Second {
+				c.logf(LevelWarn, "⚠ Error checking runs (retrying...): %v", err)
+				lastPrintTime = time.Now()
+			}
+		}
+		if runID > 0 {
+			c.logf(LevelInfo, "   ✓ Triggered run #%d", runID)
+			return runID, nil
+		}
+
+		// Show progress dot every 10 seconds
+		if time.Since(lastPrintTime) > 10*time.Second {
+			elapsed := time.Since(startTime).Round(time.Second)
+			c.logf(LevelInfo, "   Finding run... %v", elapsed)
+			lastPrintTime = time.Now()
+		}
+
+		// Exponential backoff up to 60 seconds
+		retryInterval *= 2
+		if retryInterval > 60*time.Second {
+			retryInterval = 60 * time.Second
+		}
+	}
+}
+
+// maxSearchPages bounds how many pages of 100 runs FindRunByDistinctID
+// reads.
+const maxSearchPages = 10
+
+// FindRunByDistinctID looks for a run of the dispatched workflow created at
+// or after since and titled with d.DistinctID, such as one dispatched by an
+// earlier attempt. Unlike FindRun, which only checks the latest runs for one
+// just dispatched, it pages through up to 1000 runs, so it also finds older
+// runs of a busy workflow. It returns 0 when there is none.
+func (c *Client) FindRunByDistinctID(ctx context.Context, d Dispatch, since time.Time) (runID int64, err error) {
+	ctx, span := c.tracer.Start(ctx, "find_run")
+	span.SetAttr("workflow", d.Workflow)
+	span.SetAttr("ref", d.Ref)
+	defer func() {
+		if runID > 0 {
+			span.SetAttr("run.id", runID)
+		}
+		span.End(err)
+	}()
+	if d.DistinctID == "" {
+		return 0, fmt.Errorf("FindRunByDistinctID requires a distinct ID")
+	}
+
+	query := url.Values{"event": {"workflow_dispatch"}, "branch": {d.Ref}, "per_page": {"100"}}
+	if !since.IsZero() {
+		query.Set("created", ">="+since.UTC().Format(time.RFC3339))
+	}
+	for page := 1; page <= maxSearchPages; page++ {
+		query.Set("page", strconv.Itoa(page))
+		respBody, err := c.apiRequest(ctx, "GET", fmt.Sprintf("workflows/%s/runs?%s", d.Workflow, query.Encode()), nil)
+		if err != nil {
+			return 0, err
+		}
+
+		var response WorkflowRunsResponse
+		if err := json.Unmarshal(respBody, &response); err != nil {
+			return 0, fmt.Errorf("failed to parse response: %w", err)
+		}
+		for _, run := range response.WorkflowRuns {
+			if ContainsDistinctID(run.DisplayTitle, d.DistinctID) {
+				return run.ID, nil
+			}
+		}
+		if len(response.WorkflowRuns) < 100 {
+			return 0, nil
+		}
+	}
+	return 0, nil
+}
+
+// FindRun looks for a run of the dispatched workflow created at or after
+// startTime. It returns 0 when no matching run exists yet.
+func (c *Client) FindRun(ctx context.Context, d Dispatch, startTime time.Time) (runID int64, err error) {
+	ctx, span := c.tracer.Start(ctx, "find_run")
+	span.SetAttr("workflow", d.Workflow)
+	span.SetAttr("ref", d.Ref)
+	defer func() {
+		if runID > 0 {
+			span.SetAttr("run.id", runID)
+		}
+		span.End(err)
+	}()
+
+	// Build query with filters
+	query := fmt.Sprintf("event=workflow_dispatch&branch=%s&per_page=10", d.Ref)
+
+	path := fmt.Sprintf("workflows/%s/runs?%s", d.Workflow, query)
+	respBody, err := c.apiRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var response WorkflowRunsResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return 0, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Find a run created after startTime
+	for _, run := range response.WorkflowRuns {
+		createdAt, err := time.Parse(time.RFC3339, run.CreatedAt)
+		if err != nil {
+			continue
+		}
+		if createdAt.Unix() >= startTime.Unix() {
+			// If distinct_id is enabled, verify by checking display_title (run-name)
+			if d.DistinctID != "" {
+				if ContainsDistinctID(run.DisplayTitle, d.DistinctID) {
+					return run.ID, nil
+				}
+			} else {
+				// Fall back to time-based matching
+				return run.ID, nil
+			}
+		}
+	}
+
+	return 0, nil
+}
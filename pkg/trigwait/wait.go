@@ -0,0 +1,177 @@
+package trigwait
+
+import (
+	"context"
+	"time"
+)
+
+// Wait polls the workflow run until it completes and returns the completed
+// run. With WithRunEvents it relies on pushed events and only polls as a
+// fallback, and with WithSLO it stops early when the run is queued or runs
+// for too long. It does not treat a non-success conclusion as an error;
+// callers decide how to react to the conclusion, for example with RunError.
+func (c *Client) Wait(ctx context.Context, runID int64) (*WorkflowRun, error) {
+	if c.waitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.waitTimeout)
+		defer cancel()
+	}
+
+	c.logf(LevelInfo, "⏳ Waiting for workflow completion...")
+	c.logf(LevelInfo, "   URL: %s", c.RunURL(runID))
+	if c.events != nil {
+		c.logf(LevelInfo, "   Listening for workflow_run events (polling every %v as a fallback)", c.fallbackInterval)
+	}
+
+	startTime := time.Now()
+	lastStatus := ""
+	pollInterval := c.waitInterval
+	lastPrintTime := time.Now()
+	var lastEvent *WorkflowRun
+	if c.events != nil {
+		pollInterval = c.fallbackInterval
+	}
+
+	clock := runClock{created: startTime}
+	warned := map[string]bool{}
+	if c.durationsHook != nil {
+		defer func() { c.durationsHook(clock.durations(time.Now())) }()
+	}
+
+	// Poll for completion with adaptive intervals
+	for {
+		run, err := c.nextEvent(ctx, runID, lastEvent, c.slo.untilBreach(&clock, time.Now(), pollInterval))
+		if err != nil {
+			return nil, c.waitError(err)
+		}
+		if run != nil {
+			lastEvent = run
+			c.logf(LevelDebug, "Received workflow_run event for run %d", runID)
+		} else {
+			run, err = c.poll(ctx, runID)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, c.waitError(ctx.Err())
+			}
+			c.logf(LevelDebug, "Error fetching status: %v", err)
+			if c.retryHook != nil {
+				c.retryHook(ctx, err)
+			}
+			// Only show errors occasionally
+			if time.Since(lastPrintTime) > 10*time.Second {
+				c.logf(LevelWarn, "⚠ Error fetching status (retrying...): %v", err)
+				lastPrintTime = time.Now()
+			}
+			continue
+		}
+
+		elapsed := time.Since(startTime).Round(time.Second)
+		clock.observe(run, time.Now())
+		c.logf(LevelDebug, "Run %d: status=%s conclusion=%s", runID, run.Status, run.Conclusion)
+
+		if run.Status != lastStatus && c.statusHook != nil {
+			c.statusHook(run)
+		}
+
+		if run.Status == "completed" {
+			switch run.Conclusion {
+			case "success":
+				c.logf(LevelInfo, "   ✅ Completed successfully in %v", elapsed)
+			case "cancelled", "skipped", "neutral":
+				c.logf(LevelInfo, "   ⚪ Completed with conclusion: %s (duration: %v)", run.Conclusion, elapsed)
+			default:
+				c.logf(LevelInfo, "   ❌ Failed with status: %s (duration: %v)", run.Conclusion, elapsed)
+			}
+			return run, nil
+		}
+
+		if err := c.enforceSLO(ctx, runID, &clock, warned); err != nil {
+			return nil, err
+		}
+
+		// Only print status changes to reduce log noise
+		if run.Status != lastStatus {
+			statusIcon := "⏳"
+			statusText := run.Status
+			switch run.Status {
+			case "queued", "waiting", "pending":
+				statusIcon = "🔄"
+				statusText = "queued"
+			case "in_progress":
+				statusIcon = "▶️"
+				statusText = "running"
+			}
+			c.logf(LevelInfo, "   %s Status: %s (elapsed: %v)", statusIcon, statusText, elapsed)
+			lastStatus = run.Status
+			lastPrintTime = time.Now()
+		} else if time.Since(lastPrintTime) > 5*time.Minute {
+			// Update elapsed time every 5 minutes if status hasn't changed
+			statusText := "running"
+			if run.Status == "queued" || run.Status == "waiting" || run.Status == "pending" {
+				statusText = "queued"
+			}
+			c.logf(LevelInfo, "   %s Status: %s (elapsed: %v)", "⏳", statusText, elapsed)
+			lastPrintTime = time.Now()
+		}
+
+		// Adaptive polling: slower when queued, faster when in_progress
+		switch {
+		case c.events != nil:
+			pollInterval = c.fallbackInterval
+		case run.Status == "queued", run.Status == "waiting", run.Status == "pending":
+			pollInterval = maxDuration(c.waitInterval, 30*time.Second)
+		case run.Status == "in_progress":
+			pollInterval = c.waitInterval
+		}
+	}
+}
+
+// nextEvent waits up to d for a run event newer than last. It returns nil
+// when d passed without one, meaning the run should be polled.
+func (c *Client) nextEvent(ctx context.Context, runID int64, last *WorkflowRun, d time.Duration) (*WorkflowRun, error) {
+	if c.events == nil {
+		return nil, sleep(ctx, d)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		if run := c.events.Run(runID); run != nil && run != last {
+			return run, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return nil, nil
+		case <-c.events.Updated():
+		}
+	}
+}
+
+// waitError classifies a context error returned while waiting.
+func (c *Client) waitError(err error) error {
+	if !isTimeout(err) {
+		return err
+	}
+	if c.waitTimeout > 0 {
+		return Errorf(KindWaitTimeout, "timeout: workflow run did not complete within %v: %w", c.waitTimeout, err)
+	}
+	return Errorf(KindWaitTimeout, "timeout: workflow run did not complete: %w", err)
+}
+
+// poll fetches the run once for Wait, in a span of its own.
+func (c *Client) poll(ctx context.Context, runID int64) (*WorkflowRun, error) {
+	ctx, span := c.tracer.Start(ctx, "poll")
+	span.SetAttr("run.id", runID)
+	run, err := c.GetRun(ctx, runID)
+	if run != nil {
+		span.SetAttr("run.status", run.Status)
+		if run.Conclusion != "" {
+			span.SetAttr("run.conclusion", run.Conclusion)
+		}
+	}
+	span.End(err)
+	return run, err
+}
@@ -0,0 +1,198 @@
+package trigwait
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// RunEvents reports workflow run updates pushed by GitHub, letting Wait
+// complete without polling.
+type RunEvents interface {
+	// Run returns the latest state of the run received so far, or nil.
+	Run(runID int64) *WorkflowRun
+	// Updated receives a value whenever a new event arrives.
+	Updated() <-chan struct{}
+}
+
+// maxWebhookBody limits the size of a webhook delivery. GitHub caps payloads
+// at 25 MB; workflow_run payloads are far smaller.
+const maxWebhookBody = 25 << 20
+
+// WebhookReceiver collects workflow_run webhook deliveries. It is an
+// http.Handler for direct deliveries and can also read deliveries from a
+// relay with Relay. Deliveries without a valid X-Hub-Signature-256 for the
+// secret are rejected.
+type WebhookReceiver struct {
+	secret []byte
+
+	mu      sync.Mutex
+	runs    map[int64]*WorkflowRun
+	updated chan struct{}
+}
+
+// NewWebhookReceiver returns a receiver that verifies deliveries with the
+// webhook secret.
+func NewWebhookReceiver(secret string) *WebhookReceiver {
+	return &WebhookReceiver{
+		secret:  []byte(secret),
+		runs:    make(map[int64]*WorkflowRun),
+		updated: make(chan struct{}, 1),
+	}
+}
+
+// Run implements RunEvents.
+func (r *WebhookReceiver) Run(runID int64) *WorkflowRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.runs[runID]
+}
+
+// Updated implements RunEvents.
+func (r *WebhookReceiver) Updated() <-chan struct{} {
+	return r.updated
+}
+
+// ServeHTTP accepts a webhook delivery.
+func (r *WebhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch err := r.Deliver(req.Header.Get("X-GitHub-Event"), req.Header.Get("X-Hub-Signature-256"), body); {
+	case errors.Is(err, errBadSignature):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+var errBadSignature = errors.New("invalid webhook signature")
+
+// Deliver verifies and records one delivery of the named event. Events other
+// than workflow_run are verified and ignored.
+func (r *WebhookReceiver) Deliver(event, signature string, body []byte) error {
+	if !r.verify(signature, body) {
+		return errBadSignature
+	}
+	if event != "workflow_run" {
+		return nil
+	}
+
+	var payload struct {
+		Action      string       `json:"action"`
+		WorkflowRun *WorkflowRun `json:"workflow_run"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("failed to parse workflow_run event: %w", err)
+	}
+	run := payload.WorkflowRun
+	if run == nil || run.ID == 0 {
+		return fmt.Errorf("workflow_run event without a run")
+	}
+
+	r.mu.Lock()
+	// Deliveries may arrive out of order; never go back from completed
+	if prev := r.runs[run.ID]; prev != nil && prev.Status == "completed" && run.Status != "completed" && run.RunAttempt <= prev.RunAttempt {
+		r.mu.Unlock()
+		return nil
+	}
+	r.runs[run.ID] = run
+	r.mu.Unlock()
+
+	select {
+	case r.updated <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// verify checks an X-Hub-Signature-256 header against body.
+func (r *WebhookReceiver) verify(signature string, body []byte) bool {
+	if len(r.secret) == 0 || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, r.secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// Relay reads deliveries from a relay such as smee.io, which forwards
+// webhooks as a Server-Sent Events stream. Each event's data is a JSON object
+// with the delivery headers in lower case and the payload under "body". Relay
+// returns when the stream ends, with ctx.Err() when ctx is done.
+func (r *WebhookReceiver) Relay(ctx context.Context, httpClient *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("relay responded with %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxWebhookBody)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			if strings.HasPrefix(line, "data:") {
+				data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			}
+			continue
+		}
+		// A blank line ends the event
+		if data.Len() > 0 {
+			r.relayed([]byte(data.String()))
+			data.Reset()
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// relayed delivers one relayed event. Events that are not deliveries, such as
+// the relay's own keep-alive messages, are ignored.
+func (r *WebhookReceiver) relayed(data []byte) {
+	var delivery struct {
+		Event     string          `json:"x-github-event"`
+		Signature string          `json:"x-hub-signature-256"`
+		Body      json.RawMessage `json:"body"`
+	}
+	if err := json.Unmarshal(data, &delivery); err != nil || delivery.Event == "" {
+		return
+	}
+	r.Deliver(delivery.Event, delivery.Signature, delivery.Body)
+}
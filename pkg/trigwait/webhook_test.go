@@ -0,0 +1,157 @@
+package trigwait
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func runEvent(id int64, status, conclusion string) string {
+	return fmt.Sprintf(`{"action":%q,"workflow_run":{"id":%d,"status":%q,"conclusion":%q,"run_attempt":1}}`,
+		status, id, status, conclusion)
+}
+
+func TestWebhookReceiver_ServeHTTP(t *testing.T) {
+	body := runEvent(42, "completed", "success")
+	tests := []struct {
+		name      string
+		event     string
+		signature string
+		body      string
+		want      int
+	}{
+		{"valid", "workflow_run", sign("s3cret", body), body, http.StatusNoContent},
+		{"ping", "ping", sign("s3cret", `{"zen":"hi"}`), `{"zen":"hi"}`, http.StatusNoContent},
+		{"wrong secret", "workflow_run", sign("other", body), body, http.StatusUnauthorized},
+		{"missing signature", "workflow_run", "", body, http.StatusUnauthorized},
+		{"sha1 signature", "workflow_run", "sha1=abc", body, http.StatusUnauthorized},
+		{"invalid payload", "workflow_run", sign("s3cret", "{"), "{", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := NewWebhookReceiver("s3cret")
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			req.Header.Set("X-GitHub-Event", tt.event)
+			if tt.signature != "" {
+				req.Header.Set("X-Hub-Signature-256", tt.signature)
+			}
+			rec := httptest.NewRecorder()
+			receiver.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("expected status %d, got %d (%s)", tt.want, rec.Code, rec.Body.String())
+			}
+			if run := receiver.Run(42); (run != nil) != (tt.name == "valid") {
+				t.Errorf("unexpected recorded run: %+v", run)
+			}
+		})
+	}
+}
+
+func TestWebhookReceiver_OutOfOrder(t *testing.T) {
+	receiver := NewWebhookReceiver("s3cret")
+	for _, body := range []string{runEvent(1, "completed", "failure"), runEvent(1, "in_progress", "")} {
+		if err := receiver.Deliver("workflow_run", sign("s3cret", body), []byte(body)); err != nil {
+			t.Fatalf("Deliver failed: %v", err)
+		}
+	}
+	if run := receiver.Run(1); run.Status != "completed" || run.Conclusion != "failure" {
+		t.Errorf("expected late in_progress event to be ignored, got %s/%s", run.Status, run.Conclusion)
+	}
+}
+
+func TestWait_RunEvents(t *testing.T) {
+	var polls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&polls, 1)
+		json.NewEncoder(w).Encode(WorkflowRun{ID: 7, Status: "in_progress"})
+	}))
+	defer server.Close()
+
+	receiver := NewWebhookReceiver("s3cret")
+	client := New("owner", "repo", "token",
+		WithAPIURL(server.URL),
+		WithRunEvents(receiver, time.Hour),
+	)
+
+	go func() {
+		for _, body := range []string{runEvent(7, "in_progress", ""), runEvent(8, "completed", "failure"), runEvent(7, "completed", "success")} {
+			time.Sleep(10 * time.Millisecond)
+			receiver.Deliver("workflow_run", sign("s3cret", body), []byte(body))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	run, err := client.Wait(ctx, 7)
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if run.Conclusion != "success" {
+		t.Errorf("expected success, got %s", run.Conclusion)
+	}
+	if n := atomic.LoadInt32(&polls); n != 0 {
+		t.Errorf("expected no polling, got %d requests", n)
+	}
+}
+
+func TestWait_RunEventsFallsBackToPolling(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(WorkflowRun{ID: 7, Status: "completed", Conclusion: "success"})
+	}))
+	defer server.Close()
+
+	client := New("owner", "repo", "token",
+		WithAPIURL(server.URL),
+		WithRunEvents(NewWebhookReceiver("s3cret"), 20*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	run, err := client.Wait(ctx, 7)
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if run.Conclusion != "success" {
+		t.Errorf("expected success, got %s", run.Conclusion)
+	}
+}
+
+func TestWebhookReceiver_Relay(t *testing.T) {
+	body := runEvent(42, "completed", "success")
+	delivery, _ := json.Marshal(map[string]interface{}{
+		"x-github-event":      "workflow_run",
+		"x-hub-signature-256": sign("s3cret", body),
+		"body":                json.RawMessage(body),
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: ready\ndata: {}\n\n")
+		fmt.Fprintf(w, "data: %s\n\n", delivery)
+	}))
+	defer server.Close()
+
+	receiver := NewWebhookReceiver("s3cret")
+	if err := receiver.Relay(context.Background(), server.Client(), server.URL); err == nil {
+		t.Error("expected an error when the stream ends")
+	}
+	if run := receiver.Run(42); run == nil || run.Conclusion != "success" {
+		t.Errorf("expected relayed run, got %+v", run)
+	}
+}
@@ -21,7 +21,7 @@ build() {
         -trimpath \
         -ldflags="-s -w -buildid=" \
         -o "$output" \
-        ./cmd/main.go
+        ./cmd
 
     local size=$(ls -lh "$output" | awk '{print $5}')
     echo "✓ ${size}"